@@ -2,9 +2,11 @@ package main
 
 import (
 	"context"
+	"fmt"
 	"log"
 	"os"
 	"path/filepath"
+	"strings"
 	"time"
 
 	"github.com/gin-gonic/gin"
@@ -13,6 +15,7 @@ import (
 	"go.mongodb.org/mongo-driver/mongo/options"
 	
 	"go-blog-platform/config"
+	"go-blog-platform/internal/constants"
 	"go-blog-platform/internal/handlers"
 	"go-blog-platform/internal/middleware"
 	"go-blog-platform/internal/services"
@@ -48,15 +51,149 @@ func main() {
 	}
 
 	// Initialize services
-	emailService := services.NewEmailService()
+	mailer, err := newMailer(cfg.SMTP)
+	if err != nil {
+		log.Fatal(err)
+	}
+	outbox := services.NewOutbox(db, mailer, cfg.SMTP.OutboxMaxAttempts, cfg.SMTP.OutboxPollInterval)
+	emailTemplates := services.NewEmailTemplates(cfg.SMTP.TemplatesDir)
+	emailService := services.NewEmailService(outbox, emailTemplates, cfg.SMTP.FromEmail, cfg.SMTP.FromName, cfg.SiteName, cfg.BaseURL)
 	mediaService := services.NewMediaService(uploadsDir, cfg.BaseURL)
+	sessionService := services.NewSessionService(db, cfg.JWT.RefreshTokenTTL)
+	passwordResetService := services.NewPasswordResetService(db, time.Hour)
+	roleService := services.NewRoleService(db)
+	twoFactorPolicy := services.NewTwoFactorPolicyService(db, roleService, cfg.Auth.TwoFactorRequiredRole)
+	apiKeyService := services.NewAPIKeyService(db, 25)
+	revisionService := services.NewRevisionService(db)
+	slugService := services.NewSlugService(db)
+	sanitizer, err := services.NewSanitizer(services.SanitizerConfig{
+		Policy:          cfg.HTML.SanitizerPolicy,
+		AllowedElements: cfg.HTML.AllowedElements,
+		URLSchemes:      cfg.HTML.AllowedURLSchemes,
+	})
+	if err != nil {
+		log.Fatal(err)
+	}
+	markdownRenderer := services.NewMarkdownRenderer(sanitizer)
+	postScheduler := services.NewPostScheduler(db, cfg.Posts.SchedulerInterval)
+	passwordPolicy, err := services.NewPasswordPolicy(services.PasswordPolicyConfig{
+		MinLength:           cfg.Auth.PasswordMinLength,
+		MinCharacterClasses: cfg.Auth.PasswordMinCharacterClasses,
+		BreachedHashesFile:  cfg.Auth.PasswordBreachedHashesFile,
+	})
+	if err != nil {
+		log.Fatal(err)
+	}
+	loginAttempts, err := newLoginAttemptStore(ctx, cfg.Auth, db)
+	if err != nil {
+		log.Fatal(err)
+	}
+	loginThrottle := services.NewLoginThrottle(loginAttempts, services.LoginThrottleConfig{
+		MaxAccountFailures: cfg.Auth.LoginMaxFailures,
+		MaxIPFailures:      cfg.Auth.LoginMaxFailuresPerIP,
+		LockoutDuration:    cfg.Auth.LoginLockoutDuration,
+		Window:             cfg.Auth.LoginAttemptWindow,
+		BaseDelay:          cfg.Auth.LoginBaseDelay,
+		MaxDelay:           cfg.Auth.LoginMaxDelay,
+	})
+
+	keySet := services.NewHMACKeySet(cfg.JWT.Secret)
+	if cfg.JWT.Algorithm != services.AlgorithmHS256 {
+		keySet, err = services.LoadKeySet(cfg.JWT.Algorithm, cfg.JWT.SigningKeyFile, cfg.JWT.VerificationKeyFiles)
+		if err != nil {
+			log.Fatal(err)
+		}
+	}
+	tokenService := services.NewTokenService(keySet, cfg.JWT.Issuer, cfg.JWT.Audience, cfg.JWT.AccessTokenTTL)
 
 	// Initialize handlers
-	userHandler := handlers.NewUserHandler(db, cfg.JWT.Secret, emailService, mediaService, cfg.BaseURL)
-	postHandler := handlers.NewPostHandler(db, mediaService)
+	userHandler := handlers.NewUserHandler(db, tokenService, emailService, mediaService, sessionService, passwordResetService, twoFactorPolicy, roleService, loginThrottle, passwordPolicy, sanitizer, cfg.SiteName, cfg.BaseURL)
+	postHandler := handlers.NewPostHandler(db, mediaService, revisionService, slugService, markdownRenderer, emailService, cfg.BaseURL)
+	jwksHandler := handlers.NewJWKSHandler(tokenService)
+	outboxHandler := handlers.NewOutboxHandler(outbox)
+	apiKeyHandler := handlers.NewAPIKeyHandler(apiKeyService)
+	roleHandler := handlers.NewRoleHandler(roleService)
+
+	// OpenID Connect login is optional
+	var oidcHandler *handlers.OIDCHandler
+	if cfg.OIDC.IssuerURL != "" {
+		oidcService := services.NewOIDCService(db, services.OIDCConfig{
+			IssuerURL:    cfg.OIDC.IssuerURL,
+			ClientID:     cfg.OIDC.ClientID,
+			ClientSecret: cfg.OIDC.ClientSecret,
+			RedirectURL:  cfg.OIDC.RedirectURL,
+			Scopes:       cfg.OIDC.Scopes,
+			GroupsClaim:  cfg.OIDC.GroupsClaim,
+		}, nil)
+		oidcHandler = handlers.NewOIDCHandler(oidcService, userHandler, handlers.OIDCOptions{
+			RoleMapping:  cfg.OIDC.RoleMapping,
+			DefaultRole:  cfg.OIDC.DefaultRole,
+			AllowSignup:  cfg.OIDC.AllowSignup,
+			LinkByEmail:  cfg.OIDC.LinkByEmail,
+			SecureCookie: strings.HasPrefix(cfg.BaseURL, "https://"),
+		})
+	}
+
+	// Prepare collections
+	if err := roleService.EnsureDefaults(ctx); err != nil {
+		log.Fatal(err)
+	}
+	if err := sessionService.EnsureIndexes(ctx); err != nil {
+		log.Fatal(err)
+	}
+	if err := passwordResetService.EnsureIndexes(ctx); err != nil {
+		log.Fatal(err)
+	}
+	if err := userHandler.MigrateEmailVerification(ctx); err != nil {
+		log.Fatal(err)
+	}
+	if err := userHandler.MigratePasswordResetTokens(ctx); err != nil {
+		log.Fatal(err)
+	}
+	if err := userHandler.MigrateUserRoles(ctx); err != nil {
+		log.Fatal(err)
+	}
+	if err := outbox.EnsureIndexes(ctx); err != nil {
+		log.Fatal(err)
+	}
+	if err := apiKeyService.EnsureIndexes(ctx); err != nil {
+		log.Fatal(err)
+	}
+	if err := postScheduler.EnsureIndexes(ctx); err != nil {
+		log.Fatal(err)
+	}
+	if err := revisionService.EnsureIndexes(ctx); err != nil {
+		log.Fatal(err)
+	}
+	if err := slugService.EnsureIndexes(ctx); err != nil {
+		log.Fatal(err)
+	}
+	if err := slugService.MigrateSlugs(ctx); err != nil {
+		log.Fatal(err)
+	}
+	if err := postHandler.EnsureIndexes(ctx); err != nil {
+		log.Fatal(err)
+	}
+	if err := postHandler.MigrateContentHTML(ctx); err != nil {
+		log.Fatal(err)
+	}
+	if oidcHandler != nil {
+		if err := oidcHandler.EnsureIndexes(ctx); err != nil {
+			log.Fatal(err)
+		}
+	}
+
+	// Start background workers
+	workerCtx, stopWorkers := context.WithCancel(context.Background())
+	defer stopWorkers()
+	go outbox.Run(workerCtx)
+	go postScheduler.Run(workerCtx)
 
 	// Initialize router
-	r := gin.Default()
+	r, err := newRouter(cfg.Server)
+	if err != nil {
+		log.Fatal(err)
+	}
 
 	// CORS middleware
 	r.Use(func(c *gin.Context) {
@@ -76,6 +213,69 @@ func main() {
 	// Serve static files
 	r.Use(static.Serve("/", static.LocalFile("ui/build", true)))
 
+	// Public keys for services that verify our tokens
+	r.GET("/.well-known/jwks.json", jwksHandler.GetJWKS)
+
+	authMiddleware := middleware.AuthMiddleware(tokenService, sessionService, apiKeyService, roleService)
+	registerRoutes(r, authMiddleware, twoFactorPolicy, userHandler, postHandler, apiKeyHandler, oidcHandler, outboxHandler, roleHandler, mediaService)
+
+	// Serve media files
+	r.Static("/media", uploadsDir)
+
+	// Start server
+	if err := r.Run(":" + cfg.Server.Port); err != nil {
+		log.Fatal("Failed to start server:", err)
+	}
+}
+
+// newRouter creates the router. Client IPs, which the login throttle counts
+// failures by, come from X-Forwarded-For only behind the configured proxies.
+func newRouter(cfg config.ServerConfig) (*gin.Engine, error) {
+	r := gin.Default()
+	if err := r.SetTrustedProxies(cfg.TrustedProxies); err != nil {
+		return nil, fmt.Errorf("invalid trusted proxies: %w", err)
+	}
+	return r, nil
+}
+
+// registerRoutes mounts the API on r. oidcHandler is nil when OpenID Connect
+// login is disabled.
+func registerRoutes(
+	r *gin.Engine,
+	authMiddleware gin.HandlerFunc,
+	twoFactorPolicy *services.TwoFactorPolicyService,
+	userHandler *handlers.UserHandler,
+	postHandler *handlers.PostHandler,
+	apiKeyHandler *handlers.APIKeyHandler,
+	oidcHandler *handlers.OIDCHandler,
+	outboxHandler *handlers.OutboxHandler,
+	roleHandler *handlers.RoleHandler,
+	mediaService *services.MediaService,
+) {
+	authorize := middleware.AuthorizeRoutes(routePermissions)
+
+	// Write endpoints API keys with scopes may call; any other route is
+	// read-only for them. Keys without scopes act with the full rights of their user.
+	apiKeyScopes := map[string]string{
+		"POST /api/posts":                               constants.ScopePostsWrite,
+		"PUT /api/posts/:id":                            constants.ScopePostsWrite,
+		"DELETE /api/posts/:id":                         constants.ScopePostsWrite,
+		"POST /api/posts/:id/collaborators":             constants.ScopePostsWrite,
+		"PUT /api/posts/:id/collaborators/:user_id":     constants.ScopePostsWrite,
+		"DELETE /api/posts/:id/collaborators/:user_id":  constants.ScopePostsWrite,
+		"POST /api/posts/:id/submit":                    constants.ScopePostsWrite,
+		"POST /api/posts/:id/withdraw":                  constants.ScopePostsWrite,
+		"POST /api/posts/:id/approve":                   constants.ScopePostsWrite,
+		"POST /api/posts/:id/reject":                    constants.ScopePostsWrite,
+		"POST /api/posts/:id/schedule":                  constants.ScopePostsWrite,
+		"POST /api/posts/:id/publish":                   constants.ScopePostsWrite,
+		"PUT /api/posts/:id/unpublish-at":               constants.ScopePostsWrite,
+		"POST /api/posts/:id/archive":                   constants.ScopePostsWrite,
+		"POST /api/posts/:id/revisions/:number/restore": constants.ScopePostsWrite,
+		"POST /api/media":                               constants.ScopeMediaWrite,
+		"DELETE /api/media/*path":                       constants.ScopeMediaWrite,
+	}
+
 	// API routes
 	api := r.Group("/api")
 	{
@@ -84,31 +284,87 @@ func main() {
 		{
 			auth.POST("/register", userHandler.Register)
 			auth.POST("/login", userHandler.Login)
+			auth.POST("/login/2fa", userHandler.LoginTwoFactor)
+			auth.POST("/refresh", userHandler.RefreshToken)
+			auth.POST("/logout", authMiddleware, authorize, middleware.RequireSession(), userHandler.Logout)
+			auth.POST("/verify-email", userHandler.VerifyEmail)
+			auth.POST("/verify-email/resend", authMiddleware, authorize, userHandler.ResendVerificationEmail)
 			auth.POST("/password-reset/request", userHandler.RequestPasswordReset)
 			auth.POST("/password-reset/reset", userHandler.ResetPassword)
+			auth.POST("/email-change/confirm", userHandler.ConfirmEmailChange)
+			if oidcHandler != nil {
+				auth.POST("/oidc/login", oidcHandler.Login)
+				auth.POST("/oidc/callback", oidcHandler.Callback)
+			}
+		}
+
+		// Two-factor enrollment stays reachable for users the 2FA policy locks out
+		twoFactor := api.Group("/users/me/2fa")
+		twoFactor.Use(authMiddleware, authorize, middleware.RequireSession())
+		{
+			twoFactor.GET("", userHandler.GetTwoFactorStatus)
+			twoFactor.POST("/totp", userHandler.SetupTOTP)
+			twoFactor.POST("/totp/confirm", userHandler.ConfirmTOTP)
+			twoFactor.POST("/recovery-codes", userHandler.RegenerateRecoveryCodes)
+			twoFactor.DELETE("", userHandler.DisableTwoFactor)
 		}
 
-		// Protected routes
+		// Protected routes; routePermissions decides which permission each one needs
 		protected := api.Group("")
-		protected.Use(middleware.AuthMiddleware([]byte(cfg.JWT.Secret)))
+		protected.Use(authMiddleware, authorize, middleware.EnforceAPIKeyScopes(apiKeyScopes), middleware.RequireTwoFactor(twoFactorPolicy, userHandler))
 		{
 			// User routes
 			users := protected.Group("/users")
 			{
 				users.GET("", userHandler.ListUsers)
 				users.PUT("/profile", userHandler.UpdateProfile)
+				users.PUT("/me/password", middleware.RequireSession(), userHandler.ChangePassword)
+				users.POST("/me/email", middleware.RequireSession(), userHandler.RequestEmailChange)
+				users.GET("/me/api-keys", middleware.RequireSession(), apiKeyHandler.List)
+				users.POST("/me/api-keys", middleware.RequireSession(), apiKeyHandler.Create)
+				users.DELETE("/me/api-keys/:id", middleware.RequireSession(), apiKeyHandler.Delete)
+				if oidcHandler != nil {
+					users.GET("/me/identities", middleware.RequireSession(), oidcHandler.ListIdentities)
+					users.POST("/me/identities/oidc", middleware.RequireSession(), oidcHandler.Link)
+					users.DELETE("/me/identities/oidc", middleware.RequireSession(), oidcHandler.Unlink)
+				}
 				users.PUT("/:id/role", userHandler.UpdateUserRole)
 				users.DELETE("/:id", userHandler.DeleteUser)
+				users.PUT("/:id/email-verification", userHandler.SetEmailVerification)
+				users.GET("/:id/sessions", userHandler.ListUserSessions)
+				users.DELETE("/:id/sessions", userHandler.RevokeUserSessions)
+				users.DELETE("/:id/sessions/:session_id", userHandler.RevokeUserSession)
+				users.DELETE("/:id/2fa", userHandler.ResetTwoFactor)
+				users.POST("/:id/unlock", userHandler.UnlockUser)
 			}
 
 			// Post routes
 			posts := protected.Group("/posts")
 			{
 				posts.GET("", postHandler.List)
-				posts.POST("", postHandler.Create)
+				posts.GET("/review-queue", postHandler.ReviewQueue)
+				posts.GET("/by-slug/:slug", postHandler.GetBySlug)
+				posts.POST("", middleware.RequireVerifiedEmail(userHandler), postHandler.Create)
 				posts.GET("/:id", postHandler.Get)
 				posts.PUT("/:id", postHandler.Update)
-				posts.DELETE("/:id", postHandler.Delete)
+				posts.DELETE("/:id", middleware.RequireOwnership(postHandler, "id", constants.PermPostCreate, constants.PermPostEditAny), postHandler.Delete)
+				posts.GET("/:id/collaborators", postHandler.ListCollaborators)
+				posts.POST("/:id/collaborators", postHandler.AddCollaborator)
+				posts.PUT("/:id/collaborators/:user_id", postHandler.UpdateCollaborator)
+				posts.DELETE("/:id/collaborators/:user_id", postHandler.RemoveCollaborator)
+				posts.GET("/:id/history", postHandler.StatusHistory)
+				posts.POST("/:id/submit", postHandler.Transition(services.PostActionSubmit))
+				posts.POST("/:id/withdraw", postHandler.Transition(services.PostActionWithdraw))
+				posts.POST("/:id/approve", postHandler.Transition(services.PostActionApprove))
+				posts.POST("/:id/reject", postHandler.Transition(services.PostActionReject))
+				posts.POST("/:id/schedule", postHandler.Transition(services.PostActionSchedule))
+				posts.POST("/:id/publish", postHandler.Transition(services.PostActionPublish))
+				posts.PUT("/:id/unpublish-at", postHandler.SetUnpublishAt)
+				posts.POST("/:id/archive", postHandler.Transition(services.PostActionArchive))
+				posts.GET("/:id/revisions", postHandler.ListRevisions)
+				posts.GET("/:id/revisions/diff", postHandler.DiffRevisions)
+				posts.GET("/:id/revisions/:number", postHandler.GetRevision)
+				posts.POST("/:id/revisions/:number/restore", postHandler.RestoreRevision)
 			}
 
 			// Media routes
@@ -121,8 +377,8 @@ func main() {
 						return
 					}
 
-					userID, _ := c.Get("user_id")
-					filePath, thumbnails, err := mediaService.SaveFile(file, userID.(string))
+					principal, _ := middleware.CurrentPrincipal(c)
+					filePath, thumbnails, err := mediaService.SaveFile(file, principal.UserID.Hex())
 					if err != nil {
 						c.JSON(500, gin.H{"error": err.Error()})
 						return
@@ -133,8 +389,20 @@ func main() {
 						"thumbnails": thumbnails,
 					})
 				})
-				media.DELETE("/:path", func(c *gin.Context) {
-					path := c.Param("path")
+				media.DELETE("/*path", func(c *gin.Context) {
+					path := strings.TrimPrefix(c.Param("path"), "/")
+
+					ownerID, err := mediaService.OwnerOf(path)
+					if err != nil {
+						c.JSON(404, gin.H{"error": "File not found"})
+						return
+					}
+					principal, _ := middleware.CurrentPrincipal(c)
+					if !principal.CanModify(ownerID, constants.PermMediaUpload, constants.PermMediaManageAny) {
+						c.JSON(403, gin.H{"error": "You can only modify your own resources"})
+						return
+					}
+
 					if err := mediaService.DeleteFile(path); err != nil {
 						c.JSON(500, gin.H{"error": err.Error()})
 						return
@@ -142,14 +410,53 @@ func main() {
 					c.JSON(200, gin.H{"message": "File deleted successfully"})
 				})
 			}
+
+			// Admin routes
+			admin := protected.Group("/admin")
+			{
+				admin.GET("/outbox", outboxHandler.List)
+				admin.GET("/outbox/:id", outboxHandler.Get)
+				admin.POST("/outbox/:id/resend", outboxHandler.Resend)
+				admin.GET("/two-factor-policy", userHandler.GetTwoFactorPolicy)
+				admin.PUT("/two-factor-policy", userHandler.SetTwoFactorPolicy)
+				admin.POST("/login-locks/ip/unlock", userHandler.UnlockIP)
+				admin.GET("/roles", roleHandler.List)
+				admin.POST("/roles", roleHandler.Create)
+				admin.GET("/roles/:name", roleHandler.Get)
+				admin.PUT("/roles/:name", roleHandler.Update)
+				admin.DELETE("/roles/:name", roleHandler.Delete)
+				admin.GET("/permissions", roleHandler.ListPermissions)
+			}
 		}
 	}
+}
 
-	// Serve media files
-	r.Static("/media", uploadsDir)
+// newMailer builds the mail transport selected by MAIL_TRANSPORT
+func newMailer(cfg config.SMTPConfig) (services.Mailer, error) {
+	switch cfg.Transport {
+	case "smtp":
+		return services.NewSMTPMailer(cfg.Host, cfg.Port, cfg.Username, cfg.Password, cfg.TLSMode)
+	case "file":
+		return services.NewFileMailer(cfg.MailDir)
+	case "memory":
+		return services.NewMemoryMailer(), nil
+	default:
+		return nil, fmt.Errorf("unsupported mail transport %q", cfg.Transport)
+	}
+}
 
-	// Start server
-	if err := r.Run(":" + cfg.Server.Port); err != nil {
-		log.Fatal("Failed to start server:", err)
+// newLoginAttemptStore builds the failed login store selected by LOGIN_ATTEMPT_STORE
+func newLoginAttemptStore(ctx context.Context, cfg config.AuthConfig, db *mongo.Database) (services.LoginAttemptStore, error) {
+	switch cfg.LoginAttemptStore {
+	case "mongo":
+		store := services.NewMongoLoginAttemptStore(db)
+		if err := store.EnsureIndexes(ctx); err != nil {
+			return nil, err
+		}
+		return store, nil
+	case "memory":
+		return services.NewMemoryLoginAttemptStore(), nil
+	default:
+		return nil, fmt.Errorf("unsupported login attempt store %q", cfg.LoginAttemptStore)
 	}
 }
@@ -0,0 +1,82 @@
+package main
+
+import "go-blog-platform/internal/constants"
+
+// routePermissions lists the permission needed for every authenticated route.
+// middleware.AuthorizeRoutes denies routes that are missing here, so add new
+// routes to this table when registering them. Routes that change a single
+// post or media item also check ownership in the handler chain.
+var routePermissions = map[string]string{
+	// Account
+	"POST /api/auth/logout":                 constants.PermAuthenticated,
+	"POST /api/auth/verify-email/resend":    constants.PermAuthenticated,
+	"GET /api/users/me/2fa":                 constants.PermAuthenticated,
+	"POST /api/users/me/2fa/totp":           constants.PermAuthenticated,
+	"POST /api/users/me/2fa/totp/confirm":   constants.PermAuthenticated,
+	"POST /api/users/me/2fa/recovery-codes": constants.PermAuthenticated,
+	"DELETE /api/users/me/2fa":              constants.PermAuthenticated,
+	"PUT /api/users/profile":                constants.PermAuthenticated,
+	"PUT /api/users/me/password":            constants.PermAuthenticated,
+	"POST /api/users/me/email":              constants.PermAuthenticated,
+	"GET /api/users/me/api-keys":            constants.PermAuthenticated,
+	"POST /api/users/me/api-keys":           constants.PermAuthenticated,
+	"DELETE /api/users/me/api-keys/:id":     constants.PermAuthenticated,
+	"GET /api/users/me/identities":          constants.PermAuthenticated,
+	"POST /api/users/me/identities/oidc":    constants.PermAuthenticated,
+	"DELETE /api/users/me/identities/oidc":  constants.PermAuthenticated,
+
+	// User administration
+	"GET /api/users":                             constants.PermUserManage,
+	"PUT /api/users/:id/role":                    constants.PermRoleManage,
+	"DELETE /api/users/:id":                      constants.PermUserManage,
+	"PUT /api/users/:id/email-verification":      constants.PermUserManage,
+	"GET /api/users/:id/sessions":                constants.PermUserManage,
+	"DELETE /api/users/:id/sessions":             constants.PermUserManage,
+	"DELETE /api/users/:id/sessions/:session_id": constants.PermUserManage,
+	"DELETE /api/users/:id/2fa":                  constants.PermUserManage,
+	"POST /api/users/:id/unlock":                 constants.PermUserManage,
+
+	// Posts
+	"GET /api/posts":                                constants.PermAuthenticated,
+	"GET /api/posts/:id":                            constants.PermAuthenticated,
+	"POST /api/posts":                               constants.PermPostCreate,
+	"PUT /api/posts/:id":                            constants.PermAuthenticated,
+	"DELETE /api/posts/:id":                         constants.PermAuthenticated,
+	"GET /api/posts/:id/collaborators":              constants.PermAuthenticated,
+	"POST /api/posts/:id/collaborators":             constants.PermAuthenticated,
+	"PUT /api/posts/:id/collaborators/:user_id":     constants.PermAuthenticated,
+	"DELETE /api/posts/:id/collaborators/:user_id":  constants.PermAuthenticated,
+	"GET /api/posts/review-queue":                   constants.PermPostReview,
+	"GET /api/posts/by-slug/:slug":                  constants.PermAuthenticated,
+	"GET /api/posts/:id/history":                    constants.PermAuthenticated,
+	"POST /api/posts/:id/submit":                    constants.PermAuthenticated,
+	"POST /api/posts/:id/withdraw":                  constants.PermAuthenticated,
+	"POST /api/posts/:id/approve":                   constants.PermPostReview,
+	"POST /api/posts/:id/reject":                    constants.PermPostReview,
+	"POST /api/posts/:id/schedule":                  constants.PermPostPublish,
+	"POST /api/posts/:id/publish":                   constants.PermPostPublish,
+	"PUT /api/posts/:id/unpublish-at":               constants.PermAuthenticated,
+	"POST /api/posts/:id/archive":                   constants.PermAuthenticated,
+	"GET /api/posts/:id/revisions":                  constants.PermAuthenticated,
+	"GET /api/posts/:id/revisions/diff":             constants.PermAuthenticated,
+	"GET /api/posts/:id/revisions/:number":          constants.PermAuthenticated,
+	"POST /api/posts/:id/revisions/:number/restore": constants.PermAuthenticated,
+
+	// Media
+	"POST /api/media":         constants.PermMediaUpload,
+	"DELETE /api/media/*path": constants.PermAuthenticated,
+
+	// Site administration
+	"GET /api/admin/outbox":                 constants.PermSettingsManage,
+	"GET /api/admin/outbox/:id":             constants.PermSettingsManage,
+	"POST /api/admin/outbox/:id/resend":     constants.PermSettingsManage,
+	"GET /api/admin/two-factor-policy":      constants.PermSettingsManage,
+	"PUT /api/admin/two-factor-policy":      constants.PermSettingsManage,
+	"POST /api/admin/login-locks/ip/unlock": constants.PermUserManage,
+	"GET /api/admin/roles":                  constants.PermRoleManage,
+	"POST /api/admin/roles":                 constants.PermRoleManage,
+	"GET /api/admin/roles/:name":            constants.PermRoleManage,
+	"PUT /api/admin/roles/:name":            constants.PermRoleManage,
+	"DELETE /api/admin/roles/:name":         constants.PermRoleManage,
+	"GET /api/admin/permissions":            constants.PermRoleManage,
+}
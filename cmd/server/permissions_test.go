@@ -0,0 +1,222 @@
+package main
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+
+	"go-blog-platform/internal/constants"
+	"go-blog-platform/internal/handlers"
+	"go-blog-platform/internal/middleware"
+	"go-blog-platform/internal/models"
+	"go-blog-platform/internal/services"
+)
+
+type activeSessions struct{}
+
+func (activeSessions) IsActive(ctx context.Context, sessionID string) (bool, error) {
+	return true, nil
+}
+
+// defaultRoles resolves permissions from the built-in role defaults
+type defaultRoles struct{}
+
+func (defaultRoles) Permissions(ctx context.Context, role string) ([]string, error) {
+	return constants.DefaultRolePermissions[role], nil
+}
+
+// publicRoutes are the API routes served without authentication, and so
+// without an entry in routePermissions
+var publicRoutes = map[string]bool{
+	"POST /api/auth/register":               true,
+	"POST /api/auth/login":                  true,
+	"POST /api/auth/login/2fa":              true,
+	"POST /api/auth/refresh":                true,
+	"POST /api/auth/verify-email":           true,
+	"POST /api/auth/password-reset/request": true,
+	"POST /api/auth/password-reset/reset":   true,
+	"POST /api/auth/email-change/confirm":   true,
+	"POST /api/auth/oidc/login":             true,
+	"POST /api/auth/oidc/callback":          true,
+}
+
+// registeredRoutes returns the "METHOD /path" of every route the server
+// registers, with OpenID Connect login enabled
+func registeredRoutes() []string {
+	gin.SetMode(gin.TestMode)
+
+	r := gin.New()
+	noop := func(c *gin.Context) {}
+	registerRoutes(r, noop, nil, nil, nil, nil, &handlers.OIDCHandler{}, nil, nil, nil)
+
+	var routes []string
+	for _, route := range r.Routes() {
+		routes = append(routes, route.Method+" "+route.Path)
+	}
+	return routes
+}
+
+// newPermissionsRouter registers every authenticated route of the server,
+// plus one route missing from routePermissions, behind the same
+// authentication and authorization middleware as the server
+func newPermissionsRouter(t *testing.T) (*gin.Engine, *services.TokenService) {
+	t.Helper()
+	gin.SetMode(gin.TestMode)
+
+	tokens := services.NewTokenService(services.NewHMACKeySet("test-secret"), "test", "test", time.Minute)
+	r := gin.New()
+	r.Use(middleware.AuthMiddleware(tokens, activeSessions{}, nil, defaultRoles{}), middleware.AuthorizeRoutes(routePermissions))
+
+	ok := func(c *gin.Context) { c.Status(http.StatusNoContent) }
+	for _, route := range registeredRoutes() {
+		if publicRoutes[route] {
+			continue
+		}
+		method, path, _ := strings.Cut(route, " ")
+		r.Handle(method, path, ok)
+	}
+	r.GET("/api/unlisted", ok)
+
+	return r, tokens
+}
+
+func request(t *testing.T, r *gin.Engine, tokens *services.TokenService, role, method, path string) int {
+	t.Helper()
+
+	user := &models.User{ID: primitive.NewObjectID(), Email: role + "@example.com", Role: role}
+	token, err := tokens.IssueAccessToken(user, primitive.NewObjectID().Hex())
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	req := httptest.NewRequest(method, path, nil)
+	req.Header.Set("Authorization", "Bearer "+token)
+	w := httptest.NewRecorder()
+	r.ServeHTTP(w, req)
+	return w.Code
+}
+
+// concretePath fills in route parameters
+func concretePath(pattern string) string {
+	segments := strings.Split(pattern, "/")
+	for i, segment := range segments {
+		if strings.HasPrefix(segment, ":") || strings.HasPrefix(segment, "*") {
+			segments[i] = primitive.NewObjectID().Hex()
+		}
+	}
+	return strings.Join(segments, "/")
+}
+
+func TestRoutePermissions(t *testing.T) {
+	r, tokens := newPermissionsRouter(t)
+	id := primitive.NewObjectID().Hex()
+
+	tests := []struct {
+		name   string
+		role   string
+		method string
+		path   string
+		want   int
+	}{
+		{"reader cannot change roles", constants.RoleReader, http.MethodPut, "/api/users/" + id + "/role", http.StatusForbidden},
+		{"author cannot change roles", constants.RoleAuthor, http.MethodPut, "/api/users/" + id + "/role", http.StatusForbidden},
+		{"editor cannot change roles", constants.RoleEditor, http.MethodPut, "/api/users/" + id + "/role", http.StatusForbidden},
+		{"admin can change roles", constants.RoleAdmin, http.MethodPut, "/api/users/" + id + "/role", http.StatusNoContent},
+		{"editor cannot define roles", constants.RoleEditor, http.MethodPost, "/api/admin/roles", http.StatusForbidden},
+		{"admin can define roles", constants.RoleAdmin, http.MethodPost, "/api/admin/roles", http.StatusNoContent},
+		{"reader cannot delete users", constants.RoleReader, http.MethodDelete, "/api/users/" + id, http.StatusForbidden},
+		{"reader cannot list users", constants.RoleReader, http.MethodGet, "/api/users", http.StatusForbidden},
+		{"reader cannot create posts", constants.RoleReader, http.MethodPost, "/api/posts", http.StatusForbidden},
+		{"author can create posts", constants.RoleAuthor, http.MethodPost, "/api/posts", http.StatusNoContent},
+		{"editor can create posts", constants.RoleEditor, http.MethodPost, "/api/posts", http.StatusNoContent},
+		{"author cannot approve posts", constants.RoleAuthor, http.MethodPost, "/api/posts/" + id + "/approve", http.StatusForbidden},
+		{"editor can approve posts", constants.RoleEditor, http.MethodPost, "/api/posts/" + id + "/approve", http.StatusNoContent},
+		{"author cannot see the review queue", constants.RoleAuthor, http.MethodGet, "/api/posts/review-queue", http.StatusForbidden},
+		{"editor can see the review queue", constants.RoleEditor, http.MethodGet, "/api/posts/review-queue", http.StatusNoContent},
+		{"reader cannot publish posts", constants.RoleReader, http.MethodPost, "/api/posts/" + id + "/publish", http.StatusForbidden},
+		{"reader can list posts", constants.RoleReader, http.MethodGet, "/api/posts", http.StatusNoContent},
+		{"reader can read a post", constants.RoleReader, http.MethodGet, "/api/posts/" + id, http.StatusNoContent},
+		{"reader cannot upload media", constants.RoleReader, http.MethodPost, "/api/media", http.StatusForbidden},
+		{"reader can update own profile", constants.RoleReader, http.MethodPut, "/api/users/profile", http.StatusNoContent},
+		{"reader can manage own API keys", constants.RoleReader, http.MethodPost, "/api/users/me/api-keys", http.StatusNoContent},
+		{"author cannot read the outbox", constants.RoleAuthor, http.MethodGet, "/api/admin/outbox", http.StatusForbidden},
+		{"editor cannot read the outbox", constants.RoleEditor, http.MethodGet, "/api/admin/outbox", http.StatusForbidden},
+		{"admin can read the outbox", constants.RoleAdmin, http.MethodGet, "/api/admin/outbox", http.StatusNoContent},
+		{"unknown role has no permissions", "ghost", http.MethodPost, "/api/posts", http.StatusForbidden},
+		{"unlisted routes are denied", constants.RoleAdmin, http.MethodGet, "/api/unlisted", http.StatusForbidden},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := request(t, r, tokens, tt.role, tt.method, tt.path); got != tt.want {
+				t.Errorf("%s %s as %s: got %d, want %d", tt.method, tt.path, tt.role, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestRoutePermissionsMatchRoutes(t *testing.T) {
+	registered := make(map[string]bool)
+	for _, route := range registeredRoutes() {
+		registered[route] = true
+		if _, listed := routePermissions[route]; !listed && !publicRoutes[route] {
+			t.Errorf("%s is registered but missing from routePermissions", route)
+		}
+	}
+
+	for route := range routePermissions {
+		if !registered[route] {
+			t.Errorf("%s is in routePermissions but not registered", route)
+		}
+		if publicRoutes[route] {
+			t.Errorf("%s is in routePermissions but served without authentication", route)
+		}
+	}
+	for route := range publicRoutes {
+		if !registered[route] {
+			t.Errorf("public route %s is not registered", route)
+		}
+	}
+}
+
+func TestEveryRouteRequiresItsPermission(t *testing.T) {
+	r, tokens := newPermissionsRouter(t)
+
+	for route, required := range routePermissions {
+		if !isKnownPermission(required) {
+			t.Errorf("%s: unknown permission %q", route, required)
+			continue
+		}
+
+		method, pattern, _ := strings.Cut(route, " ")
+		for _, role := range constants.BuiltInRoles {
+			want := http.StatusForbidden
+			if required == constants.PermAuthenticated || roleHas(role, required) {
+				want = http.StatusNoContent
+			}
+
+			if got := request(t, r, tokens, role, method, concretePath(pattern)); got != want {
+				t.Errorf("%s as %s: got %d, want %d", route, role, got, want)
+			}
+		}
+	}
+}
+
+func isKnownPermission(permission string) bool {
+	return permission == constants.PermAuthenticated || roleHas(constants.RoleAdmin, permission)
+}
+
+func roleHas(role, permission string) bool {
+	for _, p := range constants.DefaultRolePermissions[role] {
+		if p == permission {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,97 @@
+package main
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+
+	"go-blog-platform/config"
+	"go-blog-platform/internal/services"
+)
+
+// newThrottledRouter serves a login route that fails every attempt, counted
+// by the client IP as the login handler does
+func newThrottledRouter(t *testing.T, cfg config.ServerConfig, maxIPFailures int) *gin.Engine {
+	t.Helper()
+	gin.SetMode(gin.TestMode)
+
+	r, err := newRouter(cfg)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	throttle := services.NewLoginThrottle(services.NewMemoryLoginAttemptStore(), services.LoginThrottleConfig{
+		MaxAccountFailures: 100,
+		MaxIPFailures:      maxIPFailures,
+		LockoutDuration:    time.Hour,
+		Window:             time.Hour,
+	})
+	r.POST("/login", func(c *gin.Context) {
+		email := c.Query("email")
+		if _, err := throttle.Check(context.Background(), email, c.ClientIP()); err != nil {
+			c.Status(http.StatusTooManyRequests)
+			return
+		}
+		if _, err := throttle.RecordFailure(context.Background(), email, c.ClientIP()); err != nil {
+			t.Fatal(err)
+		}
+		c.Status(http.StatusUnauthorized)
+	})
+	return r
+}
+
+func login(r *gin.Engine, email, remoteAddr, forwardedFor string) int {
+	req := httptest.NewRequest(http.MethodPost, "/login?email="+email, nil)
+	req.RemoteAddr = remoteAddr
+	if forwardedFor != "" {
+		req.Header.Set("X-Forwarded-For", forwardedFor)
+	}
+	w := httptest.NewRecorder()
+	r.ServeHTTP(w, req)
+	return w.Code
+}
+
+func TestLoginThrottleIgnoresSpoofedForwardedFor(t *testing.T) {
+	r := newThrottledRouter(t, config.ServerConfig{}, 3)
+
+	// A fresh forwarded IP on every attempt still counts against the sender
+	spoofed := []string{"198.51.100.1", "198.51.100.2", "198.51.100.3"}
+	for i, ip := range spoofed {
+		if got := login(r, "user"+ip+"@example.com", "192.0.2.1:1234", ip); got != http.StatusUnauthorized {
+			t.Fatalf("attempt %d: got %d, want 401", i+1, got)
+		}
+	}
+	if got := login(r, "next@example.com", "192.0.2.1:1234", "198.51.100.4"); got != http.StatusTooManyRequests {
+		t.Errorf("attempt from a locked IP with a new forwarded IP: got %d, want 429", got)
+	}
+
+	// and the IP named in the header is not locked out
+	if got := login(r, "victim@example.com", "198.51.100.1:1234", ""); got != http.StatusUnauthorized {
+		t.Errorf("attempt from the spoofed IP: got %d, want 401", got)
+	}
+}
+
+func TestLoginThrottleTrustedProxy(t *testing.T) {
+	r := newThrottledRouter(t, config.ServerConfig{TrustedProxies: []string{"10.0.0.0/8"}}, 1)
+
+	// Behind a trusted proxy the forwarded IP is the client's
+	if got := login(r, "ann@example.com", "10.0.0.5:1234", "198.51.100.1"); got != http.StatusUnauthorized {
+		t.Fatalf("first attempt: got %d, want 401", got)
+	}
+	if got := login(r, "bob@example.com", "10.0.0.5:1234", "198.51.100.2"); got != http.StatusUnauthorized {
+		t.Errorf("attempt from another client behind the proxy: got %d, want 401", got)
+	}
+	if got := login(r, "carol@example.com", "10.0.0.6:1234", "198.51.100.1"); got != http.StatusTooManyRequests {
+		t.Errorf("attempt from the locked client: got %d, want 429", got)
+	}
+}
+
+func TestNewRouterRejectsInvalidProxies(t *testing.T) {
+	if _, err := newRouter(config.ServerConfig{TrustedProxies: []string{"not an address"}}); err == nil {
+		t.Error("newRouter accepted an invalid trusted proxy")
+	}
+}
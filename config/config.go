@@ -2,18 +2,30 @@ package config
 
 import (
     "os"
+    "strconv"
+    "strings"
+    "time"
 )
 
 type Config struct {
     Server   ServerConfig
     MongoDB  MongoDBConfig
     JWT      JWTConfig
+    Auth     AuthConfig
+    OIDC     OIDCConfig
     SMTP     SMTPConfig
+    Posts    PostsConfig
+    HTML     HTMLConfig
     BaseURL  string
+    SiteName string
 }
 
 type ServerConfig struct {
     Port string
+    // TrustedProxies are the addresses or CIDR ranges of reverse proxies
+    // whose X-Forwarded-For header gives the client IP. With none, the
+    // header is ignored.
+    TrustedProxies []string
 }
 
 type MongoDBConfig struct {
@@ -22,37 +34,150 @@ type MongoDBConfig struct {
 }
 
 type JWTConfig struct {
-    Secret string
+    Algorithm            string
+    Secret               string
+    SigningKeyFile       string
+    VerificationKeyFiles []string
+    Issuer               string
+    Audience             string
+    AccessTokenTTL       time.Duration
+    RefreshTokenTTL      time.Duration
+}
+
+type AuthConfig struct {
+    TwoFactorRequiredRole string
+
+    PasswordMinLength           int
+    PasswordMinCharacterClasses int
+    PasswordBreachedHashesFile  string
+
+    LoginAttemptStore     string
+    LoginMaxFailures      int
+    LoginMaxFailuresPerIP int
+    LoginLockoutDuration  time.Duration
+    LoginAttemptWindow    time.Duration
+    LoginBaseDelay        time.Duration
+    LoginMaxDelay         time.Duration
+}
+
+// OIDCConfig configures login through an OpenID Connect provider. OIDC is
+// disabled when IssuerURL is empty.
+type OIDCConfig struct {
+    IssuerURL    string
+    ClientID     string
+    ClientSecret string
+    RedirectURL  string
+    Scopes       []string
+    GroupsClaim  string
+    RoleMapping  map[string]string
+    DefaultRole  string
+    AllowSignup  bool
+    LinkByEmail  bool
+}
+
+type PostsConfig struct {
+    // SchedulerInterval is how often scheduled posts are published and
+    // expired posts archived
+    SchedulerInterval time.Duration
+}
+
+// HTMLConfig configures the sanitizer applied to user-written HTML
+type HTMLConfig struct {
+    SanitizerPolicy   string
+    AllowedElements   []string
+    AllowedURLSchemes []string
 }
 
 type SMTPConfig struct {
-    Host     string
-    Port     string
-    Username string
-    Password string
-    FromEmail string
+    Transport    string
+    Host         string
+    Port         string
+    Username     string
+    Password     string
+    TLSMode      string
+    FromEmail    string
+    FromName     string
+    MailDir      string
+    TemplatesDir string
+
+    OutboxMaxAttempts  int
+    OutboxPollInterval time.Duration
 }
 
 func LoadConfig() *Config {
+    baseURL := getEnvOrDefault("BASE_URL", "http://localhost:8080")
+
     return &Config{
         Server: ServerConfig{
-            Port: getEnvOrDefault("SERVER_PORT", "8080"),
+            Port:           getEnvOrDefault("SERVER_PORT", "8080"),
+            TrustedProxies: getListOrDefault("TRUSTED_PROXIES", nil),
         },
         MongoDB: MongoDBConfig{
             URI:      getEnvOrDefault("MONGODB_URI", "mongodb://localhost:27017"),
             Database: getEnvOrDefault("MONGODB_DATABASE", "blog_platform"),
         },
         JWT: JWTConfig{
-            Secret: getEnvOrDefault("JWT_SECRET", "your-256-bit-secret"),
+            Algorithm:            getEnvOrDefault("JWT_ALGORITHM", "HS256"),
+            Secret:               getEnvOrDefault("JWT_SECRET", "your-256-bit-secret"),
+            SigningKeyFile:       getEnvOrDefault("JWT_SIGNING_KEY_FILE", ""),
+            VerificationKeyFiles: getListOrDefault("JWT_VERIFICATION_KEY_FILES", nil),
+            Issuer:               getEnvOrDefault("JWT_ISSUER", "go-blog-platform"),
+            Audience:             getEnvOrDefault("JWT_AUDIENCE", "go-blog-platform-api"),
+            AccessTokenTTL:       getDurationOrDefault("JWT_ACCESS_TOKEN_TTL", 15*time.Minute),
+            RefreshTokenTTL:      getDurationOrDefault("JWT_REFRESH_TOKEN_TTL", 30*24*time.Hour),
+        },
+        Auth: AuthConfig{
+            TwoFactorRequiredRole: getEnvOrDefault("TWO_FACTOR_REQUIRED_ROLE", ""),
+
+            PasswordMinLength:           getIntOrDefault("PASSWORD_MIN_LENGTH", 10),
+            PasswordMinCharacterClasses: getIntOrDefault("PASSWORD_MIN_CHARACTER_CLASSES", 3),
+            PasswordBreachedHashesFile:  getEnvOrDefault("PASSWORD_BREACHED_HASHES_FILE", ""),
+
+            LoginAttemptStore:     getEnvOrDefault("LOGIN_ATTEMPT_STORE", "mongo"),
+            LoginMaxFailures:      getIntOrDefault("LOGIN_MAX_FAILURES", 5),
+            LoginMaxFailuresPerIP: getIntOrDefault("LOGIN_MAX_FAILURES_PER_IP", 50),
+            LoginLockoutDuration:  getDurationOrDefault("LOGIN_LOCKOUT_DURATION", 15*time.Minute),
+            LoginAttemptWindow:    getDurationOrDefault("LOGIN_ATTEMPT_WINDOW", 15*time.Minute),
+            LoginBaseDelay:        getDurationOrDefault("LOGIN_BASE_DELAY", time.Second),
+            LoginMaxDelay:         getDurationOrDefault("LOGIN_MAX_DELAY", 30*time.Second),
+        },
+        OIDC: OIDCConfig{
+            IssuerURL:    getEnvOrDefault("OIDC_ISSUER_URL", ""),
+            ClientID:     getEnvOrDefault("OIDC_CLIENT_ID", ""),
+            ClientSecret: getEnvOrDefault("OIDC_CLIENT_SECRET", ""),
+            RedirectURL:  getEnvOrDefault("OIDC_REDIRECT_URL", baseURL+"/oidc/callback"),
+            Scopes:       getListOrDefault("OIDC_SCOPES", []string{"openid", "email", "profile"}),
+            GroupsClaim:  getEnvOrDefault("OIDC_GROUPS_CLAIM", "groups"),
+            RoleMapping:  getMapOrDefault("OIDC_ROLE_MAPPING", nil),
+            DefaultRole:  getEnvOrDefault("OIDC_DEFAULT_ROLE", "reader"),
+            AllowSignup:  getBoolOrDefault("OIDC_ALLOW_SIGNUP", true),
+            LinkByEmail:  getBoolOrDefault("OIDC_LINK_BY_EMAIL", false),
         },
         SMTP: SMTPConfig{
-            Host:     getEnvOrDefault("SMTP_HOST", "smtp.gmail.com"),
-            Port:     getEnvOrDefault("SMTP_PORT", "587"),
-            Username: getEnvOrDefault("SMTP_USERNAME", ""),
-            Password: getEnvOrDefault("SMTP_PASSWORD", ""),
-            FromEmail: getEnvOrDefault("SMTP_FROM_EMAIL", "noreply@yourblog.com"),
+            Transport:    getEnvOrDefault("MAIL_TRANSPORT", "smtp"),
+            Host:         getEnvOrDefault("SMTP_HOST", "smtp.gmail.com"),
+            Port:         getEnvOrDefault("SMTP_PORT", "587"),
+            Username:     getEnvOrDefault("SMTP_USERNAME", ""),
+            Password:     getEnvOrDefault("SMTP_PASSWORD", ""),
+            TLSMode:      getEnvOrDefault("SMTP_TLS_MODE", "starttls"),
+            FromEmail:    getEnvOrDefault("SMTP_FROM_EMAIL", "noreply@yourblog.com"),
+            FromName:     getEnvOrDefault("SMTP_FROM_NAME", "Go Blog Platform"),
+            MailDir:      getEnvOrDefault("MAIL_DIR", "mail"),
+            TemplatesDir: getEnvOrDefault("MAIL_TEMPLATES_DIR", ""),
+
+            OutboxMaxAttempts:  getIntOrDefault("MAIL_OUTBOX_MAX_ATTEMPTS", 8),
+            OutboxPollInterval: getDurationOrDefault("MAIL_OUTBOX_POLL_INTERVAL", 5*time.Second),
         },
-        BaseURL: getEnvOrDefault("BASE_URL", "http://localhost:8080"),
+        Posts: PostsConfig{
+            SchedulerInterval: getDurationOrDefault("POST_SCHEDULER_INTERVAL", 30*time.Second),
+        },
+        HTML: HTMLConfig{
+            SanitizerPolicy:   getEnvOrDefault("HTML_SANITIZER_POLICY", "ugc"),
+            AllowedElements:   getListOrDefault("HTML_ALLOWED_ELEMENTS", nil),
+            AllowedURLSchemes: getListOrDefault("HTML_ALLOWED_URL_SCHEMES", nil),
+        },
+        BaseURL:  baseURL,
+        SiteName: getEnvOrDefault("SITE_NAME", "Go Blog Platform"),
     }
 }
 
@@ -62,3 +187,63 @@ func getEnvOrDefault(key, defaultValue string) string {
     }
     return defaultValue
 }
+
+func getDurationOrDefault(key string, defaultValue time.Duration) time.Duration {
+    if value := os.Getenv(key); value != "" {
+        if d, err := time.ParseDuration(value); err == nil {
+            return d
+        }
+    }
+    return defaultValue
+}
+
+func getIntOrDefault(key string, defaultValue int) int {
+    if value := os.Getenv(key); value != "" {
+        if n, err := strconv.Atoi(value); err == nil && n > 0 {
+            return n
+        }
+    }
+    return defaultValue
+}
+
+func getListOrDefault(key string, defaultValue []string) []string {
+    value := os.Getenv(key)
+    if value == "" {
+        return defaultValue
+    }
+
+    var items []string
+    for _, item := range strings.Split(value, ",") {
+        if item = strings.TrimSpace(item); item != "" {
+            items = append(items, item)
+        }
+    }
+    return items
+}
+
+func getBoolOrDefault(key string, defaultValue bool) bool {
+    if value := os.Getenv(key); value != "" {
+        if b, err := strconv.ParseBool(value); err == nil {
+            return b
+        }
+    }
+    return defaultValue
+}
+
+// getMapOrDefault parses a comma-separated list of key=value pairs
+func getMapOrDefault(key string, defaultValue map[string]string) map[string]string {
+    value := os.Getenv(key)
+    if value == "" {
+        return defaultValue
+    }
+
+    items := make(map[string]string)
+    for _, pair := range strings.Split(value, ",") {
+        k, v, ok := strings.Cut(pair, "=")
+        k, v = strings.TrimSpace(k), strings.TrimSpace(v)
+        if ok && k != "" && v != "" {
+            items[k] = v
+        }
+    }
+    return items
+}
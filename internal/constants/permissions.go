@@ -0,0 +1,43 @@
+package constants
+
+// Permissions that can be granted to roles
+const (
+    // PermPostCreate allows writing posts and changing one's own posts
+    PermPostCreate = "post.create"
+    // PermPostPublish allows publishing approved posts one may edit
+    PermPostPublish = "post.publish"
+    // PermPostReview allows approving and rejecting other users' posts
+    PermPostReview = "post.review"
+    // PermPostEditAny allows changing and deleting anyone's posts
+    PermPostEditAny = "post.edit_any"
+    // PermCommentModerate allows hiding and deleting comments
+    PermCommentModerate = "comment.moderate"
+    // PermMediaUpload allows uploading media and deleting one's own uploads
+    PermMediaUpload = "media.upload"
+    // PermMediaManageAny allows changing and deleting anyone's media
+    PermMediaManageAny = "media.manage_any"
+    // PermUserManage allows managing other users' accounts and sessions
+    PermUserManage = "user.manage"
+    // PermRoleManage allows defining roles and assigning them to users
+    PermRoleManage = "role.manage"
+    // PermSettingsManage allows changing site settings such as the 2FA policy
+    PermSettingsManage = "settings.manage"
+)
+
+// PermAuthenticated marks routes in a route permission table that every
+// signed-in user may call, whatever their role
+const PermAuthenticated = "authenticated"
+
+// AllPermissions lists every permission a role can be granted
+var AllPermissions = []string{
+    PermPostCreate,
+    PermPostPublish,
+    PermPostReview,
+    PermPostEditAny,
+    PermCommentModerate,
+    PermMediaUpload,
+    PermMediaManageAny,
+    PermUserManage,
+    PermRoleManage,
+    PermSettingsManage,
+}
@@ -0,0 +1,16 @@
+package constants
+
+// Roles a user can have on a single post
+const (
+    // PostRoleAuthor is the user who created the post
+    PostRoleAuthor = "author"
+    // PostRoleCoAuthor can edit the post and is credited as one of its authors
+    PostRoleCoAuthor = "co-author"
+    // PostRoleEditor can edit the post without being credited
+    PostRoleEditor = "editor"
+    // PostRoleViewer has read-only access to the post
+    PostRoleViewer = "viewer"
+)
+
+// CollaboratorRoles lists the roles authors can give collaborators
+var CollaboratorRoles = []string{PostRoleCoAuthor, PostRoleEditor, PostRoleViewer}
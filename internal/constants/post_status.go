@@ -0,0 +1,12 @@
+package constants
+
+// Post statuses. Posts move between them through the review workflow.
+const (
+    PostStatusDraft     = "draft"
+    PostStatusInReview  = "in_review"
+    PostStatusApproved  = "approved"
+    PostStatusRejected  = "rejected"
+    PostStatusScheduled = "scheduled"
+    PostStatusPublished = "published"
+    PostStatusArchived  = "archived"
+)
@@ -1,18 +1,24 @@
 package constants
 
-// User roles
+// Built-in roles. Admins can define more roles through the API.
 const (
     RoleAdmin  = "admin"
+    RoleEditor = "editor"
     RoleAuthor = "author"
     RoleReader = "reader"
 )
 
-// ValidRoles is a list of all valid user roles
-var ValidRoles = []string{RoleAdmin, RoleAuthor, RoleReader}
+// BuiltInRoles are created on startup and cannot be deleted
+var BuiltInRoles = []string{RoleAdmin, RoleEditor, RoleAuthor, RoleReader}
 
-// RoleHierarchy defines which roles have access to other roles' permissions
-var RoleHierarchy = map[string][]string{
-    RoleAdmin:  {RoleAdmin, RoleAuthor, RoleReader},
-    RoleAuthor: {RoleAuthor, RoleReader},
-    RoleReader: {RoleReader},
+// DefaultRolePermissions are the permissions the built-in roles start with.
+// Admins always hold every permission.
+var DefaultRolePermissions = map[string][]string{
+    RoleAdmin: AllPermissions,
+    RoleEditor: {
+        PermPostCreate, PermPostPublish, PermPostReview, PermPostEditAny,
+        PermCommentModerate, PermMediaUpload, PermMediaManageAny,
+    },
+    RoleAuthor: {PermPostCreate, PermPostPublish, PermMediaUpload},
+    RoleReader: {},
 }
@@ -0,0 +1,10 @@
+package constants
+
+// API key scopes
+const (
+    ScopePostsWrite = "posts:write"
+    ScopeMediaWrite = "media:write"
+)
+
+// ValidScopes is a list of all scopes an API key can be limited to
+var ValidScopes = []string{ScopePostsWrite, ScopeMediaWrite}
@@ -0,0 +1,289 @@
+package handlers
+
+import (
+    "context"
+    "fmt"
+    "log"
+    "net/http"
+    "time"
+
+    "github.com/gin-gonic/gin"
+    "go.mongodb.org/mongo-driver/bson"
+    "go.mongodb.org/mongo-driver/bson/primitive"
+    "go.mongodb.org/mongo-driver/mongo"
+    "go.mongodb.org/mongo-driver/mongo/options"
+    "golang.org/x/crypto/bcrypt"
+
+    "go-blog-platform/internal/middleware"
+    "go-blog-platform/internal/models"
+    "go-blog-platform/internal/services"
+)
+
+const emailChangeTokenTTL = 24 * time.Hour
+
+type ChangePasswordRequest struct {
+    CurrentPassword string `json:"current_password" binding:"required"`
+    NewPassword     string `json:"new_password" binding:"required"`
+}
+
+type ChangeEmailRequest struct {
+    NewEmail        string `json:"new_email" binding:"required,email"`
+    CurrentPassword string `json:"current_password" binding:"required"`
+}
+
+type ConfirmEmailChangeRequest struct {
+    Token string `json:"token" binding:"required"`
+}
+
+// ChangePassword sets a new password for the current user, given the current
+// one, and signs the user out everywhere else
+func (h *UserHandler) ChangePassword(c *gin.Context) {
+    var req ChangePasswordRequest
+    if err := c.ShouldBindJSON(&req); err != nil {
+        c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+        return
+    }
+
+    user, ok := h.currentUser(c)
+    if !ok {
+        return
+    }
+
+    if !h.checkCurrentPassword(c, user, req.CurrentPassword) {
+        return
+    }
+
+    if !h.checkPasswordPolicy(c, req.NewPassword, user.Username, user.Email) {
+        return
+    }
+
+    hashedPassword, err := bcrypt.GenerateFromPassword([]byte(req.NewPassword), bcrypt.DefaultCost)
+    if err != nil {
+        c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to hash password"})
+        return
+    }
+
+    ctx := context.Background()
+    _, err = h.collection.UpdateOne(
+        ctx,
+        bson.M{"_id": user.ID},
+        bson.M{"$set": bson.M{
+            "password":   string(hashedPassword),
+            "updated_at": time.Now(),
+        }},
+    )
+    if err != nil {
+        c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to update password"})
+        return
+    }
+
+    principal, _ := middleware.CurrentPrincipal(c)
+    h.revokeOtherSessions(ctx, user.ID, principal.SessionID)
+
+    // Reset links sent earlier would undo the change
+    if err := h.passwordResets.InvalidateAll(ctx, user.ID); err != nil {
+        log.Printf("Failed to invalidate password reset tokens for user %s: %v", user.ID.Hex(), err)
+    }
+
+    err = h.emailService.Send(user.Email, user.Locale, services.TemplatePasswordChanged, services.TemplateData{
+        "Username":  user.Username,
+        "IPAddress": c.ClientIP(),
+        "Link":      h.baseURL + "/reset-password",
+    })
+    if err != nil {
+        log.Printf("Failed to send password change email to user %s: %v", user.ID.Hex(), err)
+    }
+
+    c.JSON(http.StatusOK, gin.H{"message": "Password changed successfully"})
+}
+
+// RequestEmailChange sends a confirmation link to the new address. The
+// account keeps its current address until the link is followed.
+func (h *UserHandler) RequestEmailChange(c *gin.Context) {
+    var req ChangeEmailRequest
+    if err := c.ShouldBindJSON(&req); err != nil {
+        c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+        return
+    }
+
+    user, ok := h.currentUser(c)
+    if !ok {
+        return
+    }
+
+    if !h.checkCurrentPassword(c, user, req.CurrentPassword) {
+        return
+    }
+
+    if req.NewEmail == user.Email {
+        c.JSON(http.StatusBadRequest, gin.H{"error": "New email is the same as the current one"})
+        return
+    }
+
+    ctx := context.Background()
+    count, err := h.collection.CountDocuments(ctx, bson.M{"email": req.NewEmail})
+    if err != nil {
+        c.JSON(http.StatusInternalServerError, gin.H{"error": "Database error"})
+        return
+    }
+    if count > 0 {
+        c.JSON(http.StatusConflict, gin.H{"error": "Email already exists"})
+        return
+    }
+
+    // Only the latest request can be confirmed
+    principal, _ := middleware.CurrentPrincipal(c)
+    _, err = h.collection.UpdateOne(ctx, bson.M{"_id": user.ID}, bson.M{
+        "$set": bson.M{"email_change": models.EmailChange{
+            NewEmail:    req.NewEmail,
+            SessionID:   principal.SessionID,
+            RequestedAt: time.Now(),
+        }},
+    })
+    if err != nil {
+        c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to request email change"})
+        return
+    }
+
+    token, err := h.tokenService.IssueActionToken(services.PurposeEmailChange, user.ID, req.NewEmail, emailChangeTokenTTL)
+    if err != nil {
+        c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to generate confirmation link"})
+        return
+    }
+
+    err = h.emailService.Send(req.NewEmail, user.Locale, services.TemplateEmailChange, services.TemplateData{
+        "Username":       user.Username,
+        "NewEmail":       req.NewEmail,
+        "Link":           fmt.Sprintf("%s/confirm-email-change?token=%s", h.baseURL, token),
+        "ExpiresInHours": int(emailChangeTokenTTL.Hours()),
+    })
+    if err != nil {
+        c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to send confirmation email"})
+        return
+    }
+
+    c.JSON(http.StatusOK, gin.H{"message": "Confirmation link sent to the new email address"})
+}
+
+// ConfirmEmailChange switches the account to the new address from a
+// confirmation link, tells the old address and signs the user out on every
+// other device
+func (h *UserHandler) ConfirmEmailChange(c *gin.Context) {
+    var req ConfirmEmailChangeRequest
+    if err := c.ShouldBindJSON(&req); err != nil {
+        c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+        return
+    }
+
+    claims, err := h.tokenService.ParseActionToken(services.PurposeEmailChange, req.Token)
+    if err != nil {
+        c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid or expired confirmation link"})
+        return
+    }
+
+    userID, err := primitive.ObjectIDFromHex(claims.Subject)
+    if err != nil {
+        c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid or expired confirmation link"})
+        return
+    }
+
+    // The address may have been taken since the change was requested
+    ctx := context.Background()
+    count, err := h.collection.CountDocuments(ctx, bson.M{"email": claims.Email, "_id": bson.M{"$ne": userID}})
+    if err != nil {
+        c.JSON(http.StatusInternalServerError, gin.H{"error": "Database error"})
+        return
+    }
+    if count > 0 {
+        c.JSON(http.StatusConflict, gin.H{"error": "Email already exists"})
+        return
+    }
+
+    now := time.Now()
+    var user models.User
+    err = h.collection.FindOneAndUpdate(
+        ctx,
+        bson.M{"_id": userID, "email_change.new_email": claims.Email},
+        bson.M{
+            "$set": bson.M{
+                "email":             claims.Email,
+                "email_verified":    true,
+                "email_verified_at": now,
+                "updated_at":        now,
+            },
+            "$unset": bson.M{"email_change": ""},
+        },
+        options.FindOneAndUpdate().SetReturnDocument(options.Before),
+    ).Decode(&user)
+    if err == mongo.ErrNoDocuments {
+        c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid or expired confirmation link"})
+        return
+    }
+    if err != nil {
+        c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to change email"})
+        return
+    }
+
+    h.revokeOtherSessions(ctx, user.ID, user.EmailChange.SessionID)
+
+    // Reset links went to the old address
+    if err := h.passwordResets.InvalidateAll(ctx, user.ID); err != nil {
+        log.Printf("Failed to invalidate password reset tokens for user %s: %v", user.ID.Hex(), err)
+    }
+
+    err = h.emailService.Send(user.Email, user.Locale, services.TemplateEmailChanged, services.TemplateData{
+        "Username": user.Username,
+        "NewEmail": claims.Email,
+        "Link":     h.baseURL + "/reset-password",
+    })
+    if err != nil {
+        log.Printf("Failed to send email change notice to user %s: %v", user.ID.Hex(), err)
+    }
+
+    c.JSON(http.StatusOK, gin.H{"message": "Email changed successfully"})
+}
+
+// checkCurrentPassword writes an error response and returns false if the
+// password is not the user's current one
+func (h *UserHandler) checkCurrentPassword(c *gin.Context, user *models.User, password string) bool {
+    // Accounts created through single sign-on have no password to compare
+    if user.Password == "" || user.ComparePassword(password) != nil {
+        c.JSON(http.StatusUnauthorized, gin.H{"error": "Current password is incorrect"})
+        return false
+    }
+    return true
+}
+
+// revokeOtherSessions signs the user out of every session but the given one
+func (h *UserHandler) revokeOtherSessions(ctx context.Context, userID primitive.ObjectID, keepSessionID string) {
+    var err error
+    if keep, parseErr := primitive.ObjectIDFromHex(keepSessionID); parseErr == nil {
+        _, err = h.sessionService.RevokeOthers(ctx, userID, keep)
+    } else {
+        _, err = h.sessionService.RevokeAll(ctx, userID)
+    }
+    if err != nil {
+        log.Printf("Failed to revoke sessions for user %s: %v", userID.Hex(), err)
+    }
+}
+
+// checkPasswordPolicy responds with the broken rules and returns false if the
+// password may not be used
+func (h *UserHandler) checkPasswordPolicy(c *gin.Context, password, username, email string) bool {
+    err := h.passwordPolicy.Validate(password, username, email)
+    if err == nil {
+        return true
+    }
+
+    if policyErr, ok := err.(*services.PasswordPolicyError); ok {
+        c.JSON(http.StatusBadRequest, gin.H{
+            "error":    "Password does not meet the password policy",
+            "problems": policyErr.Problems,
+        })
+        return false
+    }
+
+    log.Printf("Failed to check password against the policy: %v", err)
+    c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to check password"})
+    return false
+}
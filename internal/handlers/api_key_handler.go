@@ -0,0 +1,132 @@
+package handlers
+
+import (
+	"context"
+	"net/http"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+
+	"go-blog-platform/internal/constants"
+	"go-blog-platform/internal/middleware"
+	"go-blog-platform/internal/services"
+)
+
+type APIKeyHandler struct {
+	apiKeys *services.APIKeyService
+}
+
+type CreateAPIKeyRequest struct {
+	Name      string     `json:"name" binding:"required,max=100"`
+	Scopes    []string   `json:"scopes"`
+	ExpiresAt *time.Time `json:"expires_at"`
+}
+
+func NewAPIKeyHandler(apiKeys *services.APIKeyService) *APIKeyHandler {
+	return &APIKeyHandler{
+		apiKeys: apiKeys,
+	}
+}
+
+// List returns the current user's API keys
+func (h *APIKeyHandler) List(c *gin.Context) {
+	principal, exists := middleware.CurrentPrincipal(c)
+	if !exists {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "User not authenticated"})
+		return
+	}
+
+	keys, err := h.apiKeys.List(context.Background(), principal.UserID)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch API keys"})
+		return
+	}
+
+	c.JSON(http.StatusOK, keys)
+}
+
+// Create issues a new API key for the current user. The key is only returned
+// in this response.
+func (h *APIKeyHandler) Create(c *gin.Context) {
+	var req CreateAPIKeyRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	principal, exists := middleware.CurrentPrincipal(c)
+	if !exists {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "User not authenticated"})
+		return
+	}
+
+	scopes := []string{}
+	seen := make(map[string]bool)
+	for _, scope := range req.Scopes {
+		if !isValidScope(scope) {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid scope: " + scope})
+			return
+		}
+		if !seen[scope] {
+			seen[scope] = true
+			scopes = append(scopes, scope)
+		}
+	}
+
+	if req.ExpiresAt != nil && !req.ExpiresAt.After(time.Now()) {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "expires_at must be in the future"})
+		return
+	}
+
+	key, secret, err := h.apiKeys.Create(context.Background(), principal.UserID, req.Name, scopes, req.ExpiresAt)
+	if err == services.ErrAPIKeyLimitReached {
+		c.JSON(http.StatusConflict, gin.H{"error": "API key limit reached, delete an unused key first"})
+		return
+	}
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to create API key"})
+		return
+	}
+
+	c.JSON(http.StatusCreated, gin.H{
+		"api_key": key,
+		"key":     secret,
+	})
+}
+
+// Delete revokes one of the current user's API keys
+func (h *APIKeyHandler) Delete(c *gin.Context) {
+	keyID, err := primitive.ObjectIDFromHex(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid API key ID"})
+		return
+	}
+
+	principal, exists := middleware.CurrentPrincipal(c)
+	if !exists {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "User not authenticated"})
+		return
+	}
+
+	err = h.apiKeys.Delete(context.Background(), principal.UserID, keyID)
+	if err == services.ErrAPIKeyNotFound {
+		c.JSON(http.StatusNotFound, gin.H{"error": "API key not found"})
+		return
+	}
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to delete API key"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"message": "API key deleted successfully"})
+}
+
+func isValidScope(scope string) bool {
+	for _, s := range constants.ValidScopes {
+		if s == scope {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,365 @@
+package handlers
+
+import (
+	"context"
+	"log"
+	"net/http"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+
+	"go-blog-platform/internal/constants"
+	"go-blog-platform/internal/middleware"
+	"go-blog-platform/internal/models"
+	"go-blog-platform/internal/services"
+)
+
+// AddCollaboratorRequest names the user by username, which posts already show
+// as their authors' names; inviting by email would reveal which addresses
+// have accounts
+type AddCollaboratorRequest struct {
+	Username string `json:"username" binding:"required"`
+	Role     string `json:"role" binding:"required,oneof=co-author editor viewer"`
+}
+
+type UpdateCollaboratorRequest struct {
+	Role string `json:"role" binding:"required,oneof=co-author editor viewer"`
+}
+
+// ListCollaborators returns the collaborators of a post to those who may
+// view it in any status
+func (h *PostHandler) ListCollaborators(c *gin.Context) {
+	post, principal, ok := h.loadPost(c)
+	if !ok {
+		return
+	}
+
+	if !canViewPost(principal, post) {
+		c.JSON(http.StatusForbidden, gin.H{"error": "You are not a collaborator on this post"})
+		return
+	}
+
+	ctx := context.Background()
+	users, err := h.findUsers(ctx, collaboratorIDs(post))
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch collaborators"})
+		return
+	}
+
+	collaborators := []models.Collaborator{}
+	for _, collaborator := range post.Collaborators {
+		collaborator.Username = users[collaborator.UserID].Username
+		collaborators = append(collaborators, collaborator)
+	}
+
+	c.JSON(http.StatusOK, collaborators)
+}
+
+// AddCollaborator gives an existing user a role on the post and emails them
+// a link to it
+func (h *PostHandler) AddCollaborator(c *gin.Context) {
+	var req AddCollaboratorRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	post, principal, ok := h.loadPost(c)
+	if !ok || !h.canManageCollaborators(c, principal, post) {
+		return
+	}
+
+	ctx := context.Background()
+	var user models.User
+	err := h.users.FindOne(ctx, bson.M{"username": req.Username}).Decode(&user)
+	if err == mongo.ErrNoDocuments {
+		c.JSON(http.StatusNotFound, gin.H{"error": "User not found"})
+		return
+	}
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch user"})
+		return
+	}
+
+	if user.ID == post.AuthorID {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "The author cannot be a collaborator"})
+		return
+	}
+
+	collaborator := models.Collaborator{
+		UserID:  user.ID,
+		Role:    req.Role,
+		AddedBy: principal.UserID,
+		AddedAt: time.Now(),
+	}
+	result, err := h.collection.UpdateOne(
+		ctx,
+		bson.M{"_id": post.ID, "collaborators.user_id": bson.M{"$ne": user.ID}},
+		bson.M{
+			"$push": bson.M{"collaborators": collaborator},
+			"$set":  bson.M{"updated_at": time.Now()},
+		},
+	)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to add collaborator"})
+		return
+	}
+	if result.MatchedCount == 0 {
+		c.JSON(http.StatusConflict, gin.H{"error": "User is already a collaborator"})
+		return
+	}
+
+	inviter, err := h.findUsers(ctx, []primitive.ObjectID{principal.UserID})
+	if err != nil {
+		log.Printf("Failed to fetch inviter %s: %v", principal.UserID.Hex(), err)
+	}
+	err = h.emailService.Send(user.Email, user.Locale, services.TemplateCollaboratorInvite, services.TemplateData{
+		"Username":    user.Username,
+		"InviterName": inviter[principal.UserID].Username,
+		"PostTitle":   post.Title,
+		"Role":        req.Role,
+		"Link":        h.baseURL + "/posts/" + post.ID.Hex(),
+	})
+	if err != nil {
+		log.Printf("Failed to send collaborator invite to user %s: %v", user.ID.Hex(), err)
+	}
+
+	collaborator.Username = user.Username
+	c.JSON(http.StatusCreated, collaborator)
+}
+
+// UpdateCollaborator changes a collaborator's role on the post
+func (h *PostHandler) UpdateCollaborator(c *gin.Context) {
+	var req UpdateCollaboratorRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	userID, err := primitive.ObjectIDFromHex(c.Param("user_id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid user ID"})
+		return
+	}
+
+	post, principal, ok := h.loadPost(c)
+	if !ok || !h.canManageCollaborators(c, principal, post) {
+		return
+	}
+
+	result, err := h.collection.UpdateOne(
+		context.Background(),
+		bson.M{"_id": post.ID, "collaborators.user_id": userID},
+		bson.M{"$set": bson.M{
+			"collaborators.$.role": req.Role,
+			"updated_at":           time.Now(),
+		}},
+	)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to update collaborator"})
+		return
+	}
+	if result.MatchedCount == 0 {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Collaborator not found"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"message": "Collaborator updated successfully"})
+}
+
+// RemoveCollaborator takes a collaborator off the post. Collaborators may
+// remove themselves.
+func (h *PostHandler) RemoveCollaborator(c *gin.Context) {
+	userID, err := primitive.ObjectIDFromHex(c.Param("user_id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid user ID"})
+		return
+	}
+
+	post, principal, ok := h.loadPost(c)
+	if !ok {
+		return
+	}
+	if principal.UserID != userID && !h.canManageCollaborators(c, principal, post) {
+		return
+	}
+
+	result, err := h.collection.UpdateOne(
+		context.Background(),
+		bson.M{"_id": post.ID, "collaborators.user_id": userID},
+		bson.M{
+			"$pull": bson.M{"collaborators": bson.M{"user_id": userID}},
+			"$set":  bson.M{"updated_at": time.Now()},
+		},
+	)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to remove collaborator"})
+		return
+	}
+	if result.MatchedCount == 0 {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Collaborator not found"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"message": "Collaborator removed successfully"})
+}
+
+// loadPost fetches the post named by the id route parameter, writing an
+// error response if that fails
+func (h *PostHandler) loadPost(c *gin.Context) (*models.Post, *middleware.Principal, bool) {
+	principal, exists := middleware.CurrentPrincipal(c)
+	if !exists {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "User not authenticated"})
+		return nil, nil, false
+	}
+
+	objID, err := primitive.ObjectIDFromHex(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid post ID"})
+		return nil, nil, false
+	}
+
+	var post models.Post
+	err = h.collection.FindOne(context.Background(), bson.M{"_id": objID}).Decode(&post)
+	if err == mongo.ErrNoDocuments {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Post not found"})
+		return nil, nil, false
+	}
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch post"})
+		return nil, nil, false
+	}
+
+	return &post, principal, true
+}
+
+// canManageCollaborators responds with 403 and returns false unless the
+// principal may invite and remove collaborators: the author, or anyone who
+// may edit every post
+func (h *PostHandler) canManageCollaborators(c *gin.Context, principal *middleware.Principal, post *models.Post) bool {
+	if !principal.CanModify(post.AuthorID, constants.PermPostCreate, constants.PermPostEditAny) {
+		c.JSON(http.StatusForbidden, gin.H{"error": "Only the author can manage collaborators"})
+		return false
+	}
+	return true
+}
+
+// canViewPost reports whether the principal may see the post whatever its
+// status, together with its history and collaborators: its author and
+// collaborators of every role, reviewers, and anyone who may edit every post
+func canViewPost(principal *middleware.Principal, post *models.Post) bool {
+	return post.RoleOf(principal.UserID) != "" ||
+		principal.HasPermission(constants.PermPostReview) ||
+		principal.HasPermission(constants.PermPostEditAny)
+}
+
+// canReadPost reports whether the principal may see the post: anyone once it
+// is published, otherwise those who may view it in any status
+func canReadPost(principal *middleware.Principal, post *models.Post) bool {
+	return post.Status == constants.PostStatusPublished || canViewPost(principal, post)
+}
+
+// redactPost hides the review comment and the collaborators from readers who
+// may only see the published post. Co-authors stay listed in its authors, so
+// call it after attachAuthors.
+func redactPost(principal *middleware.Principal, post *models.Post) {
+	if !canViewPost(principal, post) {
+		post.ReviewComment = ""
+		post.Collaborators = nil
+	}
+}
+
+// canEditPost reports whether the principal may change the post: its author,
+// co-authors and editors, or anyone who may edit every post
+func canEditPost(principal *middleware.Principal, post *models.Post) bool {
+	if principal.CanModify(post.AuthorID, constants.PermPostCreate, constants.PermPostEditAny) {
+		return true
+	}
+
+	role := post.RoleOf(principal.UserID)
+	return role == constants.PostRoleCoAuthor || role == constants.PostRoleEditor
+}
+
+// attachAuthors fills in the authors of each post: its author followed by
+// its co-authors
+func (h *PostHandler) attachAuthors(ctx context.Context, posts ...*models.Post) error {
+	var ids []primitive.ObjectID
+	for _, post := range posts {
+		ids = append(ids, post.AuthorID)
+		for _, collaborator := range post.Collaborators {
+			if collaborator.Role == constants.PostRoleCoAuthor {
+				ids = append(ids, collaborator.UserID)
+			}
+		}
+	}
+
+	users, err := h.findUsers(ctx, ids)
+	if err != nil {
+		return err
+	}
+
+	for _, post := range posts {
+		post.Authors = []models.PostAuthor{postAuthor(users, post.AuthorID, constants.PostRoleAuthor)}
+		for _, collaborator := range post.Collaborators {
+			if collaborator.Role == constants.PostRoleCoAuthor {
+				post.Authors = append(post.Authors, postAuthor(users, collaborator.UserID, constants.PostRoleCoAuthor))
+			}
+		}
+	}
+	return nil
+}
+
+// postRefs returns pointers to the posts, for attachAuthors
+func postRefs(posts []models.Post) []*models.Post {
+	refs := make([]*models.Post, len(posts))
+	for i := range posts {
+		refs[i] = &posts[i]
+	}
+	return refs
+}
+
+func postAuthor(users map[primitive.ObjectID]models.User, id primitive.ObjectID, role string) models.PostAuthor {
+	user := users[id]
+	return models.PostAuthor{
+		ID:       id,
+		Username: user.Username,
+		FullName: user.Profile.FullName,
+		Role:     role,
+	}
+}
+
+// findUsers loads the names of the given users, keyed by ID
+func (h *PostHandler) findUsers(ctx context.Context, ids []primitive.ObjectID) (map[primitive.ObjectID]models.User, error) {
+	users := make(map[primitive.ObjectID]models.User)
+	if len(ids) == 0 {
+		return users, nil
+	}
+
+	opts := options.Find().SetProjection(bson.M{"username": 1, "profile.full_name": 1})
+	cursor, err := h.users.Find(ctx, bson.M{"_id": bson.M{"$in": ids}}, opts)
+	if err != nil {
+		return users, err
+	}
+	defer cursor.Close(ctx)
+
+	var list []models.User
+	if err := cursor.All(ctx, &list); err != nil {
+		return users, err
+	}
+	for _, user := range list {
+		users[user.ID] = user
+	}
+	return users, nil
+}
+
+func collaboratorIDs(post *models.Post) []primitive.ObjectID {
+	var ids []primitive.ObjectID
+	for _, collaborator := range post.Collaborators {
+		ids = append(ids, collaborator.UserID)
+	}
+	return ids
+}
@@ -0,0 +1,25 @@
+package handlers
+
+import (
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+
+	"go-blog-platform/internal/services"
+)
+
+type JWKSHandler struct {
+	tokenService *services.TokenService
+}
+
+func NewJWKSHandler(tokenService *services.TokenService) *JWKSHandler {
+	return &JWKSHandler{
+		tokenService: tokenService,
+	}
+}
+
+// GetJWKS publishes the public keys access tokens can be verified with
+func (h *JWKSHandler) GetJWKS(c *gin.Context) {
+	c.Header("Cache-Control", "public, max-age=300")
+	c.JSON(http.StatusOK, h.tokenService.JWKS())
+}
@@ -0,0 +1,96 @@
+package handlers
+
+import (
+    "context"
+    "log"
+    "net"
+    "net/http"
+
+    "github.com/gin-gonic/gin"
+    "go.mongodb.org/mongo-driver/bson"
+    "go.mongodb.org/mongo-driver/bson/primitive"
+    "go.mongodb.org/mongo-driver/mongo"
+
+    "go-blog-platform/internal/models"
+    "go-blog-platform/internal/services"
+)
+
+type UnlockIPRequest struct {
+    IP string `json:"ip" binding:"required"`
+}
+
+// UnlockUser lifts a login lockout on a user's account (admin only)
+func (h *UserHandler) UnlockUser(c *gin.Context) {
+    userID, err := primitive.ObjectIDFromHex(c.Param("id"))
+    if err != nil {
+        c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid user ID"})
+        return
+    }
+
+    ctx := context.Background()
+    var user models.User
+    err = h.collection.FindOne(ctx, bson.M{"_id": userID}).Decode(&user)
+    if err == mongo.ErrNoDocuments {
+        c.JSON(http.StatusNotFound, gin.H{"error": "User not found"})
+        return
+    }
+    if err != nil {
+        c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch user"})
+        return
+    }
+
+    if err := h.loginThrottle.Unlock(ctx, user.Email); err != nil {
+        c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to unlock user"})
+        return
+    }
+
+    c.JSON(http.StatusOK, gin.H{"message": "User unlocked successfully"})
+}
+
+// UnlockIP lifts a login lockout on a client IP address (admin only)
+func (h *UserHandler) UnlockIP(c *gin.Context) {
+    var req UnlockIPRequest
+    if err := c.ShouldBindJSON(&req); err != nil {
+        c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+        return
+    }
+
+    ip := net.ParseIP(req.IP)
+    if ip == nil {
+        c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid IP address"})
+        return
+    }
+
+    if err := h.loginThrottle.UnlockIP(context.Background(), ip.String()); err != nil {
+        c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to unlock IP address"})
+        return
+    }
+
+    c.JSON(http.StatusOK, gin.H{"message": "IP address unlocked successfully"})
+}
+
+// recordLoginFailure counts a failed login and, if it locked an existing
+// account, tells the owner by email
+func (h *UserHandler) recordLoginFailure(c *gin.Context, email string, user *models.User) {
+    failure, err := h.loginThrottle.RecordFailure(context.Background(), email, c.ClientIP())
+    if err != nil {
+        log.Printf("Failed to record failed login: %v", err)
+        return
+    }
+
+    if !failure.Locked || user == nil {
+        return
+    }
+
+    log.Printf("Locked user %s after %d failed logins", user.ID.Hex(), failure.Failures)
+    err = h.emailService.Send(user.Email, user.Locale, services.TemplateAccountLocked, services.TemplateData{
+        "Username":      user.Username,
+        "Attempts":      failure.Failures,
+        "LockedMinutes": int(h.loginThrottle.LockoutDuration().Minutes()),
+        "IPAddress":     c.ClientIP(),
+        "Link":          h.baseURL + "/reset-password",
+    })
+    if err != nil {
+        log.Printf("Failed to send lockout email to user %s: %v", user.ID.Hex(), err)
+    }
+}
@@ -13,6 +13,8 @@ import (
     "go.mongodb.org/mongo-driver/mongo"
     "go.mongodb.org/mongo-driver/mongo/options"
 
+    "go-blog-platform/internal/constants"
+    "go-blog-platform/internal/middleware"
     "go-blog-platform/internal/models"
     "go-blog-platform/internal/services"
 )
@@ -20,13 +22,15 @@ import (
 type MediaHandler struct {
     collection   *mongo.Collection
     mediaService *services.MediaService
+    sanitizer    *services.Sanitizer
     baseURL      string
 }
 
-func NewMediaHandler(db *mongo.Database, mediaService *services.MediaService, baseURL string) *MediaHandler {
+func NewMediaHandler(db *mongo.Database, mediaService *services.MediaService, sanitizer *services.Sanitizer, baseURL string) *MediaHandler {
     return &MediaHandler{
         collection:   db.Collection("media"),
         mediaService: mediaService,
+        sanitizer:    sanitizer,
         baseURL:      baseURL,
     }
 }
@@ -34,7 +38,7 @@ func NewMediaHandler(db *mongo.Database, mediaService *services.MediaService, ba
 // UploadMedia handles file uploads
 func (h *MediaHandler) UploadMedia(c *gin.Context) {
     // Get user ID from context
-    userID, exists := c.Get("user_id")
+    principal, exists := middleware.CurrentPrincipal(c)
     if !exists {
         c.JSON(http.StatusUnauthorized, gin.H{"error": "User not authenticated"})
         return
@@ -55,14 +59,14 @@ func (h *MediaHandler) UploadMedia(c *gin.Context) {
     }
 
     // Save file and create thumbnails
-    filePath, thumbnails, err := h.mediaService.SaveFile(header, userID.(string))
+    filePath, thumbnails, err := h.mediaService.SaveFile(header, principal.UserID.Hex())
     if err != nil {
         c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to save file"})
         return
     }
 
     // Create media record
-    objID, _ := primitive.ObjectIDFromHex(userID.(string))
+    objID := principal.UserID
     media := models.Media{
         ID:        primitive.NewObjectID(),
         UserID:    objID,
@@ -98,13 +102,13 @@ func (h *MediaHandler) UploadMedia(c *gin.Context) {
 
 // ListMedia returns a list of media files
 func (h *MediaHandler) ListMedia(c *gin.Context) {
-    userID, exists := c.Get("user_id")
+    principal, exists := middleware.CurrentPrincipal(c)
     if !exists {
         c.JSON(http.StatusUnauthorized, gin.H{"error": "User not authenticated"})
         return
     }
 
-    objID, _ := primitive.ObjectIDFromHex(userID.(string))
+    objID := principal.UserID
     
     ctx := context.Background()
     opts := options.Find().SetSort(bson.D{{Key: "created_at", Value: -1}})
@@ -150,7 +154,7 @@ func (h *MediaHandler) GetMedia(c *gin.Context) {
 
 // DeleteMedia deletes a media file
 func (h *MediaHandler) DeleteMedia(c *gin.Context) {
-    userID, exists := c.Get("user_id")
+    principal, exists := middleware.CurrentPrincipal(c)
     if !exists {
         c.JSON(http.StatusUnauthorized, gin.H{"error": "User not authenticated"})
         return
@@ -162,14 +166,9 @@ func (h *MediaHandler) DeleteMedia(c *gin.Context) {
         return
     }
 
-    objID, _ := primitive.ObjectIDFromHex(userID.(string))
-    
     ctx := context.Background()
     var media models.Media
-    err = h.collection.FindOne(ctx, bson.M{
-        "_id": id,
-        "user_id": objID,
-    }).Decode(&media)
+    err = h.collection.FindOne(ctx, bson.M{"_id": id}).Decode(&media)
 
     if err != nil {
         if err == mongo.ErrNoDocuments {
@@ -180,6 +179,11 @@ func (h *MediaHandler) DeleteMedia(c *gin.Context) {
         return
     }
 
+    if !principal.CanModify(media.UserID, constants.PermMediaUpload, constants.PermMediaManageAny) {
+        c.JSON(http.StatusForbidden, gin.H{"error": "You can only modify your own resources"})
+        return
+    }
+
     // Delete file and thumbnails
     if err := h.mediaService.DeleteFile(media.Path); err != nil {
         c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to delete file"})
@@ -198,7 +202,7 @@ func (h *MediaHandler) DeleteMedia(c *gin.Context) {
 
 // UpdateMedia updates media metadata
 func (h *MediaHandler) UpdateMedia(c *gin.Context) {
-    userID, exists := c.Get("user_id")
+    principal, exists := middleware.CurrentPrincipal(c)
     if !exists {
         c.JSON(http.StatusUnauthorized, gin.H{"error": "User not authenticated"})
         return
@@ -216,15 +220,30 @@ func (h *MediaHandler) UpdateMedia(c *gin.Context) {
         return
     }
 
-    objID, _ := primitive.ObjectIDFromHex(userID.(string))
-    
+    // The title and alt text are plain text; the description may be formatted
+    metadata.Title = h.sanitizer.Text(metadata.Title)
+    metadata.AltText = h.sanitizer.Text(metadata.AltText)
+    metadata.Description = h.sanitizer.HTML(metadata.Description)
+
     ctx := context.Background()
+    ownerID, err := h.OwnerOf(ctx, id.Hex())
+    if err == middleware.ErrResourceNotFound {
+        c.JSON(http.StatusNotFound, gin.H{"error": "Media not found"})
+        return
+    }
+    if err != nil {
+        c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch media"})
+        return
+    }
+
+    if !principal.CanModify(ownerID, constants.PermMediaUpload, constants.PermMediaManageAny) {
+        c.JSON(http.StatusForbidden, gin.H{"error": "You can only modify your own resources"})
+        return
+    }
+
     result, err := h.collection.UpdateOne(
         ctx,
-        bson.M{
-            "_id": id,
-            "user_id": objID,
-        },
+        bson.M{"_id": id},
         bson.M{
             "$set": bson.M{
                 "metadata": metadata,
@@ -246,6 +265,25 @@ func (h *MediaHandler) UpdateMedia(c *gin.Context) {
     c.Status(http.StatusOK)
 }
 
+// OwnerOf returns the user who uploaded a media item, for middleware.RequireOwnership
+func (h *MediaHandler) OwnerOf(ctx context.Context, id string) (primitive.ObjectID, error) {
+    objID, err := primitive.ObjectIDFromHex(id)
+    if err != nil {
+        return primitive.NilObjectID, middleware.ErrResourceNotFound
+    }
+
+    var media models.Media
+    err = h.collection.FindOne(ctx, bson.M{"_id": objID}).Decode(&media)
+    if err == mongo.ErrNoDocuments {
+        return primitive.NilObjectID, middleware.ErrResourceNotFound
+    }
+    if err != nil {
+        return primitive.NilObjectID, err
+    }
+
+    return media.UserID, nil
+}
+
 // generateURL creates a public URL for the file
 func (h *MediaHandler) generateURL(filePath string) string {
     relativePath := strings.TrimPrefix(filePath, "uploads/")
@@ -0,0 +1,419 @@
+package handlers
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"errors"
+	"log"
+	"net/http"
+	"regexp"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+
+	"go-blog-platform/internal/middleware"
+	"go-blog-platform/internal/models"
+	"go-blog-platform/internal/services"
+)
+
+const oidcStateCookie = "oidc_state"
+
+var usernameDisallowed = regexp.MustCompile(`[^a-z0-9_.-]+`)
+
+// OIDCOptions controls how OpenID Connect identities map onto local users
+type OIDCOptions struct {
+	// RoleMapping maps provider groups to roles
+	RoleMapping map[string]string
+	// DefaultRole is given to users created on first login when none of
+	// their groups is mapped
+	DefaultRole string
+	// AllowSignup creates users on their first login
+	AllowSignup bool
+	// LinkByEmail links an identity to the local account with the same
+	// verified email address on first login
+	LinkByEmail bool
+	// SecureCookie marks the state cookie Secure
+	SecureCookie bool
+}
+
+type OIDCHandler struct {
+	oidc    *services.OIDCService
+	users   *UserHandler
+	options OIDCOptions
+}
+
+type OIDCCallbackRequest struct {
+	Code  string `json:"code" binding:"required"`
+	State string `json:"state" binding:"required"`
+}
+
+func NewOIDCHandler(oidc *services.OIDCService, users *UserHandler, options OIDCOptions) *OIDCHandler {
+	return &OIDCHandler{
+		oidc:    oidc,
+		users:   users,
+		options: options,
+	}
+}
+
+// EnsureIndexes creates the indexes OpenID Connect login relies on
+func (h *OIDCHandler) EnsureIndexes(ctx context.Context) error {
+	if err := h.oidc.EnsureIndexes(ctx); err != nil {
+		return err
+	}
+
+	// An external identity can belong to one user only
+	_, err := h.users.collection.Indexes().CreateOne(ctx, mongo.IndexModel{
+		Keys: bson.D{{Key: "identities.issuer", Value: 1}, {Key: "identities.subject", Value: 1}},
+		Options: options.Index().
+			SetUnique(true).
+			SetPartialFilterExpression(bson.M{"identities.subject": bson.M{"$exists": true}}),
+	})
+	return err
+}
+
+// Login starts an OpenID Connect login and returns the provider URL to
+// redirect the browser to
+func (h *OIDCHandler) Login(c *gin.Context) {
+	h.start(c, nil)
+}
+
+// Link starts linking an OpenID Connect identity to the current user
+func (h *OIDCHandler) Link(c *gin.Context) {
+	principal, exists := middleware.CurrentPrincipal(c)
+	if !exists {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "User not authenticated"})
+		return
+	}
+
+	userID := principal.UserID
+	h.start(c, &userID)
+}
+
+func (h *OIDCHandler) start(c *gin.Context, linkUserID *primitive.ObjectID) {
+	authURL, state, err := h.oidc.StartAuth(context.Background(), linkUserID)
+	if err != nil {
+		log.Printf("Failed to start OIDC login: %v", err)
+		c.JSON(http.StatusBadGateway, gin.H{"error": "Identity provider is unavailable"})
+		return
+	}
+
+	// Bind the request to this browser so a callback cannot be replayed elsewhere
+	c.SetSameSite(http.SameSiteLaxMode)
+	c.SetCookie(oidcStateCookie, state, int((10 * time.Minute).Seconds()), "/api", "", h.options.SecureCookie, true)
+
+	c.JSON(http.StatusOK, gin.H{"authorization_url": authURL})
+}
+
+// Callback completes an OpenID Connect login or link with the code and state
+// the provider redirected back with
+func (h *OIDCHandler) Callback(c *gin.Context) {
+	var req OIDCCallbackRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	cookie, err := c.Cookie(oidcStateCookie)
+	if err != nil || cookie != req.State {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid or expired login request"})
+		return
+	}
+	c.SetCookie(oidcStateCookie, "", -1, "/api", "", h.options.SecureCookie, true)
+
+	ctx := context.Background()
+	identity, state, err := h.oidc.CompleteAuth(ctx, req.State, req.Code)
+	if err == services.ErrInvalidOIDCState {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid or expired login request"})
+		return
+	}
+	if errors.Is(err, services.ErrInvalidIDToken) {
+		log.Printf("Rejected OIDC ID token: %v", err)
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "Identity provider login failed"})
+		return
+	}
+	if err != nil {
+		log.Printf("Failed to complete OIDC login: %v", err)
+		c.JSON(http.StatusBadGateway, gin.H{"error": "Identity provider login failed"})
+		return
+	}
+
+	if state.LinkUserID != nil {
+		h.link(c, *state.LinkUserID, identity)
+		return
+	}
+
+	// Known identity
+	var user models.User
+	err = h.users.collection.FindOne(ctx, bson.M{"identities": bson.M{"$elemMatch": bson.M{
+		"issuer":  identity.Issuer,
+		"subject": identity.Subject,
+	}}}).Decode(&user)
+	if err != nil && err != mongo.ErrNoDocuments {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch user"})
+		return
+	}
+
+	if err == mongo.ErrNoDocuments {
+		if identity.Email == "" {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Identity provider did not share an email address"})
+			return
+		}
+
+		// Existing local account with the same email address
+		err = h.users.collection.FindOne(ctx, bson.M{"email": identity.Email}).Decode(&user)
+		switch {
+		case err == nil:
+			if !h.options.LinkByEmail || !identity.EmailVerified {
+				c.JSON(http.StatusConflict, gin.H{"error": "An account with this email already exists. Log in and link your identity from your account settings."})
+				return
+			}
+			if err := h.addIdentity(ctx, user.ID, identity); err != nil {
+				c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to link identity"})
+				return
+			}
+
+		case err == mongo.ErrNoDocuments:
+			if !h.options.AllowSignup {
+				c.JSON(http.StatusForbidden, gin.H{"error": "No account is linked to this identity"})
+				return
+			}
+			created, err := h.createUser(ctx, identity, requestLocale(c, ""))
+			if err != nil {
+				log.Printf("Failed to create user from OIDC identity: %v", err)
+				c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to create user"})
+				return
+			}
+			user = *created
+
+		default:
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch user"})
+			return
+		}
+	}
+
+	// Keep the role in sync with the provider's groups
+	role, err := h.mappedRole(ctx, identity.Groups)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to map role"})
+		return
+	}
+	if role != "" && role != user.Role {
+		_, err := h.users.collection.UpdateOne(ctx, bson.M{"_id": user.ID}, bson.M{
+			"$set": bson.M{"role": role, "updated_at": time.Now()},
+		})
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to update role"})
+			return
+		}
+		user.Role = role
+	}
+
+	h.users.loginUser(c, &user)
+}
+
+// ListIdentities returns the external identities linked to the current user
+func (h *OIDCHandler) ListIdentities(c *gin.Context) {
+	user, ok := h.users.currentUser(c)
+	if !ok {
+		return
+	}
+
+	identities := user.Identities
+	if identities == nil {
+		identities = []models.ExternalIdentity{}
+	}
+	c.JSON(http.StatusOK, identities)
+}
+
+// Unlink removes the current provider's identity from the current user, as
+// long as the user can still log in with a password afterwards
+func (h *OIDCHandler) Unlink(c *gin.Context) {
+	user, ok := h.users.currentUser(c)
+	if !ok {
+		return
+	}
+
+	if user.Password == "" {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Set a password before unlinking your identity"})
+		return
+	}
+
+	result, err := h.users.collection.UpdateOne(
+		context.Background(),
+		bson.M{"_id": user.ID},
+		bson.M{"$pull": bson.M{"identities": bson.M{"issuer": h.oidc.Issuer()}}},
+	)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to unlink identity"})
+		return
+	}
+	if result.ModifiedCount == 0 {
+		c.JSON(http.StatusNotFound, gin.H{"error": "No identity is linked"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"message": "Identity unlinked successfully"})
+}
+
+func (h *OIDCHandler) link(c *gin.Context, userID primitive.ObjectID, identity *services.OIDCIdentity) {
+	ctx := context.Background()
+
+	var owner models.User
+	err := h.users.collection.FindOne(ctx, bson.M{"identities": bson.M{"$elemMatch": bson.M{
+		"issuer":  identity.Issuer,
+		"subject": identity.Subject,
+	}}}).Decode(&owner)
+	if err == nil {
+		if owner.ID == userID {
+			c.JSON(http.StatusOK, gin.H{"message": "Identity is already linked"})
+			return
+		}
+		c.JSON(http.StatusConflict, gin.H{"error": "This identity is linked to another account"})
+		return
+	}
+	if err != mongo.ErrNoDocuments {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to link identity"})
+		return
+	}
+
+	if err := h.addIdentity(ctx, userID, identity); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to link identity"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"message": "Identity linked successfully"})
+}
+
+// addIdentity links the identity to the user, replacing an earlier identity
+// from the same provider
+func (h *OIDCHandler) addIdentity(ctx context.Context, userID primitive.ObjectID, identity *services.OIDCIdentity) error {
+	_, err := h.users.collection.UpdateOne(ctx, bson.M{"_id": userID}, bson.M{
+		"$pull": bson.M{"identities": bson.M{"issuer": identity.Issuer}},
+	})
+	if err != nil {
+		return err
+	}
+
+	_, err = h.users.collection.UpdateOne(ctx, bson.M{"_id": userID}, bson.M{
+		"$push": bson.M{"identities": models.ExternalIdentity{
+			Issuer:   identity.Issuer,
+			Subject:  identity.Subject,
+			Email:    identity.Email,
+			LinkedAt: time.Now(),
+		}},
+		"$set": bson.M{"updated_at": time.Now()},
+	})
+	return err
+}
+
+// createUser provisions a user on first login. The account has no password
+// until the user sets one through a password reset.
+func (h *OIDCHandler) createUser(ctx context.Context, identity *services.OIDCIdentity, locale string) (*models.User, error) {
+	username, err := h.availableUsername(ctx, identity)
+	if err != nil {
+		return nil, err
+	}
+
+	role, err := h.mappedRole(ctx, identity.Groups)
+	if err != nil {
+		return nil, err
+	}
+	if role == "" {
+		role = h.options.DefaultRole
+	}
+
+	userID := primitive.NewObjectID()
+	now := time.Now()
+	user := models.User{
+		ID:       userID,
+		Username: username,
+		Email:    identity.Email,
+		Role:     role,
+		Profile: models.Profile{
+			ID:        primitive.NewObjectID(),
+			UserID:    userID,
+			FullName:  identity.Name,
+			CreatedAt: now,
+			UpdatedAt: now,
+		},
+		Locale:        locale,
+		EmailVerified: identity.EmailVerified,
+		Identities: []models.ExternalIdentity{{
+			Issuer:   identity.Issuer,
+			Subject:  identity.Subject,
+			Email:    identity.Email,
+			LinkedAt: now,
+		}},
+		CreatedAt: now,
+		UpdatedAt: now,
+	}
+	if user.EmailVerified {
+		user.EmailVerifiedAt = &now
+	}
+
+	if _, err := h.users.collection.InsertOne(ctx, user); err != nil {
+		return nil, err
+	}
+	return &user, nil
+}
+
+// availableUsername derives a username from the identity, adding a random
+// suffix if it is taken
+func (h *OIDCHandler) availableUsername(ctx context.Context, identity *services.OIDCIdentity) (string, error) {
+	base := identity.PreferredUsername
+	if base == "" {
+		base, _, _ = strings.Cut(identity.Email, "@")
+	}
+	base = strings.Trim(usernameDisallowed.ReplaceAllString(strings.ToLower(base), "-"), "-.")
+	if base == "" {
+		base = "user"
+	}
+
+	candidate := base
+	for i := 0; i < 5; i++ {
+		count, err := h.users.collection.CountDocuments(ctx, bson.M{"username": candidate})
+		if err != nil {
+			return "", err
+		}
+		if count == 0 {
+			return candidate, nil
+		}
+
+		suffix := make([]byte, 3)
+		if _, err := rand.Read(suffix); err != nil {
+			return "", err
+		}
+		candidate = base + "-" + hex.EncodeToString(suffix)
+	}
+	return "", errors.New("could not find an available username")
+}
+
+// mappedRole returns the role with the most permissions any of the groups
+// maps to, or an empty string if none is mapped to an existing role
+func (h *OIDCHandler) mappedRole(ctx context.Context, groups []string) (string, error) {
+	best, bestCount := "", -1
+	for _, group := range groups {
+		name, ok := h.options.RoleMapping[group]
+		if !ok {
+			continue
+		}
+
+		role, err := h.users.roles.Get(ctx, name)
+		if err == services.ErrRoleNotFound {
+			continue
+		}
+		if err != nil {
+			return "", err
+		}
+		if len(role.Permissions) > bestCount {
+			best, bestCount = role.Name, len(role.Permissions)
+		}
+	}
+	return best, nil
+}
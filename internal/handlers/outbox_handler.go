@@ -0,0 +1,100 @@
+package handlers
+
+import (
+	"context"
+	"net/http"
+	"strconv"
+
+	"github.com/gin-gonic/gin"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+
+	"go-blog-platform/internal/models"
+	"go-blog-platform/internal/services"
+)
+
+const (
+	defaultOutboxListLimit = 50
+	maxOutboxListLimit     = 500
+)
+
+type OutboxHandler struct {
+	outbox *services.Outbox
+}
+
+func NewOutboxHandler(outbox *services.Outbox) *OutboxHandler {
+	return &OutboxHandler{
+		outbox: outbox,
+	}
+}
+
+// List returns queued and delivered emails, optionally filtered by ?status= (admin only)
+func (h *OutboxHandler) List(c *gin.Context) {
+	status := c.Query("status")
+	switch status {
+	case "", models.OutboxStatusPending, models.OutboxStatusSending, models.OutboxStatusFailed,
+		models.OutboxStatusDead, models.OutboxStatusSent:
+	default:
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid status"})
+		return
+	}
+
+	limit := defaultOutboxListLimit
+	if value := c.Query("limit"); value != "" {
+		n, err := strconv.Atoi(value)
+		if err != nil || n < 1 || n > maxOutboxListLimit {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid limit"})
+			return
+		}
+		limit = n
+	}
+
+	messages, err := h.outbox.List(context.Background(), status, int64(limit))
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch outbox"})
+		return
+	}
+
+	c.JSON(http.StatusOK, messages)
+}
+
+// Get returns a single outbox message (admin only)
+func (h *OutboxHandler) Get(c *gin.Context) {
+	id, err := primitive.ObjectIDFromHex(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid message ID"})
+		return
+	}
+
+	msg, err := h.outbox.Get(context.Background(), id)
+	if err == services.ErrOutboxMessageNotFound {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Message not found"})
+		return
+	}
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch message"})
+		return
+	}
+
+	c.JSON(http.StatusOK, msg)
+}
+
+// Resend queues a failed or dead-lettered message for another round of delivery attempts (admin only)
+func (h *OutboxHandler) Resend(c *gin.Context) {
+	id, err := primitive.ObjectIDFromHex(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid message ID"})
+		return
+	}
+
+	err = h.outbox.Requeue(context.Background(), id)
+	if err == services.ErrOutboxMessageNotFound {
+		c.JSON(http.StatusNotFound, gin.H{"error": "No failed message with this ID"})
+		return
+	}
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to requeue message"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"message": "Message queued for delivery"})
+}
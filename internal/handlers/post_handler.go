@@ -2,6 +2,7 @@ package handlers
 
 import (
 	"context"
+	"log"
 	"mime/multipart"
 	"net/http"
 	"path/filepath"
@@ -11,48 +12,270 @@ import (
 	"go.mongodb.org/mongo-driver/bson"
 	"go.mongodb.org/mongo-driver/bson/primitive"
 	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
 
+	"go-blog-platform/internal/constants"
+	"go-blog-platform/internal/middleware"
 	"go-blog-platform/internal/models"
 	"go-blog-platform/internal/services"
 )
 
 type PostHandler struct {
 	collection   *mongo.Collection
+	users        *mongo.Collection
 	mediaService *services.MediaService
+	revisions    *services.RevisionService
+	slugs        *services.SlugService
+	markdown     *services.MarkdownRenderer
+	emailService *services.EmailService
+	baseURL      string
+}
+
+const defaultPostListLimit = 20
+
+type ListPostsQuery struct {
+	Status string    `form:"status" binding:"omitempty,oneof=draft in_review approved rejected scheduled published archived"`
+	Tag    string    `form:"tag"`
+	Author string    `form:"author"`
+	From   time.Time `form:"from"` // RFC 3339, inclusive, on the sort field
+	To     time.Time `form:"to"`   // RFC 3339, exclusive, on the sort field
+	Sort   string    `form:"sort" binding:"omitempty,oneof=published_at updated_at"`
+	Order  string    `form:"order" binding:"omitempty,oneof=asc desc"`
+	Limit  int       `form:"limit" binding:"omitempty,min=1,max=100"`
+	Cursor string    `form:"cursor"`
+	Total  bool      `form:"total"`
 }
 
 type CreatePostRequest struct {
 	Title        string                  `json:"title" binding:"required"`
 	Content      string                  `json:"content" binding:"required"`
 	Tags         []string                `json:"tags"`
-	Status       string                  `json:"status" binding:"required,oneof=published draft"`
+	Status       string                  `json:"status" binding:"omitempty,oneof=published draft"`
 	FeaturedFile *multipart.FileHeader   `form:"featured_image"`
 	GalleryFiles []*multipart.FileHeader `form:"gallery[]"`
 }
 
-func NewPostHandler(db *mongo.Database, mediaService *services.MediaService) *PostHandler {
+func NewPostHandler(db *mongo.Database, mediaService *services.MediaService, revisions *services.RevisionService, slugs *services.SlugService, markdown *services.MarkdownRenderer, emailService *services.EmailService, baseURL string) *PostHandler {
 	return &PostHandler{
 		collection:   db.Collection("posts"),
+		users:        db.Collection("users"),
 		mediaService: mediaService,
+		revisions:    revisions,
+		slugs:        slugs,
+		markdown:     markdown,
+		emailService: emailService,
+		baseURL:      baseURL,
+	}
+}
+
+// OwnerOf returns the author of a post, for middleware.RequireOwnership.
+// Collaborators are not owners; Update checks their roles itself.
+func (h *PostHandler) OwnerOf(ctx context.Context, id string) (primitive.ObjectID, error) {
+	objID, err := primitive.ObjectIDFromHex(id)
+	if err != nil {
+		return primitive.NilObjectID, middleware.ErrResourceNotFound
+	}
+
+	var post models.Post
+	err = h.collection.FindOne(ctx, bson.M{"_id": objID}).Decode(&post)
+	if err == mongo.ErrNoDocuments {
+		return primitive.NilObjectID, middleware.ErrResourceNotFound
 	}
+	if err != nil {
+		return primitive.NilObjectID, err
+	}
+
+	return post.AuthorID, nil
 }
 
+// EnsureIndexes creates the indexes listing posts relies on
+func (h *PostHandler) EnsureIndexes(ctx context.Context) error {
+	_, err := h.collection.Indexes().CreateMany(ctx, []mongo.IndexModel{
+		{Keys: bson.D{{Key: "published_at", Value: -1}, {Key: "_id", Value: -1}}},
+		{Keys: bson.D{{Key: "updated_at", Value: -1}, {Key: "_id", Value: -1}}},
+		{Keys: bson.D{{Key: "author_id", Value: 1}}},
+		{Keys: bson.D{{Key: "collaborators.user_id", Value: 1}}},
+		{Keys: bson.D{{Key: "tags", Value: 1}}},
+	})
+	return err
+}
+
+// MigrateContentHTML renders the content of posts written before content was
+// rendered on the server
+func (h *PostHandler) MigrateContentHTML(ctx context.Context) error {
+	opts := options.Find().SetProjection(bson.M{"content": 1})
+	cursor, err := h.collection.Find(ctx, bson.M{"content_html": bson.M{"$exists": false}}, opts)
+	if err != nil {
+		return err
+	}
+	defer cursor.Close(ctx)
+
+	var posts []models.Post
+	if err := cursor.All(ctx, &posts); err != nil {
+		return err
+	}
+
+	for _, post := range posts {
+		rendered, err := h.markdown.Render(post.Content)
+		if err != nil {
+			return err
+		}
+		_, err = h.collection.UpdateOne(ctx, bson.M{"_id": post.ID}, bson.M{"$set": bson.M{
+			"content_html": rendered.HTML,
+			"toc":          rendered.TOC,
+		}})
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// List returns a page of the posts the user may see, filtered and sorted by
+// the query parameters. Other users' posts are listed only once published.
 func (h *PostHandler) List(c *gin.Context) {
+	var query ListPostsQuery
+	if err := c.ShouldBindQuery(&query); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	principal, exists := middleware.CurrentPrincipal(c)
+	if !exists {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "User not authenticated"})
+		return
+	}
+
+	sortField := query.Sort
+	if sortField == "" {
+		sortField = "published_at"
+	}
+	desc := query.Order != "asc"
+	limit := query.Limit
+	if limit == 0 {
+		limit = defaultPostListLimit
+	}
+
+	var conditions bson.A
+	if visibility := postVisibility(principal); visibility != nil {
+		conditions = append(conditions, visibility)
+	}
+	if query.Status != "" {
+		conditions = append(conditions, bson.M{"status": query.Status})
+	}
+	if query.Tag != "" {
+		conditions = append(conditions, bson.M{"tags": query.Tag})
+	}
+	if query.Author != "" {
+		authorID, err := primitive.ObjectIDFromHex(query.Author)
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid author ID"})
+			return
+		}
+		// Co-authors are listed among the post's authors too
+		conditions = append(conditions, bson.M{"$or": bson.A{
+			bson.M{"author_id": authorID},
+			bson.M{"collaborators": bson.M{"$elemMatch": bson.M{
+				"user_id": authorID,
+				"role":    constants.PostRoleCoAuthor,
+			}}},
+		}})
+	}
+	if !query.From.IsZero() {
+		conditions = append(conditions, bson.M{sortField: bson.M{"$gte": query.From}})
+	}
+	if !query.To.IsZero() {
+		conditions = append(conditions, bson.M{sortField: bson.M{"$lt": query.To}})
+	}
+
 	ctx := context.Background()
-	cursor, err := h.collection.Find(ctx, bson.M{})
+	var total int64
+	if query.Total {
+		var err error
+		total, err = h.collection.CountDocuments(ctx, matchAll(conditions))
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to count posts"})
+			return
+		}
+	}
+
+	if query.Cursor != "" {
+		cursor, err := services.DecodePostCursor(query.Cursor, sortField, desc)
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid cursor"})
+			return
+		}
+		conditions = append(conditions, cursor.After())
+	}
+
+	direction := 1
+	if desc {
+		direction = -1
+	}
+	opts := options.Find().
+		SetSort(bson.D{{Key: sortField, Value: direction}, {Key: "_id", Value: direction}}).
+		SetLimit(int64(limit) + 1)
+	cursor, err := h.collection.Find(ctx, matchAll(conditions), opts)
 	if err != nil {
 		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
 		return
 	}
 	defer cursor.Close(ctx)
 
-	var posts []models.Post
+	posts := []models.Post{}
 	if err := cursor.All(ctx, &posts); err != nil {
 		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
 		return
 	}
 
-	c.JSON(http.StatusOK, posts)
+	// One post more than the page holds is fetched to tell whether another
+	// page follows
+	response := gin.H{}
+	if len(posts) > limit {
+		posts = posts[:limit]
+		last := posts[limit-1]
+		next := &services.PostCursor{Field: sortField, Desc: desc, Value: last.PublishedAt, ID: last.ID}
+		if sortField == "updated_at" {
+			next.Value = &last.UpdatedAt
+		}
+		response["next_cursor"] = next.Encode()
+	}
+	if query.Total {
+		response["total"] = total
+	}
+
+	if err := h.attachAuthors(ctx, postRefs(posts)...); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch authors"})
+		return
+	}
+	for i := range posts {
+		redactPost(principal, &posts[i])
+	}
+
+	response["posts"] = posts
+	c.JSON(http.StatusOK, response)
+}
+
+// postVisibility limits a list of posts to those the principal may see:
+// published posts and those the principal wrote or collaborates on. Reviewers
+// and anyone who may edit every post see all posts, so it returns nil.
+func postVisibility(principal *middleware.Principal) bson.M {
+	if principal.HasPermission(constants.PermPostReview) || principal.HasPermission(constants.PermPostEditAny) {
+		return nil
+	}
+	return bson.M{"$or": bson.A{
+		bson.M{"status": constants.PostStatusPublished},
+		bson.M{"author_id": principal.UserID},
+		bson.M{"collaborators.user_id": principal.UserID},
+	}}
+}
+
+// matchAll returns a filter matching documents that meet every condition
+func matchAll(conditions bson.A) bson.M {
+	if len(conditions) == 0 {
+		return bson.M{}
+	}
+	return bson.M{"$and": conditions}
 }
 
 func (h *PostHandler) Create(c *gin.Context) {
@@ -62,23 +285,53 @@ func (h *PostHandler) Create(c *gin.Context) {
 		return
 	}
 
-	// Get user ID from context
-	userID, exists := c.Get("user_id")
+	// Get the authenticated user
+	principal, exists := middleware.CurrentPrincipal(c)
 	if !exists {
 		c.JSON(http.StatusUnauthorized, gin.H{"error": "User not authenticated"})
 		return
 	}
 
-	objID, _ := primitive.ObjectIDFromHex(userID.(string))
+	// Posts start as drafts and go through review, unless the author may
+	// approve and publish posts anyway
+	status := req.Status
+	if status == "" {
+		status = constants.PostStatusDraft
+	}
+	if status == constants.PostStatusPublished && !canSkipReview(principal) {
+		c.JSON(http.StatusForbidden, gin.H{"error": "Posts must be reviewed before they are published"})
+		return
+	}
+
+	rendered, err := h.markdown.Render(req.Content)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to render content"})
+		return
+	}
+
+	objID := principal.UserID
+	userID := objID.Hex()
+	now := time.Now()
 	post := models.Post{
-		ID:        primitive.NewObjectID(),
-		Title:     req.Title,
-		Content:   req.Content,
-		AuthorID:  objID,
-		Status:    req.Status,
-		Tags:      req.Tags,
-		CreatedAt: time.Now(),
-		UpdatedAt: time.Now(),
+		ID:          primitive.NewObjectID(),
+		Title:       req.Title,
+		Content:     rendered.Source,
+		ContentHTML: rendered.HTML,
+		TOC:         rendered.TOC,
+		AuthorID:    objID,
+		Status:      status,
+		Tags:        req.Tags,
+		StatusHistory: []models.StatusChange{{
+			Action: services.PostActionCreate,
+			To:     status,
+			By:     &objID,
+			At:     now,
+		}},
+		CreatedAt: now,
+		UpdatedAt: now,
+	}
+	if status == constants.PostStatusPublished {
+		post.PublishedAt = &now
 	}
 
 	// Handle featured image upload
@@ -88,7 +341,7 @@ func (h *PostHandler) Create(c *gin.Context) {
 			return
 		}
 
-		filePath, thumbnails, err := h.mediaService.SaveFile(req.FeaturedFile, userID.(string))
+		filePath, thumbnails, err := h.mediaService.SaveFile(req.FeaturedFile, userID)
 		if err != nil {
 			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to save featured image"})
 			return
@@ -124,7 +377,7 @@ func (h *PostHandler) Create(c *gin.Context) {
 				return
 			}
 
-			filePath, thumbnails, err := h.mediaService.SaveFile(file, userID.(string))
+			filePath, thumbnails, err := h.mediaService.SaveFile(file, userID)
 			if err != nil {
 				c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to save gallery image"})
 				return
@@ -154,16 +407,32 @@ func (h *PostHandler) Create(c *gin.Context) {
 	}
 
 	ctx := context.Background()
-	_, err := h.collection.InsertOne(ctx, post)
-	if err != nil {
+	if err := h.insertPost(ctx, &post); err != nil {
 		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to create post"})
 		return
 	}
 
+	if _, err := h.revisions.Record(ctx, &post, objID, 0); err != nil {
+		log.Printf("Failed to record first revision of post %s: %v", post.ID.Hex(), err)
+	}
+
+	if err := h.attachAuthors(ctx, &post); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch authors"})
+		return
+	}
+
 	c.JSON(http.StatusCreated, post)
 }
 
+// Get returns a post. Posts that are not published are only found by those
+// who may view them in any status.
 func (h *PostHandler) Get(c *gin.Context) {
+	principal, exists := middleware.CurrentPrincipal(c)
+	if !exists {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "User not authenticated"})
+		return
+	}
+
 	id, err := primitive.ObjectIDFromHex(c.Param("id"))
 	if err != nil {
 		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid ID format"})
@@ -181,6 +450,16 @@ func (h *PostHandler) Get(c *gin.Context) {
 		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
 		return
 	}
+	if !canReadPost(principal, &post) {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Post not found"})
+		return
+	}
+
+	if err := h.attachAuthors(ctx, &post); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch authors"})
+		return
+	}
+	redactPost(principal, &post)
 
 	c.JSON(http.StatusOK, post)
 }
@@ -199,11 +478,12 @@ func (h *PostHandler) Update(c *gin.Context) {
 		return
 	}
 
-	userID, exists := c.Get("user_id")
+	principal, exists := middleware.CurrentPrincipal(c)
 	if !exists {
 		c.JSON(http.StatusUnauthorized, gin.H{"error": "User not authenticated"})
 		return
 	}
+	userID := principal.UserID.Hex()
 
 	// Get existing post
 	ctx := context.Background()
@@ -214,14 +494,41 @@ func (h *PostHandler) Update(c *gin.Context) {
 		return
 	}
 
+	if !canEditPost(principal, &existingPost) {
+		c.JSON(http.StatusForbidden, gin.H{"error": "You can only modify your own resources"})
+		return
+	}
+
+	if req.Status != "" && req.Status != existingPost.Status {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Use the review workflow endpoints to change the status"})
+		return
+	}
+	if !canChangeReviewedContent(principal, &existingPost) {
+		c.JSON(http.StatusConflict, gin.H{"error": "Only a reviewer can change a post once it is approved; withdraw it to edit"})
+		return
+	}
+
+	// Keep the text the post had before revisions were recorded
+	if err := h.revisions.RecordBaseline(ctx, &existingPost); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to record revision"})
+		return
+	}
+
+	rendered, err := h.markdown.Render(req.Content)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to render content"})
+		return
+	}
+
 	// Update basic fields
 	update := bson.M{
 		"$set": bson.M{
-			"title":      req.Title,
-			"content":    req.Content,
-			"status":     req.Status,
-			"tags":       req.Tags,
-			"updated_at": time.Now(),
+			"title":        req.Title,
+			"content":      rendered.Source,
+			"content_html": rendered.HTML,
+			"toc":          rendered.TOC,
+			"tags":         req.Tags,
+			"updated_at":   time.Now(),
 		},
 	}
 
@@ -238,16 +545,15 @@ func (h *PostHandler) Update(c *gin.Context) {
 			return
 		}
 
-		filePath, thumbnails, err := h.mediaService.SaveFile(req.FeaturedFile, userID.(string))
+		filePath, thumbnails, err := h.mediaService.SaveFile(req.FeaturedFile, userID)
 		if err != nil {
 			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to save featured image"})
 			return
 		}
 
-		objID, _ := primitive.ObjectIDFromHex(userID.(string))
 		media := &models.Media{
 			ID:        primitive.NewObjectID(),
-			UserID:    objID,
+			UserID:    principal.UserID,
 			FileName:  req.FeaturedFile.Filename,
 			FileType:  filepath.Ext(req.FeaturedFile.Filename),
 			MimeType:  req.FeaturedFile.Header.Get("Content-Type"),
@@ -282,16 +588,15 @@ func (h *PostHandler) Update(c *gin.Context) {
 				return
 			}
 
-			filePath, thumbnails, err := h.mediaService.SaveFile(file, userID.(string))
+			filePath, thumbnails, err := h.mediaService.SaveFile(file, userID)
 			if err != nil {
 				c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to save gallery image"})
 				return
 			}
 
-			objID, _ := primitive.ObjectIDFromHex(userID.(string))
 			media := &models.Media{
 				ID:        primitive.NewObjectID(),
-				UserID:    objID,
+				UserID:    principal.UserID,
 				FileName:  file.Filename,
 				FileType:  filepath.Ext(file.Filename),
 				MimeType:  file.Header.Get("Content-Type"),
@@ -314,22 +619,33 @@ func (h *PostHandler) Update(c *gin.Context) {
 		update["$set"].(bson.M)["gallery"] = gallery
 	}
 
-	result, err := h.collection.UpdateOne(ctx, bson.M{"_id": objID}, update)
+	// Record the revision first, so the post never changes without one
+	revision, err := h.revisions.Record(ctx, &models.Post{
+		ID:      existingPost.ID,
+		Title:   req.Title,
+		Content: rendered.Source,
+		Tags:    req.Tags,
+	}, principal.UserID, 0)
 	if err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to update post"})
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to record revision"})
 		return
 	}
 
-	if result.MatchedCount == 0 {
+	updatedPost, err := h.updatePost(ctx, &existingPost, req.Title, update)
+	if err != nil {
+		h.discardRevision(ctx, revision)
+	}
+	if err == mongo.ErrNoDocuments {
 		c.JSON(http.StatusNotFound, gin.H{"error": "Post not found"})
 		return
 	}
-
-	// Get updated post
-	var updatedPost models.Post
-	err = h.collection.FindOne(ctx, bson.M{"_id": objID}).Decode(&updatedPost)
 	if err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch updated post"})
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to update post"})
+		return
+	}
+
+	if err := h.attachAuthors(ctx, updatedPost); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch authors"})
 		return
 	}
 
@@ -378,12 +694,22 @@ func (h *PostHandler) Delete(c *gin.Context) {
 		return
 	}
 
+	if err := h.revisions.DeleteForPost(ctx, objID); err != nil {
+		log.Printf("Failed to delete revisions of post %s: %v", objID.Hex(), err)
+	}
+
 	c.Status(http.StatusNoContent)
 }
 
+// canSkipReview reports whether the principal may publish posts without
+// anyone else reviewing them
+func canSkipReview(principal *middleware.Principal) bool {
+	return principal.HasPermission(constants.PermPostReview) && principal.HasPermission(constants.PermPostPublish)
+}
+
 // ListDrafts returns all draft posts for the current user
 func (h *PostHandler) ListDrafts(c *gin.Context) {
-	userID, exists := c.Get("user_id")
+	principal, exists := middleware.CurrentPrincipal(c)
 	if !exists {
 		c.JSON(http.StatusUnauthorized, gin.H{"error": "User not authenticated"})
 		return
@@ -391,8 +717,8 @@ func (h *PostHandler) ListDrafts(c *gin.Context) {
 
 	ctx := context.Background()
 	cursor, err := h.collection.Find(ctx, bson.M{
-		"author_id": userID.(string),
-		"status":    "draft",
+		"author_id": principal.UserID,
+		"status":    constants.PostStatusDraft,
 	})
 	if err != nil {
 		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch drafts"})
@@ -417,30 +743,23 @@ func (h *PostHandler) CreateDraft(c *gin.Context) {
 		return
 	}
 
-	// Get user ID from context
-	userID, exists := c.Get("userID")
+	// Get the authenticated user
+	principal, exists := middleware.CurrentPrincipal(c)
 	if !exists {
 		c.JSON(http.StatusUnauthorized, gin.H{"error": "User not authenticated"})
 		return
 	}
 
-	// Convert string userID to ObjectID
-	userObjID, err := primitive.ObjectIDFromHex(userID.(string))
-	if err != nil {
-		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid user ID"})
-		return
-	}
-
 	// Set post metadata
 	post.ID = primitive.NewObjectID()
-	post.AuthorID = userObjID
-	post.Status = "draft"
+	post.AuthorID = principal.UserID
+	post.Status = constants.PostStatusDraft
 	post.CreatedAt = time.Now()
 	post.UpdatedAt = time.Now()
 
 	// Insert the post
 	ctx := context.Background()
-	_, err = h.collection.InsertOne(ctx, post)
+	_, err := h.collection.InsertOne(ctx, post)
 	if err != nil {
 		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to create draft"})
 		return
@@ -10,16 +10,20 @@ import (
     "go.mongodb.org/mongo-driver/bson/primitive"
     "go.mongodb.org/mongo-driver/mongo"
 
+    "go-blog-platform/internal/middleware"
     "go-blog-platform/internal/models"
+    "go-blog-platform/internal/services"
 )
 
 type ProfileHandler struct {
     collection *mongo.Collection
+    sanitizer  *services.Sanitizer
 }
 
-func NewProfileHandler(db *mongo.Database) *ProfileHandler {
+func NewProfileHandler(db *mongo.Database, sanitizer *services.Sanitizer) *ProfileHandler {
     return &ProfileHandler{
         collection: db.Collection("profiles"),
+        sanitizer:  sanitizer,
     }
 }
 
@@ -48,21 +52,17 @@ func (h *ProfileHandler) GetProfile(c *gin.Context) {
 
 // GetMyProfile retrieves the current user's profile
 func (h *ProfileHandler) GetMyProfile(c *gin.Context) {
-    userID, exists := c.Get("user_id")
+    principal, exists := middleware.CurrentPrincipal(c)
     if !exists {
         c.JSON(http.StatusUnauthorized, gin.H{"error": "User not authenticated"})
         return
     }
 
-    objID, err := primitive.ObjectIDFromHex(userID.(string))
-    if err != nil {
-        c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid user ID"})
-        return
-    }
+    objID := principal.UserID
 
     ctx := context.Background()
     var profile models.Profile
-    err = h.collection.FindOne(ctx, bson.M{"user_id": objID}).Decode(&profile)
+    err := h.collection.FindOne(ctx, bson.M{"user_id": objID}).Decode(&profile)
     if err != nil {
         if err == mongo.ErrNoDocuments {
             // If profile doesn't exist, create an empty one
@@ -88,17 +88,13 @@ func (h *ProfileHandler) GetMyProfile(c *gin.Context) {
 
 // UpdateProfile updates the current user's profile
 func (h *ProfileHandler) UpdateProfile(c *gin.Context) {
-    userID, exists := c.Get("user_id")
+    principal, exists := middleware.CurrentPrincipal(c)
     if !exists {
         c.JSON(http.StatusUnauthorized, gin.H{"error": "User not authenticated"})
         return
     }
 
-    objID, err := primitive.ObjectIDFromHex(userID.(string))
-    if err != nil {
-        c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid user ID"})
-        return
-    }
+    objID := principal.UserID
 
     var profile models.Profile
     if err := c.ShouldBindJSON(&profile); err != nil {
@@ -108,6 +104,7 @@ func (h *ProfileHandler) UpdateProfile(c *gin.Context) {
 
     // Ensure we're updating the correct profile
     profile.UserID = objID
+    profile.Bio = h.sanitizer.HTML(profile.Bio)
     profile.UpdatedAt = time.Now()
 
     ctx := context.Background()
@@ -148,17 +145,13 @@ func (h *ProfileHandler) UpdateProfile(c *gin.Context) {
 
 // DeleteProfile deletes the current user's profile
 func (h *ProfileHandler) DeleteProfile(c *gin.Context) {
-    userID, exists := c.Get("user_id")
+    principal, exists := middleware.CurrentPrincipal(c)
     if !exists {
         c.JSON(http.StatusUnauthorized, gin.H{"error": "User not authenticated"})
         return
     }
 
-    objID, err := primitive.ObjectIDFromHex(userID.(string))
-    if err != nil {
-        c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid user ID"})
-        return
-    }
+    objID := principal.UserID
 
     ctx := context.Background()
     result, err := h.collection.DeleteOne(ctx, bson.M{"user_id": objID})
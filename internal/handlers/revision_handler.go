@@ -0,0 +1,201 @@
+package handlers
+
+import (
+	"context"
+	"log"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo"
+
+	"go-blog-platform/internal/models"
+	"go-blog-platform/internal/services"
+)
+
+type RevisionDiffQuery struct {
+	From int `form:"from" binding:"required,min=1"`
+	To   int `form:"to" binding:"required,min=1"`
+}
+
+// ListRevisions returns the revisions of a post without their content,
+// newest first
+func (h *PostHandler) ListRevisions(c *gin.Context) {
+	post, principal, ok := h.loadPost(c)
+	if !ok {
+		return
+	}
+	if !canViewPost(principal, post) {
+		c.JSON(http.StatusForbidden, gin.H{"error": "You are not a collaborator on this post"})
+		return
+	}
+
+	revisions, err := h.revisions.List(context.Background(), post.ID)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch revisions"})
+		return
+	}
+
+	c.JSON(http.StatusOK, revisions)
+}
+
+// GetRevision returns one revision of a post
+func (h *PostHandler) GetRevision(c *gin.Context) {
+	number, ok := revisionNumber(c)
+	if !ok {
+		return
+	}
+
+	post, principal, ok := h.loadPost(c)
+	if !ok {
+		return
+	}
+	if !canViewPost(principal, post) {
+		c.JSON(http.StatusForbidden, gin.H{"error": "You are not a collaborator on this post"})
+		return
+	}
+
+	revision, err := h.revisions.Get(context.Background(), post.ID, number)
+	if err != nil {
+		respondRevisionError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, revision)
+}
+
+// DiffRevisions returns a unified diff from one revision of a post to another
+func (h *PostHandler) DiffRevisions(c *gin.Context) {
+	var query RevisionDiffQuery
+	if err := c.ShouldBindQuery(&query); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	post, principal, ok := h.loadPost(c)
+	if !ok {
+		return
+	}
+	if !canViewPost(principal, post) {
+		c.JSON(http.StatusForbidden, gin.H{"error": "You are not a collaborator on this post"})
+		return
+	}
+
+	diff, err := h.revisions.Diff(context.Background(), post.ID, query.From, query.To)
+	if err != nil {
+		respondRevisionError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"from": query.From,
+		"to":   query.To,
+		"diff": diff,
+	})
+}
+
+// RestoreRevision puts a revision's title, content and tags back on the post
+// and records the result as a new revision
+func (h *PostHandler) RestoreRevision(c *gin.Context) {
+	number, ok := revisionNumber(c)
+	if !ok {
+		return
+	}
+
+	post, principal, ok := h.loadPost(c)
+	if !ok {
+		return
+	}
+	if !canEditPost(principal, post) {
+		c.JSON(http.StatusForbidden, gin.H{"error": "You can only modify your own resources"})
+		return
+	}
+	if !canChangeReviewedContent(principal, post) {
+		c.JSON(http.StatusConflict, gin.H{"error": "Only a reviewer can change a post once it is approved; withdraw it to edit"})
+		return
+	}
+
+	ctx := context.Background()
+	revision, err := h.revisions.Get(ctx, post.ID, number)
+	if err != nil {
+		respondRevisionError(c, err)
+		return
+	}
+
+	rendered, err := h.markdown.Render(revision.Content)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to render content"})
+		return
+	}
+
+	// Record the restored text first, so the post never changes without a revision
+	restored, err := h.revisions.Record(ctx, &models.Post{
+		ID:      post.ID,
+		Title:   revision.Title,
+		Content: rendered.Source,
+		Tags:    revision.Tags,
+	}, principal.UserID, number)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to record revision"})
+		return
+	}
+
+	updated, err := h.updatePost(ctx, post, revision.Title, bson.M{"$set": bson.M{
+		"title":        revision.Title,
+		"content":      rendered.Source,
+		"content_html": rendered.HTML,
+		"toc":          rendered.TOC,
+		"tags":         revision.Tags,
+		"updated_at":   time.Now(),
+	}})
+	if err != nil {
+		h.discardRevision(ctx, restored)
+	}
+	if err == mongo.ErrNoDocuments {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Post not found"})
+		return
+	}
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to restore revision"})
+		return
+	}
+
+	if err := h.attachAuthors(ctx, updated); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch authors"})
+		return
+	}
+
+	c.JSON(http.StatusOK, updated)
+}
+
+// discardRevision removes the revision recorded for a change that failed
+func (h *PostHandler) discardRevision(ctx context.Context, revision *models.PostRevision) {
+	if err := h.revisions.Discard(ctx, revision); err != nil {
+		log.Printf("Failed to discard revision %d of post %s: %v", revision.Number, revision.PostID.Hex(), err)
+	}
+}
+
+// revisionNumber parses the number route parameter, writing an error
+// response if it is invalid
+func revisionNumber(c *gin.Context) (int, bool) {
+	number, err := strconv.Atoi(c.Param("number"))
+	if err != nil || number < 1 {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid revision number"})
+		return 0, false
+	}
+	return number, true
+}
+
+func respondRevisionError(c *gin.Context, err error) {
+	if err == services.ErrRevisionNotFound {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Revision not found"})
+		return
+	}
+	if err == services.ErrDiffTooLarge {
+		c.JSON(http.StatusUnprocessableEntity, gin.H{"error": "Revisions are too large or too different to compare"})
+		return
+	}
+	c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch revision"})
+}
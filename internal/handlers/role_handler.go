@@ -0,0 +1,128 @@
+package handlers
+
+import (
+	"context"
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+
+	"go-blog-platform/internal/constants"
+	"go-blog-platform/internal/services"
+)
+
+type RoleHandler struct {
+	roles *services.RoleService
+}
+
+type CreateRoleRequest struct {
+	Name        string   `json:"name" binding:"required"`
+	Description string   `json:"description" binding:"max=200"`
+	Permissions []string `json:"permissions"`
+}
+
+type UpdateRoleDefinitionRequest struct {
+	Description string   `json:"description" binding:"max=200"`
+	Permissions []string `json:"permissions"`
+}
+
+func NewRoleHandler(roles *services.RoleService) *RoleHandler {
+	return &RoleHandler{
+		roles: roles,
+	}
+}
+
+// List returns every role with its permissions
+func (h *RoleHandler) List(c *gin.Context) {
+	roles, err := h.roles.List(context.Background())
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch roles"})
+		return
+	}
+
+	c.JSON(http.StatusOK, roles)
+}
+
+// Get returns a single role
+func (h *RoleHandler) Get(c *gin.Context) {
+	role, err := h.roles.Get(context.Background(), c.Param("name"))
+	if err == services.ErrRoleNotFound {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Role not found"})
+		return
+	}
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch role"})
+		return
+	}
+
+	c.JSON(http.StatusOK, role)
+}
+
+// Create defines a custom role
+func (h *RoleHandler) Create(c *gin.Context) {
+	var req CreateRoleRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	role, err := h.roles.Create(context.Background(), req.Name, req.Description, req.Permissions)
+	if err != nil {
+		respondRoleError(c, err, "Failed to create role")
+		return
+	}
+
+	c.JSON(http.StatusCreated, role)
+}
+
+// Update replaces the description and permissions of a role. Built-in roles
+// other than admin can be changed too.
+func (h *RoleHandler) Update(c *gin.Context) {
+	var req UpdateRoleDefinitionRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	role, err := h.roles.Update(context.Background(), c.Param("name"), req.Description, req.Permissions)
+	if err != nil {
+		respondRoleError(c, err, "Failed to update role")
+		return
+	}
+
+	c.JSON(http.StatusOK, role)
+}
+
+// Delete removes a custom role that is not assigned to anyone
+func (h *RoleHandler) Delete(c *gin.Context) {
+	err := h.roles.Delete(context.Background(), c.Param("name"))
+	if err != nil {
+		respondRoleError(c, err, "Failed to delete role")
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"message": "Role deleted successfully"})
+}
+
+// ListPermissions returns every permission a role can be granted
+func (h *RoleHandler) ListPermissions(c *gin.Context) {
+	c.JSON(http.StatusOK, constants.AllPermissions)
+}
+
+func respondRoleError(c *gin.Context, err error, message string) {
+	switch err {
+	case services.ErrRoleNotFound:
+		c.JSON(http.StatusNotFound, gin.H{"error": "Role not found"})
+	case services.ErrRoleExists:
+		c.JSON(http.StatusConflict, gin.H{"error": "Role already exists"})
+	case services.ErrInvalidRoleName:
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Role names must be 2-32 lowercase letters, digits, '-' or '_', starting with a letter"})
+	case services.ErrInvalidPermission:
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid permission"})
+	case services.ErrBuiltInRole:
+		c.JSON(http.StatusConflict, gin.H{"error": "Built-in role cannot be changed"})
+	case services.ErrRoleInUse:
+		c.JSON(http.StatusConflict, gin.H{"error": "Role is assigned to users"})
+	default:
+		c.JSON(http.StatusInternalServerError, gin.H{"error": message})
+	}
+}
@@ -0,0 +1,153 @@
+package handlers
+
+import (
+    "context"
+    "net/http"
+
+    "github.com/gin-gonic/gin"
+    "go.mongodb.org/mongo-driver/bson"
+    "go.mongodb.org/mongo-driver/bson/primitive"
+    "go.mongodb.org/mongo-driver/mongo"
+
+    "go-blog-platform/internal/middleware"
+    "go-blog-platform/internal/models"
+    "go-blog-platform/internal/services"
+)
+
+type RefreshTokenRequest struct {
+    RefreshToken string `json:"refresh_token" binding:"required"`
+}
+
+// RefreshToken exchanges a refresh token for a new access/refresh token pair.
+// The presented refresh token is consumed and cannot be used again.
+func (h *UserHandler) RefreshToken(c *gin.Context) {
+    var req RefreshTokenRequest
+    if err := c.ShouldBindJSON(&req); err != nil {
+        c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+        return
+    }
+
+    ctx := context.Background()
+    session, refreshToken, err := h.sessionService.Rotate(ctx, req.RefreshToken)
+    if err != nil {
+        switch err {
+        case services.ErrInvalidRefreshToken, services.ErrRefreshTokenReused:
+            c.JSON(http.StatusUnauthorized, gin.H{"error": err.Error()})
+        default:
+            c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to refresh session"})
+        }
+        return
+    }
+
+    // Reload the user so role changes take effect on refresh
+    var user models.User
+    err = h.collection.FindOne(ctx, bson.M{"_id": session.UserID}).Decode(&user)
+    if err != nil {
+        if err == mongo.ErrNoDocuments {
+            _ = h.sessionService.Revoke(ctx, session.ID)
+            c.JSON(http.StatusUnauthorized, gin.H{"error": "User no longer exists"})
+            return
+        }
+        c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch user"})
+        return
+    }
+
+    tokenString, err := h.tokenService.IssueAccessToken(&user, session.ID.Hex())
+    if err != nil {
+        c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to generate token"})
+        return
+    }
+
+    c.JSON(http.StatusOK, gin.H{
+        "token":         tokenString,
+        "refresh_token": refreshToken,
+        "expires_in":    int(h.tokenService.AccessTokenTTL().Seconds()),
+    })
+}
+
+// Logout revokes the session the current access token belongs to
+func (h *UserHandler) Logout(c *gin.Context) {
+    principal, exists := middleware.CurrentPrincipal(c)
+    if !exists {
+        c.JSON(http.StatusUnauthorized, gin.H{"error": "User not authenticated"})
+        return
+    }
+
+    sessionObjID, err := primitive.ObjectIDFromHex(principal.SessionID)
+    if err != nil {
+        c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid session ID"})
+        return
+    }
+
+    err = h.sessionService.Revoke(context.Background(), sessionObjID)
+    if err != nil && err != services.ErrSessionNotFound {
+        c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to revoke session"})
+        return
+    }
+
+    c.JSON(http.StatusOK, gin.H{"message": "Logged out successfully"})
+}
+
+// ListUserSessions lists a user's active sessions (admin only)
+func (h *UserHandler) ListUserSessions(c *gin.Context) {
+    userID, err := primitive.ObjectIDFromHex(c.Param("id"))
+    if err != nil {
+        c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid user ID"})
+        return
+    }
+
+    sessions, err := h.sessionService.ListActive(context.Background(), userID)
+    if err != nil {
+        c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch sessions"})
+        return
+    }
+
+    c.JSON(http.StatusOK, sessions)
+}
+
+// RevokeUserSession revokes one of a user's sessions (admin only)
+func (h *UserHandler) RevokeUserSession(c *gin.Context) {
+    userID, err := primitive.ObjectIDFromHex(c.Param("id"))
+    if err != nil {
+        c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid user ID"})
+        return
+    }
+
+    sessionID, err := primitive.ObjectIDFromHex(c.Param("session_id"))
+    if err != nil {
+        c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid session ID"})
+        return
+    }
+
+    err = h.sessionService.RevokeForUser(context.Background(), userID, sessionID)
+    if err != nil {
+        if err == services.ErrSessionNotFound {
+            c.JSON(http.StatusNotFound, gin.H{"error": "Session not found"})
+            return
+        }
+        c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to revoke session"})
+        return
+    }
+
+    c.JSON(http.StatusOK, gin.H{"message": "Session revoked successfully"})
+}
+
+// RevokeUserSessions revokes all of a user's sessions (admin only)
+func (h *UserHandler) RevokeUserSessions(c *gin.Context) {
+    userID, err := primitive.ObjectIDFromHex(c.Param("id"))
+    if err != nil {
+        c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid user ID"})
+        return
+    }
+
+    revoked, err := h.sessionService.RevokeAll(context.Background(), userID)
+    if err != nil {
+        c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to revoke sessions"})
+        return
+    }
+
+    c.JSON(http.StatusOK, gin.H{
+        "message": "Sessions revoked successfully",
+        "revoked": revoked,
+    })
+}
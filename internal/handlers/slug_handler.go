@@ -0,0 +1,113 @@
+package handlers
+
+import (
+	"context"
+	"net/http"
+	"net/url"
+
+	"github.com/gin-gonic/gin"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+
+	"go-blog-platform/internal/middleware"
+	"go-blog-platform/internal/models"
+	"go-blog-platform/internal/services"
+)
+
+// GetBySlug returns the post with the given slug. Slugs the post had before
+// its title changed redirect permanently to the current one. Posts the user
+// may not read are not found under either.
+func (h *PostHandler) GetBySlug(c *gin.Context) {
+	principal, exists := middleware.CurrentPrincipal(c)
+	if !exists {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "User not authenticated"})
+		return
+	}
+	slug := c.Param("slug")
+
+	ctx := context.Background()
+	var post models.Post
+	err := h.collection.FindOne(ctx, bson.M{"slug": slug}).Decode(&post)
+	moved := false
+	if err == mongo.ErrNoDocuments {
+		err = h.collection.FindOne(ctx, bson.M{"slug_history": slug}).Decode(&post)
+		moved = true
+	}
+	// A redirect would tell others that a hidden post exists, and its new slug
+	if err == mongo.ErrNoDocuments || (err == nil && !canReadPost(principal, &post)) {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Post not found"})
+		return
+	}
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch post"})
+		return
+	}
+
+	if moved {
+		c.Redirect(http.StatusMovedPermanently, "/api/posts/by-slug/"+url.PathEscape(post.Slug))
+		return
+	}
+
+	if err := h.attachAuthors(ctx, &post); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch authors"})
+		return
+	}
+	redactPost(principal, &post)
+
+	c.JSON(http.StatusOK, post)
+}
+
+// insertPost saves a new post under a free slug for its title
+func (h *PostHandler) insertPost(ctx context.Context, post *models.Post) error {
+	return h.slugs.Assign(ctx, post.Title, post.ID, func(slug string) error {
+		post.Slug = slug
+		_, err := h.collection.InsertOne(ctx, post)
+		return err
+	})
+}
+
+// updatePost applies an update with a $set to the post and returns the
+// result. If the post's slug does not fit the new title, the post moves to a
+// new slug and keeps the old one in its slug history.
+func (h *PostHandler) updatePost(ctx context.Context, post *models.Post, title string, update bson.M) (*models.Post, error) {
+	set := update["$set"].(bson.M)
+	var updated models.Post
+	write := func(slug string) error {
+		if slug != post.Slug {
+			set["slug"] = slug
+			set["slug_history"] = slugHistory(post, slug)
+		}
+		return h.collection.FindOneAndUpdate(
+			ctx,
+			bson.M{"_id": post.ID},
+			update,
+			options.FindOneAndUpdate().SetReturnDocument(options.After),
+		).Decode(&updated)
+	}
+
+	var err error
+	if services.SlugFitsTitle(post.Slug, title) {
+		err = write(post.Slug)
+	} else {
+		err = h.slugs.Assign(ctx, title, post.ID, write)
+	}
+	if err != nil {
+		return nil, err
+	}
+	return &updated, nil
+}
+
+// slugHistory returns the post's earlier slugs once it moves to slug
+func slugHistory(post *models.Post, slug string) []string {
+	history := []string{}
+	for _, old := range post.SlugHistory {
+		if old != slug {
+			history = append(history, old)
+		}
+	}
+	if post.Slug != "" {
+		history = append(history, post.Slug)
+	}
+	return history
+}
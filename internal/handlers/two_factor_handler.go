@@ -0,0 +1,524 @@
+package handlers
+
+import (
+    "context"
+    "log"
+    "net/http"
+    "strconv"
+    "time"
+
+    "github.com/gin-gonic/gin"
+    "go.mongodb.org/mongo-driver/bson"
+    "go.mongodb.org/mongo-driver/bson/primitive"
+    "go.mongodb.org/mongo-driver/mongo"
+    "go.mongodb.org/mongo-driver/mongo/options"
+
+    "go-blog-platform/internal/middleware"
+    "go-blog-platform/internal/models"
+    "go-blog-platform/internal/services"
+)
+
+const (
+    twoFactorChallengeTTL  = 5 * time.Minute
+    twoFactorMaxFailures   = 5
+    twoFactorLockoutPeriod = 15 * time.Minute
+    recoveryCodeCount      = 10
+)
+
+type LoginTwoFactorRequest struct {
+    ChallengeToken string `json:"challenge_token" binding:"required"`
+    Code           string `json:"code"`
+    RecoveryCode   string `json:"recovery_code"`
+}
+
+type TwoFactorCodeRequest struct {
+    Code string `json:"code" binding:"required"`
+}
+
+type DisableTwoFactorRequest struct {
+    Password string `json:"password" binding:"required"`
+    Code     string `json:"code" binding:"required"`
+}
+
+type TwoFactorPolicyRequest struct {
+    RequiredRole string `json:"required_role"`
+}
+
+// LoginTwoFactor completes a login started with a password by checking a
+// TOTP or recovery code against the challenge token
+func (h *UserHandler) LoginTwoFactor(c *gin.Context) {
+    var req LoginTwoFactorRequest
+    if err := c.ShouldBindJSON(&req); err != nil {
+        c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+        return
+    }
+    if (req.Code == "") == (req.RecoveryCode == "") {
+        c.JSON(http.StatusBadRequest, gin.H{"error": "Provide either code or recovery_code"})
+        return
+    }
+
+    claims, err := h.tokenService.ParseActionToken(services.PurposeTwoFactorChallenge, req.ChallengeToken)
+    if err != nil {
+        c.JSON(http.StatusUnauthorized, gin.H{"error": "Invalid or expired challenge, please log in again"})
+        return
+    }
+
+    userID, err := primitive.ObjectIDFromHex(claims.Subject)
+    if err != nil {
+        c.JSON(http.StatusUnauthorized, gin.H{"error": "Invalid or expired challenge, please log in again"})
+        return
+    }
+
+    ctx := context.Background()
+    var user models.User
+    err = h.collection.FindOne(ctx, bson.M{"_id": userID, "email": claims.Email}).Decode(&user)
+    if err != nil || !user.TwoFactorEnabled {
+        c.JSON(http.StatusUnauthorized, gin.H{"error": "Invalid or expired challenge, please log in again"})
+        return
+    }
+
+    if user.TwoFactorLockedUntil != nil && time.Now().Before(*user.TwoFactorLockedUntil) {
+        c.Header("Retry-After", strconv.Itoa(int(time.Until(*user.TwoFactorLockedUntil).Seconds())+1))
+        c.JSON(http.StatusTooManyRequests, gin.H{"error": "Too many failed attempts, please try again later"})
+        return
+    }
+
+    var ok bool
+    if req.Code != "" {
+        ok, err = h.useTOTPCode(ctx, &user, req.Code)
+    } else {
+        ok, err = h.useRecoveryCode(ctx, &user, req.RecoveryCode)
+    }
+    if err != nil {
+        c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to verify code"})
+        return
+    }
+
+    if !ok {
+        if err := h.recordTwoFactorFailure(ctx, user.ID); err != nil {
+            log.Printf("Failed to record two-factor failure for user %s: %v", user.ID.Hex(), err)
+        }
+        c.JSON(http.StatusUnauthorized, gin.H{"error": "Invalid authentication code"})
+        return
+    }
+
+    _, err = h.collection.UpdateOne(ctx, bson.M{"_id": user.ID}, bson.M{
+        "$unset": bson.M{"two_factor_failures": "", "two_factor_locked_until": ""},
+    })
+    if err != nil {
+        log.Printf("Failed to reset two-factor failures for user %s: %v", user.ID.Hex(), err)
+    }
+
+    h.completeLogin(c, &user)
+}
+
+// GetTwoFactorStatus returns the current user's two-factor settings
+func (h *UserHandler) GetTwoFactorStatus(c *gin.Context) {
+    user, ok := h.currentUser(c)
+    if !ok {
+        return
+    }
+
+    required, err := h.twoFactorPolicy.Requires(context.Background(), user.Role)
+    if err != nil {
+        c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to check two-factor policy"})
+        return
+    }
+
+    c.JSON(http.StatusOK, gin.H{
+        "enabled":                  user.TwoFactorEnabled,
+        "required":                 required,
+        "recovery_codes_remaining": len(user.RecoveryCodeHashes),
+    })
+}
+
+// SetupTOTP generates a new TOTP secret for the current user. It takes effect
+// once confirmed with a code from the authenticator app.
+func (h *UserHandler) SetupTOTP(c *gin.Context) {
+    user, ok := h.currentUser(c)
+    if !ok {
+        return
+    }
+
+    if user.TwoFactorEnabled {
+        c.JSON(http.StatusConflict, gin.H{"error": "Two-factor authentication is already enabled"})
+        return
+    }
+
+    secret, err := services.GenerateTOTPSecret()
+    if err != nil {
+        c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to generate secret"})
+        return
+    }
+
+    _, err = h.collection.UpdateOne(
+        context.Background(),
+        bson.M{"_id": user.ID, "two_factor_enabled": bson.M{"$ne": true}},
+        bson.M{"$set": bson.M{"totp_pending_secret": secret, "updated_at": time.Now()}},
+    )
+    if err != nil {
+        c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to save secret"})
+        return
+    }
+
+    c.JSON(http.StatusOK, gin.H{
+        "secret":      secret,
+        "otpauth_uri": services.TOTPURI(h.siteName, user.Email, secret),
+    })
+}
+
+// ConfirmTOTP enables two-factor authentication once the user proves their
+// authenticator app produces valid codes, and returns fresh recovery codes
+func (h *UserHandler) ConfirmTOTP(c *gin.Context) {
+    var req TwoFactorCodeRequest
+    if err := c.ShouldBindJSON(&req); err != nil {
+        c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+        return
+    }
+
+    user, ok := h.currentUser(c)
+    if !ok {
+        return
+    }
+
+    if user.TwoFactorEnabled {
+        c.JSON(http.StatusConflict, gin.H{"error": "Two-factor authentication is already enabled"})
+        return
+    }
+    if user.TOTPPendingSecret == "" {
+        c.JSON(http.StatusBadRequest, gin.H{"error": "Start two-factor setup first"})
+        return
+    }
+
+    step, valid := services.ValidateTOTP(user.TOTPPendingSecret, req.Code, 0, time.Now())
+    if !valid {
+        c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid authentication code"})
+        return
+    }
+
+    codes, hashes, err := newRecoveryCodes()
+    if err != nil {
+        c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to generate recovery codes"})
+        return
+    }
+
+    result, err := h.collection.UpdateOne(
+        context.Background(),
+        bson.M{"_id": user.ID, "totp_pending_secret": user.TOTPPendingSecret},
+        bson.M{
+            "$set": bson.M{
+                "two_factor_enabled":   true,
+                "totp_secret":          user.TOTPPendingSecret,
+                "totp_last_step":       step,
+                "recovery_code_hashes": hashes,
+                "updated_at":           time.Now(),
+            },
+            "$unset": bson.M{"totp_pending_secret": ""},
+        },
+    )
+    if err != nil {
+        c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to enable two-factor authentication"})
+        return
+    }
+    if result.MatchedCount == 0 {
+        c.JSON(http.StatusConflict, gin.H{"error": "Two-factor setup changed, please start again"})
+        return
+    }
+
+    c.JSON(http.StatusOK, gin.H{
+        "message":        "Two-factor authentication enabled",
+        "recovery_codes": codes,
+    })
+}
+
+// RegenerateRecoveryCodes replaces the current user's recovery codes
+func (h *UserHandler) RegenerateRecoveryCodes(c *gin.Context) {
+    var req TwoFactorCodeRequest
+    if err := c.ShouldBindJSON(&req); err != nil {
+        c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+        return
+    }
+
+    user, ok := h.currentUser(c)
+    if !ok {
+        return
+    }
+
+    if !user.TwoFactorEnabled {
+        c.JSON(http.StatusBadRequest, gin.H{"error": "Two-factor authentication is not enabled"})
+        return
+    }
+
+    ctx := context.Background()
+    valid, err := h.useTOTPCode(ctx, user, req.Code)
+    if err != nil {
+        c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to verify code"})
+        return
+    }
+    if !valid {
+        c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid authentication code"})
+        return
+    }
+
+    codes, hashes, err := newRecoveryCodes()
+    if err != nil {
+        c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to generate recovery codes"})
+        return
+    }
+
+    _, err = h.collection.UpdateOne(ctx, bson.M{"_id": user.ID}, bson.M{
+        "$set": bson.M{"recovery_code_hashes": hashes, "updated_at": time.Now()},
+    })
+    if err != nil {
+        c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to save recovery codes"})
+        return
+    }
+
+    c.JSON(http.StatusOK, gin.H{"recovery_codes": codes})
+}
+
+// DisableTwoFactor turns off two-factor authentication for the current user,
+// unless the policy requires it for their role
+func (h *UserHandler) DisableTwoFactor(c *gin.Context) {
+    var req DisableTwoFactorRequest
+    if err := c.ShouldBindJSON(&req); err != nil {
+        c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+        return
+    }
+
+    user, ok := h.currentUser(c)
+    if !ok {
+        return
+    }
+
+    if !user.TwoFactorEnabled {
+        c.JSON(http.StatusBadRequest, gin.H{"error": "Two-factor authentication is not enabled"})
+        return
+    }
+
+    ctx := context.Background()
+    required, err := h.twoFactorPolicy.Requires(ctx, user.Role)
+    if err != nil {
+        c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to check two-factor policy"})
+        return
+    }
+    if required {
+        c.JSON(http.StatusForbidden, gin.H{"error": "Two-factor authentication is required for your role"})
+        return
+    }
+
+    if err := user.ComparePassword(req.Password); err != nil {
+        c.JSON(http.StatusUnauthorized, gin.H{"error": "Invalid password"})
+        return
+    }
+
+    valid, err := h.useTOTPCode(ctx, user, req.Code)
+    if err != nil {
+        c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to verify code"})
+        return
+    }
+    if !valid {
+        c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid authentication code"})
+        return
+    }
+
+    if err := h.clearTwoFactor(ctx, user.ID); err != nil {
+        c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to disable two-factor authentication"})
+        return
+    }
+
+    c.JSON(http.StatusOK, gin.H{"message": "Two-factor authentication disabled"})
+}
+
+// ResetTwoFactor removes a user's two-factor enrollment, e.g. after they lost
+// their device, and signs them out everywhere (admin only)
+func (h *UserHandler) ResetTwoFactor(c *gin.Context) {
+    userID, err := primitive.ObjectIDFromHex(c.Param("id"))
+    if err != nil {
+        c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid user ID"})
+        return
+    }
+
+    ctx := context.Background()
+    count, err := h.collection.CountDocuments(ctx, bson.M{"_id": userID})
+    if err != nil {
+        c.JSON(http.StatusInternalServerError, gin.H{"error": "Database error"})
+        return
+    }
+    if count == 0 {
+        c.JSON(http.StatusNotFound, gin.H{"error": "User not found"})
+        return
+    }
+
+    if err := h.clearTwoFactor(ctx, userID); err != nil {
+        c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to reset two-factor authentication"})
+        return
+    }
+
+    if _, err := h.sessionService.RevokeAll(ctx, userID); err != nil {
+        log.Printf("Failed to revoke sessions after two-factor reset for user %s: %v", userID.Hex(), err)
+    }
+
+    c.JSON(http.StatusOK, gin.H{"message": "Two-factor authentication reset successfully"})
+}
+
+// GetTwoFactorPolicy returns the role two-factor authentication is required for (admin only)
+func (h *UserHandler) GetTwoFactorPolicy(c *gin.Context) {
+    policy, err := h.twoFactorPolicy.Get(context.Background())
+    if err != nil {
+        c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch two-factor policy"})
+        return
+    }
+
+    c.JSON(http.StatusOK, policy)
+}
+
+// SetTwoFactorPolicy requires two-factor authentication for a role and every
+// role holding all of its permissions, or for nobody with an empty role (admin only)
+func (h *UserHandler) SetTwoFactorPolicy(c *gin.Context) {
+    var req TwoFactorPolicyRequest
+    if err := c.ShouldBindJSON(&req); err != nil {
+        c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+        return
+    }
+
+    principal, _ := middleware.CurrentPrincipal(c)
+    policy, err := h.twoFactorPolicy.Set(context.Background(), req.RequiredRole, principal.UserID)
+    if err == services.ErrInvalidPolicyRole {
+        c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid role"})
+        return
+    }
+    if err != nil {
+        c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to update two-factor policy"})
+        return
+    }
+
+    c.JSON(http.StatusOK, policy)
+}
+
+// AccountState returns whether the user has verified their email address and
+// enrolled in two-factor authentication, for middleware.RequireVerifiedEmail
+// and middleware.RequireTwoFactor. A missing user has done neither.
+func (h *UserHandler) AccountState(ctx context.Context, userID primitive.ObjectID) (bool, bool, error) {
+    var user models.User
+    opts := options.FindOne().SetProjection(bson.M{"email_verified": 1, "two_factor_enabled": 1})
+    err := h.collection.FindOne(ctx, bson.M{"_id": userID}, opts).Decode(&user)
+    if err == mongo.ErrNoDocuments {
+        return false, false, nil
+    }
+    if err != nil {
+        return false, false, err
+    }
+
+    return user.EmailVerified, user.TwoFactorEnabled, nil
+}
+
+// currentUser loads the authenticated user, writing an error response if that fails
+func (h *UserHandler) currentUser(c *gin.Context) (*models.User, bool) {
+    principal, exists := middleware.CurrentPrincipal(c)
+    if !exists {
+        c.JSON(http.StatusUnauthorized, gin.H{"error": "User not authenticated"})
+        return nil, false
+    }
+
+    var user models.User
+    err := h.collection.FindOne(context.Background(), bson.M{"_id": principal.UserID}).Decode(&user)
+    if err == mongo.ErrNoDocuments {
+        c.JSON(http.StatusNotFound, gin.H{"error": "User not found"})
+        return nil, false
+    }
+    if err != nil {
+        c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch user"})
+        return nil, false
+    }
+
+    return &user, true
+}
+
+// useTOTPCode validates a TOTP code and records its time step, so each code
+// is accepted only once
+func (h *UserHandler) useTOTPCode(ctx context.Context, user *models.User, code string) (bool, error) {
+    step, valid := services.ValidateTOTP(user.TOTPSecret, code, user.TOTPLastStep, time.Now())
+    if !valid {
+        return false, nil
+    }
+
+    // Another request may have used a code from this step since the user was loaded
+    result, err := h.collection.UpdateOne(
+        ctx,
+        bson.M{"_id": user.ID, "totp_last_step": bson.M{"$not": bson.M{"$gte": step}}},
+        bson.M{"$set": bson.M{"totp_last_step": step}},
+    )
+    if err != nil {
+        return false, err
+    }
+    return result.MatchedCount == 1, nil
+}
+
+// useRecoveryCode consumes a recovery code
+func (h *UserHandler) useRecoveryCode(ctx context.Context, user *models.User, code string) (bool, error) {
+    hash := services.HashRecoveryCode(code)
+    result, err := h.collection.UpdateOne(
+        ctx,
+        bson.M{"_id": user.ID, "recovery_code_hashes": hash},
+        bson.M{"$pull": bson.M{"recovery_code_hashes": hash}},
+    )
+    if err != nil {
+        return false, err
+    }
+    return result.MatchedCount == 1, nil
+}
+
+// recordTwoFactorFailure counts a failed code and locks the second step for a
+// while once too many codes in a row were wrong
+func (h *UserHandler) recordTwoFactorFailure(ctx context.Context, userID primitive.ObjectID) error {
+    var user models.User
+    err := h.collection.FindOneAndUpdate(
+        ctx,
+        bson.M{"_id": userID},
+        bson.M{"$inc": bson.M{"two_factor_failures": 1}},
+        options.FindOneAndUpdate().SetReturnDocument(options.After),
+    ).Decode(&user)
+    if err != nil {
+        return err
+    }
+
+    if user.TwoFactorFailures < twoFactorMaxFailures {
+        return nil
+    }
+
+    _, err = h.collection.UpdateOne(ctx, bson.M{"_id": userID}, bson.M{
+        "$set":   bson.M{"two_factor_locked_until": time.Now().Add(twoFactorLockoutPeriod)},
+        "$unset": bson.M{"two_factor_failures": ""},
+    })
+    return err
+}
+
+func (h *UserHandler) clearTwoFactor(ctx context.Context, userID primitive.ObjectID) error {
+    _, err := h.collection.UpdateOne(ctx, bson.M{"_id": userID}, bson.M{
+        "$set": bson.M{"two_factor_enabled": false, "updated_at": time.Now()},
+        "$unset": bson.M{
+            "totp_secret":             "",
+            "totp_pending_secret":     "",
+            "totp_last_step":          "",
+            "recovery_code_hashes":    "",
+            "two_factor_failures":     "",
+            "two_factor_locked_until": "",
+        },
+    })
+    return err
+}
+
+// newRecoveryCodes returns a set of recovery codes together with their hashes
+func newRecoveryCodes() ([]string, []string, error) {
+    codes, err := services.GenerateRecoveryCodes(recoveryCodeCount)
+    if err != nil {
+        return nil, nil, err
+    }
+
+    hashes := make([]string, len(codes))
+    for i, code := range codes {
+        hashes[i] = services.HashRecoveryCode(code)
+    }
+    return codes, hashes, nil
+}
@@ -7,34 +7,53 @@ import (
     "mime/multipart"
     "net/http"
     "path/filepath"
+    "strconv"
+    "strings"
     "time"
 
     "github.com/gin-gonic/gin"
-    "github.com/golang-jwt/jwt/v5"
     "go.mongodb.org/mongo-driver/bson"
     "go.mongodb.org/mongo-driver/bson/primitive"
     "go.mongodb.org/mongo-driver/mongo"
     "golang.org/x/crypto/bcrypt"
 
+    "go-blog-platform/internal/constants"
+    "go-blog-platform/internal/middleware"
     "go-blog-platform/internal/models"
     "go-blog-platform/internal/services"
 )
 
 type UserHandler struct {
-    collection   *mongo.Collection
-    jwtSecret   string
-    emailService *services.EmailService
-    mediaService *services.MediaService
-    baseURL     string
+    collection      *mongo.Collection
+    tokenService    *services.TokenService
+    emailService    *services.EmailService
+    mediaService    *services.MediaService
+    sessionService  *services.SessionService
+    passwordResets  *services.PasswordResetService
+    twoFactorPolicy *services.TwoFactorPolicyService
+    roles           *services.RoleService
+    loginThrottle   *services.LoginThrottle
+    passwordPolicy  *services.PasswordPolicy
+    sanitizer       *services.Sanitizer
+    siteName        string
+    baseURL         string
 }
 
-func NewUserHandler(db *mongo.Database, jwtSecret string, emailService *services.EmailService, mediaService *services.MediaService, baseURL string) *UserHandler {
+func NewUserHandler(db *mongo.Database, tokenService *services.TokenService, emailService *services.EmailService, mediaService *services.MediaService, sessionService *services.SessionService, passwordResets *services.PasswordResetService, twoFactorPolicy *services.TwoFactorPolicyService, roles *services.RoleService, loginThrottle *services.LoginThrottle, passwordPolicy *services.PasswordPolicy, sanitizer *services.Sanitizer, siteName, baseURL string) *UserHandler {
     return &UserHandler{
-        collection:   db.Collection("users"),
-        jwtSecret:   jwtSecret,
-        emailService: emailService,
-        mediaService: mediaService,
-        baseURL:     baseURL,
+        collection:      db.Collection("users"),
+        tokenService:    tokenService,
+        emailService:    emailService,
+        mediaService:    mediaService,
+        sessionService:  sessionService,
+        passwordResets:  passwordResets,
+        twoFactorPolicy: twoFactorPolicy,
+        roles:           roles,
+        loginThrottle:   loginThrottle,
+        passwordPolicy:  passwordPolicy,
+        sanitizer:       sanitizer,
+        siteName:        siteName,
+        baseURL:         baseURL,
     }
 }
 
@@ -54,7 +73,7 @@ type RegisterRequest struct {
     Location    string               `form:"location"`
     Website     string               `form:"website"`
     SocialLinks models.SocialLinks   `form:"social_links"`
-    Role        string               `json:"role"`
+    Locale      string               `form:"locale"`
 }
 
 type UpdateRoleRequest struct {
@@ -63,6 +82,7 @@ type UpdateRoleRequest struct {
 
 type UpdateProfileRequest struct {
     FullName    string               `form:"full_name"`
+    Locale      string               `form:"locale"`
     Avatar      *multipart.FileHeader `form:"avatar"`
     CoverImage  *multipart.FileHeader `form:"cover_image"`
     Bio         string               `form:"bio"`
@@ -77,7 +97,7 @@ type RequestPasswordResetRequest struct {
 
 type ResetPasswordRequest struct {
     Token    string `json:"token" binding:"required"`
-    Password string `json:"password" binding:"required,min=6"`
+    Password string `json:"password" binding:"required"`
 }
 
 func (h *UserHandler) ListUsers(c *gin.Context) {
@@ -98,7 +118,7 @@ func (h *UserHandler) ListUsers(c *gin.Context) {
     c.JSON(http.StatusOK, users)
 }
 
-// UpdateUserRole updates a user's role (admin only)
+// UpdateUserRole updates a user's role and signs them out (admin only)
 func (h *UserHandler) UpdateUserRole(c *gin.Context) {
     userID, err := primitive.ObjectIDFromHex(c.Param("id"))
     if err != nil {
@@ -112,16 +132,17 @@ func (h *UserHandler) UpdateUserRole(c *gin.Context) {
         return
     }
 
-    // Validate the new role
-    switch req.Role {
-    case "admin", "author", "reader":
-        // Valid role
-    default:
+    ctx := context.Background()
+    _, err = h.roles.Get(ctx, req.Role)
+    if err == services.ErrRoleNotFound {
         c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid role"})
         return
     }
+    if err != nil {
+        c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch role"})
+        return
+    }
 
-    ctx := context.Background()
     result, err := h.collection.UpdateOne(
         ctx,
         bson.M{"_id": userID},
@@ -138,6 +159,12 @@ func (h *UserHandler) UpdateUserRole(c *gin.Context) {
         return
     }
 
+    // Access tokens carry the role, so sign the user out for it to apply at once
+    if _, err := h.sessionService.RevokeAll(ctx, userID); err != nil {
+        c.JSON(http.StatusInternalServerError, gin.H{"error": "User role updated, but failed to sign the user out; repeat the request"})
+        return
+    }
+
     c.JSON(http.StatusOK, gin.H{"message": "User role updated successfully"})
 }
 
@@ -161,6 +188,10 @@ func (h *UserHandler) DeleteUser(c *gin.Context) {
         return
     }
 
+    if _, err := h.sessionService.RevokeAll(ctx, userID); err != nil {
+        log.Printf("Failed to revoke sessions of deleted user %s: %v", userID.Hex(), err)
+    }
+
     c.JSON(http.StatusOK, gin.H{"message": "User deleted successfully"})
 }
 
@@ -186,6 +217,10 @@ func (h *UserHandler) Register(c *gin.Context) {
         return
     }
 
+    if !h.checkPasswordPolicy(c, req.Password, req.Username, req.Email) {
+        return
+    }
+
     // Hash password
     hashedPassword, err := bcrypt.GenerateFromPassword([]byte(req.Password), bcrypt.DefaultCost)
     if err != nil {
@@ -201,7 +236,7 @@ func (h *UserHandler) Register(c *gin.Context) {
         ID:          primitive.NewObjectID(),
         UserID:      userID,
         FullName:    req.FullName,
-        Bio:         req.Bio,
+        Bio:         h.sanitizer.HTML(req.Bio),
         Location:    req.Location,
         Website:     req.Website,
         SocialLinks: req.SocialLinks,
@@ -281,14 +316,18 @@ func (h *UserHandler) Register(c *gin.Context) {
 
     // Create user
     user := models.User{
-        ID:        userID,
-        Username:  req.Username,
-        Email:     req.Email,
-        Password:  string(hashedPassword),
-        Profile:   profile,
-        Role:      req.Role,
-        CreatedAt: now,
-        UpdatedAt: now,
+        ID:                 userID,
+        Username:           req.Username,
+        Email:              req.Email,
+        Password:           string(hashedPassword),
+        Profile:            profile,
+        // New accounts are readers; only admins can grant other roles
+        Role:               constants.RoleReader,
+        Locale:             requestLocale(c, req.Locale),
+        EmailVerified:      false,
+        VerificationSentAt: &now,
+        CreatedAt:          now,
+        UpdatedAt:          now,
     }
 
     _, err = h.collection.InsertOne(context.Background(), user)
@@ -297,15 +336,21 @@ func (h *UserHandler) Register(c *gin.Context) {
         return
     }
 
+    // The account exists either way; the user can ask for the link again
+    if err := h.sendVerificationEmail(&user); err != nil {
+        log.Printf("Failed to send verification email to user %s: %v", user.ID.Hex(), err)
+    }
+
     c.JSON(http.StatusCreated, gin.H{
-        "id":       user.ID,
-        "username": user.Username,
-        "profile":  user.Profile,
+        "id":             user.ID,
+        "username":       user.Username,
+        "email_verified": user.EmailVerified,
+        "profile":        user.Profile,
     })
 }
 
 func (h *UserHandler) UpdateProfile(c *gin.Context) {
-    userID, exists := c.Get("user_id")
+    principal, exists := middleware.CurrentPrincipal(c)
     if !exists {
         c.JSON(http.StatusUnauthorized, gin.H{"error": "User not authenticated"})
         return
@@ -317,7 +362,7 @@ func (h *UserHandler) UpdateProfile(c *gin.Context) {
         return
     }
 
-    objID, _ := primitive.ObjectIDFromHex(userID.(string))
+    objID := principal.UserID
 
     // Get existing user
     var user models.User
@@ -331,7 +376,7 @@ func (h *UserHandler) UpdateProfile(c *gin.Context) {
     update := bson.M{
         "$set": bson.M{
             "profile.full_name":     req.FullName,
-            "profile.bio":           req.Bio,
+            "profile.bio":           h.sanitizer.HTML(req.Bio),
             "profile.location":      req.Location,
             "profile.website":       req.Website,
             "profile.social_links":  req.SocialLinks,
@@ -339,6 +384,10 @@ func (h *UserHandler) UpdateProfile(c *gin.Context) {
         },
     }
 
+    if locale := services.NormalizeLocale(req.Locale); locale != "" {
+        update["$set"].(bson.M)["locale"] = locale
+    }
+
     // Handle avatar update
     if req.Avatar != nil {
         // Delete old avatar if exists
@@ -351,7 +400,7 @@ func (h *UserHandler) UpdateProfile(c *gin.Context) {
             return
         }
 
-        filePath, thumbnails, err := h.mediaService.SaveFile(req.Avatar, userID.(string))
+        filePath, thumbnails, err := h.mediaService.SaveFile(req.Avatar, objID.Hex())
         if err != nil {
             c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to save avatar"})
             return
@@ -391,7 +440,7 @@ func (h *UserHandler) UpdateProfile(c *gin.Context) {
             return
         }
 
-        filePath, thumbnails, err := h.mediaService.SaveFile(req.CoverImage, userID.(string))
+        filePath, thumbnails, err := h.mediaService.SaveFile(req.CoverImage, objID.Hex())
         if err != nil {
             c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to save cover image"})
             return
@@ -445,6 +494,23 @@ func (h *UserHandler) UpdateProfile(c *gin.Context) {
     })
 }
 
+// requestLocale returns the explicitly requested locale, or the first
+// language from the Accept-Language header
+func requestLocale(c *gin.Context, explicit string) string {
+    if locale := services.NormalizeLocale(explicit); locale != "" {
+        return locale
+    }
+
+    for _, tag := range strings.Split(c.GetHeader("Accept-Language"), ",") {
+        tag, _, _ = strings.Cut(tag, ";")
+        if locale := services.NormalizeLocale(tag); locale != "" {
+            return locale
+        }
+    }
+
+    return services.DefaultLocale
+}
+
 func (h *UserHandler) Login(c *gin.Context) {
     var loginData struct {
         Email    string `json:"email" binding:"required,email"`
@@ -456,11 +522,25 @@ func (h *UserHandler) Login(c *gin.Context) {
         return
     }
 
+    // Refuse to check the password while the account or IP is throttled
+    ctx := context.Background()
+    retryAfter, err := h.loginThrottle.Check(ctx, loginData.Email, c.ClientIP())
+    if err == services.ErrLoginThrottled {
+        c.Header("Retry-After", strconv.Itoa(int(retryAfter.Seconds())+1))
+        c.JSON(http.StatusTooManyRequests, gin.H{"error": "Too many failed login attempts, please try again later"})
+        return
+    }
+    if err != nil {
+        c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to check login attempts"})
+        return
+    }
+
     // Find user by email
     var user models.User
-    err := h.collection.FindOne(context.Background(), bson.M{"email": loginData.Email}).Decode(&user)
+    err = h.collection.FindOne(ctx, bson.M{"email": loginData.Email}).Decode(&user)
     if err != nil {
         if err == mongo.ErrNoDocuments {
+            h.recordLoginFailure(c, loginData.Email, nil)
             c.JSON(http.StatusUnauthorized, gin.H{"error": "Invalid email or password"})
             return
         }
@@ -470,32 +550,75 @@ func (h *UserHandler) Login(c *gin.Context) {
 
     // Compare passwords
     if err := bcrypt.CompareHashAndPassword([]byte(user.Password), []byte(loginData.Password)); err != nil {
+        h.recordLoginFailure(c, loginData.Email, &user)
         c.JSON(http.StatusUnauthorized, gin.H{"error": "Invalid email or password"})
         return
     }
 
-    // Generate JWT token
-    token := jwt.NewWithClaims(jwt.SigningMethodHS256, jwt.MapClaims{
-        "id":    user.ID.Hex(),
-        "email": user.Email,
-        "role":  user.Role,
-        "exp":   time.Now().Add(24 * time.Hour).Unix(),
-    })
+    if err := h.loginThrottle.RecordSuccess(ctx, loginData.Email); err != nil {
+        log.Printf("Failed to reset login attempts for user %s: %v", user.ID.Hex(), err)
+    }
+
+    h.loginUser(c, &user)
+}
+
+// loginUser finishes the first login step: accounts with two-factor
+// authentication get a challenge, everyone else gets a session
+func (h *UserHandler) loginUser(c *gin.Context, user *models.User) {
+    if user.TwoFactorEnabled {
+        challenge, err := h.tokenService.IssueActionToken(services.PurposeTwoFactorChallenge, user.ID, user.Email, twoFactorChallengeTTL)
+        if err != nil {
+            c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to generate token"})
+            return
+        }
+
+        c.JSON(http.StatusOK, gin.H{
+            "two_factor_required": true,
+            "challenge_token":     challenge,
+            "expires_in":          int(twoFactorChallengeTTL.Seconds()),
+        })
+        return
+    }
+
+    h.completeLogin(c, user)
+}
+
+// completeLogin starts a session for an authenticated user and responds with
+// the token pair
+func (h *UserHandler) completeLogin(c *gin.Context, user *models.User) {
+    session, refreshToken, err := h.sessionService.Create(context.Background(), user.ID, c.Request.UserAgent(), c.ClientIP())
+    if err != nil {
+        c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to create session"})
+        return
+    }
 
-    tokenString, err := token.SignedString([]byte(h.jwtSecret))
+    tokenString, err := h.tokenService.IssueAccessToken(user, session.ID.Hex())
     if err != nil {
         c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to generate token"})
         return
     }
 
+    enrollmentRequired := false
+    if !user.TwoFactorEnabled {
+        enrollmentRequired, err = h.twoFactorPolicy.Requires(context.Background(), user.Role)
+        if err != nil {
+            log.Printf("Failed to check two-factor policy: %v", err)
+        }
+    }
+
     c.JSON(http.StatusOK, gin.H{
-        "token": tokenString,
+        "token":                          tokenString,
+        "refresh_token":                  refreshToken,
+        "expires_in":                     int(h.tokenService.AccessTokenTTL().Seconds()),
+        "two_factor_enrollment_required": enrollmentRequired,
         "user": gin.H{
-            "id":       user.ID,
-            "username": user.Username,
-            "email":    user.Email,
-            "role":     user.Role,
-            "profile":  user.Profile,
+            "id":                 user.ID,
+            "username":           user.Username,
+            "email":              user.Email,
+            "role":               user.Role,
+            "email_verified":     user.EmailVerified,
+            "two_factor_enabled": user.TwoFactorEnabled,
+            "profile":            user.Profile,
         },
     })
 }
@@ -522,19 +645,8 @@ func (h *UserHandler) RequestPasswordReset(c *gin.Context) {
         return
     }
 
-    // Generate reset token
-    token := primitive.NewObjectID().Hex()
-    resetToken := models.PasswordResetToken{
-        ID:        primitive.NewObjectID(),
-        UserID:    user.ID,
-        Token:     token,
-        ExpiresAt: time.Now().Add(1 * time.Hour),
-        Used:      false,
-        CreatedAt: time.Now(),
-    }
-
-    // Save reset token
-    _, err = h.collection.InsertOne(ctx, resetToken)
+    // Generate reset token, replacing any earlier one
+    token, err := h.passwordResets.Create(ctx, user.ID)
     if err != nil {
         c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to create reset token"})
         return
@@ -544,7 +656,11 @@ func (h *UserHandler) RequestPasswordReset(c *gin.Context) {
     resetLink := fmt.Sprintf("%s/reset-password?token=%s", h.baseURL, token)
 
     // Send reset email
-    err = h.emailService.SendPasswordResetEmail(user.Email, resetLink)
+    err = h.emailService.Send(user.Email, user.Locale, services.TemplatePasswordReset, services.TemplateData{
+        "Username":         user.Username,
+        "Link":             resetLink,
+        "ExpiresInMinutes": int(h.passwordResets.TTL().Minutes()),
+    })
     if err != nil {
         c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to send reset email"})
         return
@@ -553,6 +669,28 @@ func (h *UserHandler) RequestPasswordReset(c *gin.Context) {
     c.JSON(http.StatusOK, gin.H{"message": "If the email exists, a reset link will be sent"})
 }
 
+// MigratePasswordResetTokens removes reset tokens that older versions stored
+// in plaintext in the users collection.
+func (h *UserHandler) MigratePasswordResetTokens(ctx context.Context) error {
+    _, err := h.collection.DeleteMany(ctx, bson.M{
+        "token":    bson.M{"$exists": true},
+        "user_id":  bson.M{"$exists": true},
+        "username": bson.M{"$exists": false},
+    })
+    return err
+}
+
+// MigrateUserRoles gives a role to accounts registered without one, which
+// would otherwise be denied every protected route.
+func (h *UserHandler) MigrateUserRoles(ctx context.Context) error {
+    _, err := h.collection.UpdateMany(
+        ctx,
+        bson.M{"$or": []bson.M{{"role": bson.M{"$exists": false}}, {"role": ""}}},
+        bson.M{"$set": bson.M{"role": constants.RoleReader}},
+    )
+    return err
+}
+
 // ResetPassword handles the actual password reset
 func (h *UserHandler) ResetPassword(c *gin.Context) {
     var req ResetPasswordRequest
@@ -561,32 +699,45 @@ func (h *UserHandler) ResetPassword(c *gin.Context) {
         return
     }
 
+    // Check the new password before consuming the token so it is not wasted on failure
     ctx := context.Background()
-
-    // Find and validate reset token
-    var resetToken models.PasswordResetToken
-    err := h.collection.FindOne(ctx, bson.M{
-        "token": req.Token,
-        "used":  false,
-        "expires_at": bson.M{"$gt": time.Now()},
-    }).Decode(&resetToken)
-
+    pending, err := h.passwordResets.Lookup(ctx, req.Token)
+    if err == services.ErrInvalidResetToken {
+        c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid or expired reset token"})
+        return
+    }
     if err != nil {
-        if err == mongo.ErrNoDocuments {
-            c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid or expired reset token"})
-            return
-        }
         c.JSON(http.StatusInternalServerError, gin.H{"error": "Database error"})
         return
     }
 
-    // Hash new password
+    var user models.User
+    if err := h.collection.FindOne(ctx, bson.M{"_id": pending.UserID}).Decode(&user); err != nil {
+        c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid or expired reset token"})
+        return
+    }
+
+    if !h.checkPasswordPolicy(c, req.Password, user.Username, user.Email) {
+        return
+    }
+
     hashedPassword, err := bcrypt.GenerateFromPassword([]byte(req.Password), bcrypt.DefaultCost)
     if err != nil {
         c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to hash password"})
         return
     }
 
+    // Atomically validate and use up the reset token
+    resetToken, err := h.passwordResets.Consume(ctx, req.Token)
+    if err == services.ErrInvalidResetToken {
+        c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid or expired reset token"})
+        return
+    }
+    if err != nil {
+        c.JSON(http.StatusInternalServerError, gin.H{"error": "Database error"})
+        return
+    }
+
     // Update user's password
     _, err = h.collection.UpdateOne(
         ctx,
@@ -601,15 +752,9 @@ func (h *UserHandler) ResetPassword(c *gin.Context) {
         return
     }
 
-    // Mark reset token as used
-    _, err = h.collection.UpdateOne(
-        ctx,
-        bson.M{"_id": resetToken.ID},
-        bson.M{"$set": bson.M{"used": true}},
-    )
-    if err != nil {
-        // Log this error but don't return it to the user since the password was updated
-        log.Printf("Failed to mark reset token as used: %v", err)
+    // Whoever requested the reset may not be the only one holding the old password
+    if _, err := h.sessionService.RevokeAll(ctx, resetToken.UserID); err != nil {
+        log.Printf("Failed to revoke sessions after password reset for user %s: %v", resetToken.UserID.Hex(), err)
     }
 
     c.JSON(http.StatusOK, gin.H{"message": "Password has been reset successfully"})
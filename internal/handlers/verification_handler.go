@@ -0,0 +1,217 @@
+package handlers
+
+import (
+    "context"
+    "fmt"
+    "log"
+    "net/http"
+    "strconv"
+    "time"
+
+    "github.com/gin-gonic/gin"
+    "go.mongodb.org/mongo-driver/bson"
+    "go.mongodb.org/mongo-driver/bson/primitive"
+    "go.mongodb.org/mongo-driver/mongo"
+
+    "go-blog-platform/internal/middleware"
+    "go-blog-platform/internal/models"
+    "go-blog-platform/internal/services"
+)
+
+const (
+    verificationTokenTTL       = 48 * time.Hour
+    verificationResendCooldown = 2 * time.Minute
+)
+
+type VerifyEmailRequest struct {
+    Token string `json:"token" binding:"required"`
+}
+
+// VerifyEmail confirms the email address from a verification link
+func (h *UserHandler) VerifyEmail(c *gin.Context) {
+    var req VerifyEmailRequest
+    if err := c.ShouldBindJSON(&req); err != nil {
+        c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+        return
+    }
+
+    claims, err := h.tokenService.ParseActionToken(services.PurposeEmailVerification, req.Token)
+    if err != nil {
+        c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid or expired verification link"})
+        return
+    }
+
+    userID, err := primitive.ObjectIDFromHex(claims.Subject)
+    if err != nil {
+        c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid or expired verification link"})
+        return
+    }
+
+    // The link is only valid for the address it was sent to
+    ctx := context.Background()
+    now := time.Now()
+    var user models.User
+    err = h.collection.FindOneAndUpdate(
+        ctx,
+        bson.M{"_id": userID, "email": claims.Email, "email_verified": false},
+        bson.M{"$set": bson.M{
+            "email_verified":    true,
+            "email_verified_at": now,
+            "updated_at":        now,
+        }},
+    ).Decode(&user)
+    if err == mongo.ErrNoDocuments {
+        // Following the link twice is fine as long as it is still for the current address
+        count, err := h.collection.CountDocuments(ctx, bson.M{"_id": userID, "email": claims.Email})
+        if err != nil {
+            c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to verify email"})
+            return
+        }
+        if count == 0 {
+            c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid or expired verification link"})
+            return
+        }
+        c.JSON(http.StatusOK, gin.H{"message": "Email verified successfully"})
+        return
+    }
+    if err != nil {
+        c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to verify email"})
+        return
+    }
+
+    // Welcome the user the first time the address is confirmed
+    err = h.emailService.Send(user.Email, user.Locale, services.TemplateWelcome, services.TemplateData{
+        "Username": user.Username,
+        "Link":     h.baseURL,
+    })
+    if err != nil {
+        log.Printf("Failed to send welcome email to user %s: %v", user.ID.Hex(), err)
+    }
+
+    c.JSON(http.StatusOK, gin.H{"message": "Email verified successfully"})
+}
+
+// ResendVerificationEmail sends a new verification link to the current user,
+// at most once per cooldown period
+func (h *UserHandler) ResendVerificationEmail(c *gin.Context) {
+    principal, exists := middleware.CurrentPrincipal(c)
+    if !exists {
+        c.JSON(http.StatusUnauthorized, gin.H{"error": "User not authenticated"})
+        return
+    }
+
+    ctx := context.Background()
+    var user models.User
+    err := h.collection.FindOne(ctx, bson.M{"_id": principal.UserID}).Decode(&user)
+    if err != nil {
+        c.JSON(http.StatusNotFound, gin.H{"error": "User not found"})
+        return
+    }
+
+    if user.EmailVerified {
+        c.JSON(http.StatusBadRequest, gin.H{"error": "Email is already verified"})
+        return
+    }
+
+    // Claim the send slot atomically so concurrent requests cannot bypass the cooldown
+    now := time.Now()
+    result, err := h.collection.UpdateOne(
+        ctx,
+        bson.M{
+            "_id":            user.ID,
+            "email_verified": false,
+            "$or": []bson.M{
+                {"verification_sent_at": nil},
+                {"verification_sent_at": bson.M{"$lte": now.Add(-verificationResendCooldown)}},
+            },
+        },
+        bson.M{"$set": bson.M{"verification_sent_at": now}},
+    )
+    if err != nil {
+        c.JSON(http.StatusInternalServerError, gin.H{"error": "Database error"})
+        return
+    }
+
+    if result.MatchedCount == 0 {
+        retryAfter := verificationResendCooldown
+        if user.VerificationSentAt != nil {
+            retryAfter = time.Until(user.VerificationSentAt.Add(verificationResendCooldown))
+        }
+        c.Header("Retry-After", strconv.Itoa(int(retryAfter.Seconds())+1))
+        c.JSON(http.StatusTooManyRequests, gin.H{"error": "Please wait before requesting another verification email"})
+        return
+    }
+
+    if err := h.sendVerificationEmail(&user); err != nil {
+        c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to send verification email"})
+        return
+    }
+
+    c.JSON(http.StatusOK, gin.H{"message": "Verification email sent"})
+}
+
+type SetEmailVerificationRequest struct {
+    Verified *bool `json:"verified" binding:"required"`
+}
+
+// SetEmailVerification overrides a user's verification status (admin only)
+func (h *UserHandler) SetEmailVerification(c *gin.Context) {
+    userID, err := primitive.ObjectIDFromHex(c.Param("id"))
+    if err != nil {
+        c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid user ID"})
+        return
+    }
+
+    var req SetEmailVerificationRequest
+    if err := c.ShouldBindJSON(&req); err != nil {
+        c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+        return
+    }
+
+    now := time.Now()
+    update := bson.M{
+        "$set": bson.M{"email_verified": *req.Verified, "updated_at": now},
+    }
+    if *req.Verified {
+        update["$set"].(bson.M)["email_verified_at"] = now
+    } else {
+        update["$unset"] = bson.M{"email_verified_at": ""}
+    }
+
+    result, err := h.collection.UpdateOne(context.Background(), bson.M{"_id": userID}, update)
+    if err != nil {
+        c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to update email verification"})
+        return
+    }
+
+    if result.MatchedCount == 0 {
+        c.JSON(http.StatusNotFound, gin.H{"error": "User not found"})
+        return
+    }
+
+    c.JSON(http.StatusOK, gin.H{"message": "Email verification updated successfully"})
+}
+
+// MigrateEmailVerification marks accounts created before email verification
+// existed as verified, so they are not locked out of posting.
+func (h *UserHandler) MigrateEmailVerification(ctx context.Context) error {
+    _, err := h.collection.UpdateMany(
+        ctx,
+        bson.M{"email_verified": bson.M{"$exists": false}},
+        bson.M{"$set": bson.M{"email_verified": true}},
+    )
+    return err
+}
+
+func (h *UserHandler) sendVerificationEmail(user *models.User) error {
+    token, err := h.tokenService.IssueActionToken(services.PurposeEmailVerification, user.ID, user.Email, verificationTokenTTL)
+    if err != nil {
+        return err
+    }
+
+    link := fmt.Sprintf("%s/verify-email?token=%s", h.baseURL, token)
+    return h.emailService.Send(user.Email, user.Locale, services.TemplateEmailVerification, services.TemplateData{
+        "Username": user.Username,
+        "Link":     link,
+    })
+}
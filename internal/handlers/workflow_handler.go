@@ -0,0 +1,288 @@
+package handlers
+
+import (
+	"context"
+	"errors"
+	"io"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+
+	"go-blog-platform/internal/constants"
+	"go-blog-platform/internal/middleware"
+	"go-blog-platform/internal/models"
+	"go-blog-platform/internal/services"
+)
+
+type TransitionRequest struct {
+	Comment     string     `json:"comment" binding:"max=2000"`
+	PublishAt   *time.Time `json:"publish_at"`
+	UnpublishAt *time.Time `json:"unpublish_at"`
+}
+
+type UnpublishAtRequest struct {
+	UnpublishAt *time.Time `json:"unpublish_at"`
+}
+
+// Transition returns a handler that moves a post through the review
+// workflow with the given action
+func (h *PostHandler) Transition(action string) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		// The body is optional
+		var req TransitionRequest
+		if err := c.ShouldBindJSON(&req); err != nil && !errors.Is(err, io.EOF) {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+
+		post, principal, ok := h.loadPost(c)
+		if !ok {
+			return
+		}
+
+		transition, err := services.FindPostTransition(action, post.Status)
+		if err != nil {
+			c.JSON(http.StatusConflict, gin.H{"error": "Cannot " + action + " a post that is " + post.Status})
+			return
+		}
+
+		if transition.Permission != "" && !principal.HasPermission(transition.Permission) {
+			c.JSON(http.StatusForbidden, gin.H{"error": "Insufficient permissions"})
+			return
+		}
+		if transition.ByReviewer {
+			role := post.RoleOf(principal.UserID)
+			if role == constants.PostRoleAuthor || role == constants.PostRoleCoAuthor {
+				c.JSON(http.StatusForbidden, gin.H{"error": "You cannot review your own post"})
+				return
+			}
+		} else if !canEditPost(principal, post) {
+			c.JSON(http.StatusForbidden, gin.H{"error": "You can only modify your own resources"})
+			return
+		}
+
+		comment := strings.TrimSpace(req.Comment)
+		if transition.RequiresComment && comment == "" {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "A comment for the authors is required"})
+			return
+		}
+
+		now := time.Now()
+		if transition.To == constants.PostStatusScheduled && (req.PublishAt == nil || !req.PublishAt.After(now)) {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "publish_at must be in the future"})
+			return
+		}
+		if req.UnpublishAt != nil {
+			publishAt := now
+			if transition.To == constants.PostStatusScheduled {
+				publishAt = *req.PublishAt
+			}
+			if !canUnpublishAt(c, transition.To, publishAt, *req.UnpublishAt) {
+				return
+			}
+		}
+
+		set := bson.M{"status": transition.To, "updated_at": now}
+		unset := bson.M{}
+		switch transition.To {
+		case constants.PostStatusInReview:
+			set["submitted_at"] = now
+			unset["review_comment"] = ""
+		case constants.PostStatusRejected:
+			set["review_comment"] = comment
+		case constants.PostStatusScheduled:
+			set["publish_at"] = *req.PublishAt
+		case constants.PostStatusPublished:
+			if post.PublishedAt == nil {
+				set["published_at"] = now
+			}
+			unset["publish_at"] = ""
+		case constants.PostStatusDraft:
+			unset["publish_at"] = ""
+			unset["unpublish_at"] = ""
+		}
+		if req.UnpublishAt != nil {
+			set["unpublish_at"] = *req.UnpublishAt
+		}
+
+		update := bson.M{
+			"$set": set,
+			"$push": bson.M{"status_history": models.StatusChange{
+				Action:  action,
+				From:    post.Status,
+				To:      transition.To,
+				By:      &principal.UserID,
+				Comment: comment,
+				At:      now,
+			}},
+		}
+		if len(unset) > 0 {
+			update["$unset"] = unset
+		}
+
+		// Only apply the step if nobody moved the post in the meantime
+		ctx := context.Background()
+		var updated models.Post
+		err = h.collection.FindOneAndUpdate(
+			ctx,
+			bson.M{"_id": post.ID, "status": post.Status},
+			update,
+			options.FindOneAndUpdate().SetReturnDocument(options.After),
+		).Decode(&updated)
+		if err == mongo.ErrNoDocuments {
+			c.JSON(http.StatusConflict, gin.H{"error": "The post's status has changed, reload it and try again"})
+			return
+		}
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to update post status"})
+			return
+		}
+
+		if err := h.attachAuthors(ctx, &updated); err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch authors"})
+			return
+		}
+
+		c.JSON(http.StatusOK, updated)
+	}
+}
+
+// SetUnpublishAt sets or, with a null unpublish_at, clears the time at which
+// a scheduled or published post is archived
+func (h *PostHandler) SetUnpublishAt(c *gin.Context) {
+	var req UnpublishAtRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	post, principal, ok := h.loadPost(c)
+	if !ok {
+		return
+	}
+	if !canEditPost(principal, post) {
+		c.JSON(http.StatusForbidden, gin.H{"error": "You can only modify your own resources"})
+		return
+	}
+
+	now := time.Now()
+	update := bson.M{"$unset": bson.M{"unpublish_at": ""}, "$set": bson.M{"updated_at": now}}
+	if req.UnpublishAt != nil {
+		publishAt := now
+		if post.PublishAt != nil {
+			publishAt = *post.PublishAt
+		}
+		if !canUnpublishAt(c, post.Status, publishAt, *req.UnpublishAt) {
+			return
+		}
+		update = bson.M{"$set": bson.M{"unpublish_at": *req.UnpublishAt, "updated_at": now}}
+	}
+
+	ctx := context.Background()
+	var updated models.Post
+	err := h.collection.FindOneAndUpdate(
+		ctx,
+		bson.M{"_id": post.ID, "status": post.Status},
+		update,
+		options.FindOneAndUpdate().SetReturnDocument(options.After),
+	).Decode(&updated)
+	if err == mongo.ErrNoDocuments {
+		c.JSON(http.StatusConflict, gin.H{"error": "The post's status has changed, reload it and try again"})
+		return
+	}
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to update post"})
+		return
+	}
+
+	if err := h.attachAuthors(ctx, &updated); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch authors"})
+		return
+	}
+
+	c.JSON(http.StatusOK, updated)
+}
+
+// canUnpublishAt responds with an error and returns false unless a post with
+// the given status, going live at publishAt, may be archived at unpublishAt
+func canUnpublishAt(c *gin.Context, status string, publishAt, unpublishAt time.Time) bool {
+	if status != constants.PostStatusScheduled && status != constants.PostStatusPublished {
+		c.JSON(http.StatusConflict, gin.H{"error": "Only scheduled and published posts can be unpublished at a set time"})
+		return false
+	}
+	if !unpublishAt.After(publishAt) || !unpublishAt.After(time.Now()) {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "unpublish_at must be in the future and after the publish time"})
+		return false
+	}
+	return true
+}
+
+// ReviewQueue lists posts awaiting review, oldest submission first
+func (h *PostHandler) ReviewQueue(c *gin.Context) {
+	ctx := context.Background()
+	opts := options.Find().SetSort(bson.D{{Key: "submitted_at", Value: 1}})
+	cursor, err := h.collection.Find(ctx, bson.M{"status": constants.PostStatusInReview}, opts)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch review queue"})
+		return
+	}
+	defer cursor.Close(ctx)
+
+	posts := []models.Post{}
+	if err := cursor.All(ctx, &posts); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to decode review queue"})
+		return
+	}
+
+	if err := h.attachAuthors(ctx, postRefs(posts)...); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch authors"})
+		return
+	}
+
+	c.JSON(http.StatusOK, posts)
+}
+
+// StatusHistory returns who moved the post between statuses, and when, to
+// its authors, collaborators and reviewers
+func (h *PostHandler) StatusHistory(c *gin.Context) {
+	post, principal, ok := h.loadPost(c)
+	if !ok {
+		return
+	}
+
+	if !canViewPost(principal, post) {
+		c.JSON(http.StatusForbidden, gin.H{"error": "You are not a collaborator on this post"})
+		return
+	}
+
+	history := post.StatusHistory
+	if history == nil {
+		history = []models.StatusChange{}
+	}
+
+	c.JSON(http.StatusOK, history)
+}
+
+// canChangeReviewedContent reports whether the principal may change the text
+// of a post that passed review without sending it through review again:
+// reviewers of posts they did not write, and anyone who may edit every post.
+// Other changes to approved, scheduled and published posts would skip review.
+func canChangeReviewedContent(principal *middleware.Principal, post *models.Post) bool {
+	switch post.Status {
+	case constants.PostStatusApproved, constants.PostStatusScheduled, constants.PostStatusPublished:
+	default:
+		return true
+	}
+
+	if principal.HasPermission(constants.PermPostEditAny) {
+		return true
+	}
+	role := post.RoleOf(principal.UserID)
+	return principal.HasPermission(constants.PermPostReview) &&
+		role != constants.PostRoleAuthor && role != constants.PostRoleCoAuthor
+}
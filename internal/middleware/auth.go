@@ -1,15 +1,41 @@
 package middleware
 
 import (
+    "context"
     "net/http"
     "strings"
 
     "github.com/gin-gonic/gin"
-    "github.com/golang-jwt/jwt/v5"
-    "go-blog-platform/internal/constants"
+    "go.mongodb.org/mongo-driver/bson/primitive"
+
+    "go-blog-platform/internal/models"
+    "go-blog-platform/internal/services"
 )
 
-func AuthMiddleware(jwtSecret []byte) gin.HandlerFunc {
+// SessionChecker reports whether a login session is still active.
+type SessionChecker interface {
+    IsActive(ctx context.Context, sessionID string) (bool, error)
+}
+
+// APIKeyAuthenticator verifies personal API keys.
+type APIKeyAuthenticator interface {
+    Authenticate(ctx context.Context, key string) (*models.APIKey, *models.User, error)
+}
+
+// PermissionResolver looks up the permissions granted to a role.
+type PermissionResolver interface {
+    Permissions(ctx context.Context, role string) ([]string, error)
+}
+
+// AccountStateLoader loads the current state of a user's account, for flags
+// that may have changed since the access token was issued.
+type AccountStateLoader interface {
+    AccountState(ctx context.Context, userID primitive.ObjectID) (emailVerified, twoFactor bool, err error)
+}
+
+// AuthMiddleware authenticates the request with either a session access token
+// or a personal API key in the Authorization header
+func AuthMiddleware(tokens *services.TokenService, sessions SessionChecker, apiKeys APIKeyAuthenticator, roles PermissionResolver) gin.HandlerFunc {
     return func(c *gin.Context) {
         authHeader := c.GetHeader("Authorization")
         if authHeader == "" {
@@ -25,60 +51,131 @@ func AuthMiddleware(jwtSecret []byte) gin.HandlerFunc {
             return
         }
 
-        tokenString := parts[1]
+        if services.IsAPIKey(parts[1]) {
+            key, user, err := apiKeys.Authenticate(c.Request.Context(), parts[1])
+            if err == services.ErrInvalidAPIKey {
+                c.JSON(http.StatusUnauthorized, gin.H{"error": "Invalid API key"})
+                c.Abort()
+                return
+            }
+            if err != nil {
+                c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to verify API key"})
+                c.Abort()
+                return
+            }
+
+            permissions, err := roles.Permissions(c.Request.Context(), user.Role)
+            if err != nil {
+                c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to load permissions"})
+                c.Abort()
+                return
+            }
 
-        token, err := jwt.Parse(tokenString, func(token *jwt.Token) (interface{}, error) {
-            return jwtSecret, nil
-        })
+            setPrincipal(c, &Principal{
+                UserID:        user.ID,
+                Email:         user.Email,
+                EmailVerified: user.EmailVerified,
+                Role:          user.Role,
+                TwoFactor:     user.TwoFactorEnabled,
+                Permissions:   permissions,
+                APIKeyID:      key.ID,
+                Scopes:        key.Scopes,
+            })
+
+            c.Next()
+            return
+        }
 
-        if err != nil || !token.Valid {
+        claims, err := tokens.ParseAccessToken(parts[1])
+        if err != nil {
             c.JSON(http.StatusUnauthorized, gin.H{"error": "Invalid token"})
             c.Abort()
             return
         }
 
-        claims, ok := token.Claims.(jwt.MapClaims)
-        if !ok {
+        userID, err := primitive.ObjectIDFromHex(claims.Subject)
+        if err != nil {
             c.JSON(http.StatusUnauthorized, gin.H{"error": "Invalid token claims"})
             c.Abort()
             return
         }
 
-        c.Set("user_id", claims["user_id"])
-        c.Set("email", claims["email"])
-        c.Set("role", claims["role"])
+        active, err := sessions.IsActive(c.Request.Context(), claims.SessionID)
+        if err != nil {
+            c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to verify session"})
+            c.Abort()
+            return
+        }
+        if !active {
+            c.JSON(http.StatusUnauthorized, gin.H{"error": "Session has been revoked"})
+            c.Abort()
+            return
+        }
+
+        // Looked up on every request so that changes to a role's permissions
+        // apply at once. Changing a user's role revokes their sessions, so the
+        // role in the token is current.
+        permissions, err := roles.Permissions(c.Request.Context(), claims.Role)
+        if err != nil {
+            c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to load permissions"})
+            c.Abort()
+            return
+        }
+
+        setPrincipal(c, &Principal{
+            UserID:        userID,
+            Email:         claims.Email,
+            EmailVerified: claims.EmailVerified,
+            Role:          claims.Role,
+            TwoFactor:     claims.TwoFactor,
+            SessionID:     claims.SessionID,
+            Permissions:   permissions,
+        })
 
         c.Next()
     }
 }
 
-// RequireRole middleware checks if the user has the required role or higher
-func RequireRole(requiredRole string) gin.HandlerFunc {
+// RequireSession blocks API keys from routes that manage the account itself,
+// such as creating API keys
+func RequireSession() gin.HandlerFunc {
     return func(c *gin.Context) {
-        userRole, exists := c.Get("role")
+        principal, exists := CurrentPrincipal(c)
         if !exists {
             c.JSON(http.StatusUnauthorized, gin.H{"error": "Unauthorized"})
             c.Abort()
             return
         }
 
-        allowedRoles, exists := constants.RoleHierarchy[userRole.(string)]
-        if !exists {
-            c.JSON(http.StatusForbidden, gin.H{"error": "Invalid role"})
+        if principal.IsAPIKey() {
+            c.JSON(http.StatusForbidden, gin.H{"error": "This endpoint cannot be used with an API key"})
             c.Abort()
             return
         }
 
-        hasPermission := false
-        for _, role := range allowedRoles {
-            if role == requiredRole {
-                hasPermission = true
-                break
-            }
+        c.Next()
+    }
+}
+
+// EnforceAPIKeyScopes limits API keys that have scopes. routeScopes maps
+// "METHOD /route/pattern" to the scope a key needs for that route; other
+// routes are read-only for scoped keys.
+func EnforceAPIKeyScopes(routeScopes map[string]string) gin.HandlerFunc {
+    return func(c *gin.Context) {
+        principal, exists := CurrentPrincipal(c)
+        if !exists || !principal.IsAPIKey() || len(principal.Scopes) == 0 {
+            c.Next()
+            return
         }
 
-        if !hasPermission {
-            c.JSON(http.StatusForbidden, gin.H{"error": "Insufficient permissions"})
+        scope, listed := routeScopes[c.Request.Method+" "+c.FullPath()]
+        allowed := principal.HasScope(scope)
+        if !listed {
+            allowed = c.Request.Method == http.MethodGet || c.Request.Method == http.MethodHead
+        }
+
+        if !allowed {
+            c.JSON(http.StatusForbidden, gin.H{"error": "API key does not have the required scope"})
             c.Abort()
             return
         }
@@ -87,37 +184,117 @@ func RequireRole(requiredRole string) gin.HandlerFunc {
     }
 }
 
-// IsAdmin is a convenience middleware for admin-only routes
-func IsAdmin() gin.HandlerFunc {
-    return RequireRole(constants.RoleAdmin)
+// AuthorizeRoutes applies RequirePermission to every route from a permission
+// table mapping "METHOD /route/pattern" to the permission needed for that
+// route. Routes missing from the table are denied, so a new route cannot go
+// live without a decision about who may call it.
+func AuthorizeRoutes(permissions map[string]string) gin.HandlerFunc {
+    checks := make(map[string]gin.HandlerFunc, len(permissions))
+    for route, permission := range permissions {
+        checks[route] = RequirePermission(permission)
+    }
+
+    return func(c *gin.Context) {
+        check, listed := checks[c.Request.Method+" "+c.FullPath()]
+        if !listed {
+            c.JSON(http.StatusForbidden, gin.H{"error": "Insufficient permissions"})
+            c.Abort()
+            return
+        }
+
+        check(c)
+    }
 }
 
-// IsAuthorOrAdmin is a convenience middleware for routes that require author or admin privileges
-func IsAuthorOrAdmin() gin.HandlerFunc {
+// TwoFactorRequirement reports whether a role must use two-factor authentication.
+type TwoFactorRequirement interface {
+    Requires(ctx context.Context, role string) (bool, error)
+}
+
+// RequireTwoFactor blocks users whose role requires two-factor authentication
+// until they have enrolled. Enrollment is checked against the account as well
+// as the access token, so it applies before the token is refreshed.
+func RequireTwoFactor(policy TwoFactorRequirement, accounts AccountStateLoader) gin.HandlerFunc {
     return func(c *gin.Context) {
-        userRole, exists := c.Get("role")
+        principal, exists := CurrentPrincipal(c)
         if !exists {
             c.JSON(http.StatusUnauthorized, gin.H{"error": "Unauthorized"})
             c.Abort()
             return
         }
 
-        allowedRoles, exists := constants.RoleHierarchy[userRole.(string)]
+        if !principal.TwoFactor {
+            required, err := policy.Requires(c.Request.Context(), principal.Role)
+            if err != nil {
+                c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to check two-factor policy"})
+                c.Abort()
+                return
+            }
+            if required {
+                _, enrolled, err := accounts.AccountState(c.Request.Context(), principal.UserID)
+                if err != nil {
+                    c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to check two-factor status"})
+                    c.Abort()
+                    return
+                }
+                principal.TwoFactor = enrolled
+            }
+            if required && !principal.TwoFactor {
+                c.JSON(http.StatusForbidden, gin.H{
+                    "error":                          "Two-factor authentication is required for your role",
+                    "two_factor_enrollment_required": true,
+                })
+                c.Abort()
+                return
+            }
+        }
+
+        c.Next()
+    }
+}
+
+// RequireVerifiedEmail blocks users who have not confirmed their email
+// address. An address verified since the access token was issued counts.
+func RequireVerifiedEmail(accounts AccountStateLoader) gin.HandlerFunc {
+    return func(c *gin.Context) {
+        principal, exists := CurrentPrincipal(c)
         if !exists {
-            c.JSON(http.StatusForbidden, gin.H{"error": "Invalid role"})
+            c.JSON(http.StatusUnauthorized, gin.H{"error": "Unauthorized"})
             c.Abort()
             return
         }
 
-        hasPermission := false
-        for _, role := range allowedRoles {
-            if role == constants.RoleAdmin || role == constants.RoleAuthor {
-                hasPermission = true
-                break
+        if !principal.EmailVerified {
+            verified, _, err := accounts.AccountState(c.Request.Context(), principal.UserID)
+            if err != nil {
+                c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to check email verification"})
+                c.Abort()
+                return
             }
+            principal.EmailVerified = verified
+        }
+
+        if !principal.EmailVerified {
+            c.JSON(http.StatusForbidden, gin.H{"error": "Email address has not been verified"})
+            c.Abort()
+            return
+        }
+
+        c.Next()
+    }
+}
+
+// RequirePermission middleware checks if the user's role grants the permission
+func RequirePermission(permission string) gin.HandlerFunc {
+    return func(c *gin.Context) {
+        principal, exists := CurrentPrincipal(c)
+        if !exists {
+            c.JSON(http.StatusUnauthorized, gin.H{"error": "Unauthorized"})
+            c.Abort()
+            return
         }
 
-        if !hasPermission {
+        if !principal.HasPermission(permission) {
             c.JSON(http.StatusForbidden, gin.H{"error": "Insufficient permissions"})
             c.Abort()
             return
@@ -0,0 +1,60 @@
+package middleware
+
+import (
+    "context"
+    "errors"
+    "net/http"
+
+    "github.com/gin-gonic/gin"
+    "go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+// ErrResourceNotFound is returned by an OwnerResolver for unknown resources
+var ErrResourceNotFound = errors.New("resource not found")
+
+// OwnerResolver finds the user that owns the resource with the given ID
+type OwnerResolver interface {
+    OwnerOf(ctx context.Context, id string) (primitive.ObjectID, error)
+}
+
+// CanModify reports whether the principal may change or delete a resource
+// owned by ownerID: owners need ownPermission, everyone else anyPermission
+func (p *Principal) CanModify(ownerID primitive.ObjectID, ownPermission, anyPermission string) bool {
+    if p.UserID == ownerID && p.HasPermission(ownPermission) {
+        return true
+    }
+    return p.HasPermission(anyPermission)
+}
+
+// RequireOwnership lets a request through only if the principal may modify
+// the resource identified by the route parameter
+func RequireOwnership(resolver OwnerResolver, param, ownPermission, anyPermission string) gin.HandlerFunc {
+    return func(c *gin.Context) {
+        principal, exists := CurrentPrincipal(c)
+        if !exists {
+            c.JSON(http.StatusUnauthorized, gin.H{"error": "Unauthorized"})
+            c.Abort()
+            return
+        }
+
+        ownerID, err := resolver.OwnerOf(c.Request.Context(), c.Param(param))
+        if err == ErrResourceNotFound {
+            c.JSON(http.StatusNotFound, gin.H{"error": "Resource not found"})
+            c.Abort()
+            return
+        }
+        if err != nil {
+            c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to check permissions"})
+            c.Abort()
+            return
+        }
+
+        if !principal.CanModify(ownerID, ownPermission, anyPermission) {
+            c.JSON(http.StatusForbidden, gin.H{"error": "You can only modify your own resources"})
+            c.Abort()
+            return
+        }
+
+        c.Next()
+    }
+}
@@ -0,0 +1,76 @@
+package middleware
+
+import (
+    "github.com/gin-gonic/gin"
+    "go.mongodb.org/mongo-driver/bson/primitive"
+
+    "go-blog-platform/internal/constants"
+)
+
+const principalKey = "principal"
+
+// Principal is the authenticated caller of a request
+type Principal struct {
+    UserID        primitive.ObjectID
+    Email         string
+    EmailVerified bool
+    Role          string
+    TwoFactor     bool
+    SessionID     string
+
+    // Permissions are those of the principal's role when the request started
+    Permissions []string
+
+    // APIKeyID is set when the request was authenticated with an API key
+    // instead of a session token. Scopes, if any, limit what the key can do.
+    APIKeyID primitive.ObjectID
+    Scopes   []string
+}
+
+// IsAPIKey reports whether the principal authenticated with an API key
+func (p *Principal) IsAPIKey() bool {
+    return !p.APIKeyID.IsZero()
+}
+
+// HasPermission reports whether the principal's role grants the permission.
+// constants.PermAuthenticated is held by everyone.
+func (p *Principal) HasPermission(permission string) bool {
+    if permission == constants.PermAuthenticated {
+        return true
+    }
+    for _, perm := range p.Permissions {
+        if perm == permission {
+            return true
+        }
+    }
+    return false
+}
+
+// HasScope reports whether the principal may act within the scope. Session
+// tokens and API keys without scopes carry the user's full rights.
+func (p *Principal) HasScope(scope string) bool {
+    if !p.IsAPIKey() || len(p.Scopes) == 0 {
+        return true
+    }
+    for _, s := range p.Scopes {
+        if s == scope {
+            return true
+        }
+    }
+    return false
+}
+
+// CurrentPrincipal returns the principal set by AuthMiddleware
+func CurrentPrincipal(c *gin.Context) (*Principal, bool) {
+    value, exists := c.Get(principalKey)
+    if !exists {
+        return nil, false
+    }
+
+    principal, ok := value.(*Principal)
+    return principal, ok
+}
+
+func setPrincipal(c *gin.Context, principal *Principal) {
+    c.Set(principalKey, principal)
+}
@@ -0,0 +1,17 @@
+package models
+
+import (
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+// OIDCState remembers an authorization request until the provider redirects
+// back. It is keyed by the hash of the state parameter and used once.
+type OIDCState struct {
+	ID           string              `bson:"_id"`
+	Nonce        string              `bson:"nonce"`
+	CodeVerifier string              `bson:"code_verifier"`
+	LinkUserID   *primitive.ObjectID `bson:"link_user_id,omitempty"`
+	ExpiresAt    time.Time           `bson:"expires_at"`
+}
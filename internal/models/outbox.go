@@ -0,0 +1,37 @@
+package models
+
+import (
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+// Outbox message statuses
+const (
+	OutboxStatusPending = "pending"
+	OutboxStatusSending = "sending"
+	OutboxStatusFailed  = "failed"
+	OutboxStatusDead    = "dead"
+	OutboxStatusSent    = "sent"
+)
+
+// OutboxMessage is an email waiting to be delivered by the outbox worker.
+// Failed deliveries are retried with backoff until MaxAttempts is reached,
+// after which the message is dead-lettered.
+type OutboxMessage struct {
+	ID            primitive.ObjectID `bson:"_id,omitempty" json:"id,omitempty"`
+	From          string             `bson:"from" json:"from"`
+	To            []string           `bson:"to" json:"to"`
+	Subject       string             `bson:"subject" json:"subject"`
+	TextBody      string             `bson:"text_body,omitempty" json:"-"`
+	HTMLBody      string             `bson:"html_body,omitempty" json:"-"`
+	Status        string             `bson:"status" json:"status"`
+	Attempts      int                `bson:"attempts" json:"attempts"`
+	MaxAttempts   int                `bson:"max_attempts" json:"max_attempts"`
+	LastError     string             `bson:"last_error,omitempty" json:"last_error,omitempty"`
+	NextAttemptAt time.Time          `bson:"next_attempt_at" json:"next_attempt_at"`
+	LockedUntil   *time.Time         `bson:"locked_until,omitempty" json:"-"`
+	SentAt        *time.Time         `bson:"sent_at,omitempty" json:"sent_at,omitempty"`
+	CreatedAt     time.Time          `bson:"created_at" json:"created_at"`
+	UpdatedAt     time.Time          `bson:"updated_at" json:"updated_at"`
+}
@@ -8,8 +8,9 @@ import (
 type PasswordResetToken struct {
     ID        primitive.ObjectID `bson:"_id,omitempty" json:"id,omitempty"`
     UserID    primitive.ObjectID `bson:"user_id" json:"user_id"`
-    Token     string            `bson:"token" json:"token"`
+    TokenHash string            `bson:"token_hash" json:"-"`
     ExpiresAt time.Time         `bson:"expires_at" json:"expires_at"`
     Used      bool              `bson:"used" json:"used"`
+    UsedAt    *time.Time        `bson:"used_at,omitempty" json:"used_at,omitempty"`
     CreatedAt time.Time         `bson:"created_at" json:"created_at"`
 }
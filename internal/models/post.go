@@ -3,18 +3,79 @@ package models
 import (
 	"time"
 	"go.mongodb.org/mongo-driver/bson/primitive"
+
+	"go-blog-platform/internal/constants"
 )
 
 type Post struct {
 	ID           primitive.ObjectID `bson:"_id,omitempty" json:"id,omitempty"`
 	Title        string            `bson:"title" json:"title"`
-	Content      string            `bson:"content" json:"content"`
+	Slug         string            `bson:"slug,omitempty" json:"slug,omitempty"`
+	SlugHistory  []string          `bson:"slug_history,omitempty" json:"-"` // earlier slugs, redirected to the current one
+	Content      string            `bson:"content" json:"content"` // Markdown
+	ContentHTML  string            `bson:"content_html,omitempty" json:"content_html,omitempty"`
+	TOC          []TOCEntry        `bson:"toc,omitempty" json:"toc,omitempty"`
 	AuthorID     primitive.ObjectID `bson:"author_id" json:"author_id"`
-	Status       string            `bson:"status" json:"status"` // draft, published, archived
+	Status       string            `bson:"status" json:"status"` // draft, in_review, approved, rejected, scheduled, published, archived
+	ReviewComment string           `bson:"review_comment,omitempty" json:"review_comment,omitempty"`
+	SubmittedAt  *time.Time        `bson:"submitted_at,omitempty" json:"submitted_at,omitempty"`
+	StatusHistory []StatusChange   `bson:"status_history,omitempty" json:"-"`
 	Tags         []string          `bson:"tags,omitempty" json:"tags,omitempty"`
 	FeaturedImage *Media           `bson:"featured_image,omitempty" json:"featured_image,omitempty"`
 	Gallery      []*Media          `bson:"gallery,omitempty" json:"gallery,omitempty"`
+	Collaborators []Collaborator   `bson:"collaborators,omitempty" json:"collaborators,omitempty"`
+	Authors      []PostAuthor      `bson:"-" json:"authors,omitempty"`
 	CreatedAt    time.Time         `bson:"created_at" json:"created_at"`
 	UpdatedAt    time.Time         `bson:"updated_at" json:"updated_at"`
 	PublishedAt  *time.Time        `bson:"published_at,omitempty" json:"published_at,omitempty"`
+	PublishAt    *time.Time        `bson:"publish_at,omitempty" json:"publish_at,omitempty"`     // when a scheduled post goes live
+	UnpublishAt  *time.Time        `bson:"unpublish_at,omitempty" json:"unpublish_at,omitempty"` // when a published post is archived
+}
+
+// Collaborator is a user other than the author who was given a role on a post
+type Collaborator struct {
+	UserID   primitive.ObjectID `bson:"user_id" json:"user_id"`
+	Username string             `bson:"-" json:"username,omitempty"`
+	Role     string             `bson:"role" json:"role"` // co-author, editor, viewer
+	AddedBy  primitive.ObjectID `bson:"added_by" json:"added_by"`
+	AddedAt  time.Time          `bson:"added_at" json:"added_at"`
+}
+
+// StatusChange records who moved a post between workflow statuses, and when
+type StatusChange struct {
+	Action  string              `bson:"action" json:"action"`
+	From    string              `bson:"from,omitempty" json:"from,omitempty"`
+	To      string              `bson:"to" json:"to"`
+	By      *primitive.ObjectID `bson:"by,omitempty" json:"by,omitempty"` // nil for the scheduler
+	Comment string              `bson:"comment,omitempty" json:"comment,omitempty"`
+	At      time.Time           `bson:"at" json:"at"`
+}
+
+// TOCEntry is a heading of a post's content, linked by its anchor
+type TOCEntry struct {
+	Level int    `bson:"level" json:"level"`
+	ID    string `bson:"id" json:"id"`
+	Text  string `bson:"text" json:"text"`
+}
+
+// PostAuthor credits a user for a post in API responses
+type PostAuthor struct {
+	ID       primitive.ObjectID `json:"id"`
+	Username string             `json:"username"`
+	FullName string             `json:"full_name,omitempty"`
+	Role     string             `json:"role"` // author, co-author
+}
+
+// RoleOf returns the user's role on the post, or an empty string
+// if the user is neither its author nor a collaborator
+func (p *Post) RoleOf(userID primitive.ObjectID) string {
+	if p.AuthorID == userID {
+		return constants.PostRoleAuthor
+	}
+	for _, collaborator := range p.Collaborators {
+		if collaborator.UserID == userID {
+			return collaborator.Role
+		}
+	}
+	return ""
 }
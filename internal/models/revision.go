@@ -0,0 +1,22 @@
+package models
+
+import (
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+// PostRevision is an immutable snapshot of a post's text, stored each time
+// the post is created, updated or restored. Revisions are numbered from 1
+// per post.
+type PostRevision struct {
+	ID           primitive.ObjectID `bson:"_id,omitempty" json:"id,omitempty"`
+	PostID       primitive.ObjectID `bson:"post_id" json:"post_id"`
+	Number       int                `bson:"number" json:"number"`
+	Title        string             `bson:"title" json:"title"`
+	Content      string             `bson:"content" json:"content,omitempty"`
+	Tags         []string           `bson:"tags,omitempty" json:"tags,omitempty"`
+	EditorID     primitive.ObjectID `bson:"editor_id" json:"editor_id"`
+	RestoredFrom int                `bson:"restored_from,omitempty" json:"restored_from,omitempty"` // the revision this one restored
+	CreatedAt    time.Time          `bson:"created_at" json:"created_at"`
+}
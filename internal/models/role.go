@@ -0,0 +1,13 @@
+package models
+
+import "time"
+
+// Role is a named set of permissions assigned to users
+type Role struct {
+	Name        string    `bson:"_id" json:"name"`
+	Description string    `bson:"description,omitempty" json:"description,omitempty"`
+	Permissions []string  `bson:"permissions" json:"permissions"`
+	BuiltIn     bool      `bson:"built_in" json:"built_in"`
+	CreatedAt   time.Time `bson:"created_at" json:"created_at"`
+	UpdatedAt   time.Time `bson:"updated_at" json:"updated_at"`
+}
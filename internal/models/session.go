@@ -0,0 +1,23 @@
+package models
+
+import (
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+// Session is a server-side login session. Access tokens carry the session ID
+// so that revoking the session invalidates them, and the session holds the
+// hash of the single refresh token that may currently be exchanged.
+type Session struct {
+	ID                  primitive.ObjectID `bson:"_id,omitempty" json:"id,omitempty"`
+	UserID              primitive.ObjectID `bson:"user_id" json:"user_id"`
+	RefreshTokenHash    string             `bson:"refresh_token_hash" json:"-"`
+	PreviousTokenHashes []string           `bson:"previous_token_hashes,omitempty" json:"-"`
+	UserAgent           string             `bson:"user_agent,omitempty" json:"user_agent,omitempty"`
+	IPAddress           string             `bson:"ip_address,omitempty" json:"ip_address,omitempty"`
+	CreatedAt           time.Time          `bson:"created_at" json:"created_at"`
+	LastUsedAt          time.Time          `bson:"last_used_at" json:"last_used_at"`
+	ExpiresAt           time.Time          `bson:"expires_at" json:"expires_at"`
+	RevokedAt           *time.Time         `bson:"revoked_at,omitempty" json:"revoked_at,omitempty"`
+}
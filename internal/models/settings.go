@@ -0,0 +1,19 @@
+package models
+
+import (
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+// TwoFactorPolicySettingID is the settings document holding the 2FA policy
+const TwoFactorPolicySettingID = "two_factor_policy"
+
+// TwoFactorPolicy requires two-factor authentication for every user whose
+// role includes RequiredRole. An empty RequiredRole disables the requirement.
+type TwoFactorPolicy struct {
+	ID           string             `bson:"_id" json:"-"`
+	RequiredRole string             `bson:"required_role" json:"required_role"`
+	UpdatedBy    primitive.ObjectID `bson:"updated_by,omitempty" json:"updated_by,omitempty"`
+	UpdatedAt    time.Time          `bson:"updated_at" json:"updated_at"`
+}
@@ -11,14 +11,35 @@ import (
 )
 
 type User struct {
-	ID        primitive.ObjectID `bson:"_id,omitempty" json:"id,omitempty"`
-	Username  string             `bson:"username" json:"username"`
-	Email     string             `bson:"email" json:"email"`
-	Password  string             `bson:"password" json:"-"`
-	Role      string             `bson:"role" json:"role"`
-	Profile   Profile            `bson:"profile" json:"profile"`
-	CreatedAt time.Time          `bson:"created_at" json:"created_at"`
-	UpdatedAt time.Time          `bson:"updated_at" json:"updated_at"`
+	ID                   primitive.ObjectID `bson:"_id,omitempty" json:"id,omitempty"`
+	Username             string             `bson:"username" json:"username"`
+	Email                string             `bson:"email" json:"email"`
+	Password             string             `bson:"password" json:"-"`
+	Role                 string             `bson:"role" json:"role"`
+	Profile              Profile            `bson:"profile" json:"profile"`
+	Locale               string             `bson:"locale,omitempty" json:"locale,omitempty"`
+	EmailVerified        bool               `bson:"email_verified" json:"email_verified"`
+	EmailVerifiedAt      *time.Time         `bson:"email_verified_at,omitempty" json:"email_verified_at,omitempty"`
+	VerificationSentAt   *time.Time         `bson:"verification_sent_at,omitempty" json:"verification_sent_at,omitempty"`
+	TwoFactorEnabled     bool               `bson:"two_factor_enabled" json:"two_factor_enabled"`
+	TOTPSecret           string             `bson:"totp_secret,omitempty" json:"-"`
+	TOTPPendingSecret    string             `bson:"totp_pending_secret,omitempty" json:"-"`
+	TOTPLastStep         int64              `bson:"totp_last_step,omitempty" json:"-"`
+	RecoveryCodeHashes   []string           `bson:"recovery_code_hashes,omitempty" json:"-"`
+	TwoFactorFailures    int                `bson:"two_factor_failures,omitempty" json:"-"`
+	TwoFactorLockedUntil *time.Time         `bson:"two_factor_locked_until,omitempty" json:"-"`
+	Identities           []ExternalIdentity `bson:"identities,omitempty" json:"identities,omitempty"`
+	EmailChange          *EmailChange       `bson:"email_change,omitempty" json:"-"`
+	CreatedAt            time.Time          `bson:"created_at" json:"created_at"`
+	UpdatedAt            time.Time          `bson:"updated_at" json:"updated_at"`
+}
+
+// EmailChange is a change of email address waiting for the user to confirm
+// the new address
+type EmailChange struct {
+	NewEmail    string    `bson:"new_email"`
+	SessionID   string    `bson:"session_id"`
+	RequestedAt time.Time `bson:"requested_at"`
 }
 
 // HashPassword hashes the user's password
@@ -41,16 +62,6 @@ func (u *User) ComparePassword(password string) error {
 	return bcrypt.CompareHashAndPassword([]byte(u.Password), []byte(password))
 }
 
-// ValidateRole checks if the role is valid
-func (u *User) ValidateRole() error {
-	switch u.Role {
-	case constants.RoleAdmin, constants.RoleAuthor, constants.RoleReader:
-		return nil
-	default:
-		return errors.New("invalid role")
-	}
-}
-
 // BeforeInsert is called before inserting a new user
 func (u *User) BeforeInsert(ctx context.Context) error {
 	now := time.Now()
@@ -69,11 +80,6 @@ func (u *User) BeforeInsert(ctx context.Context) error {
 		u.Role = constants.RoleReader
 	}
 
-	// Validate role
-	if err := u.ValidateRole(); err != nil {
-		return err
-	}
-
 	// Hash password if not already hashed
 	if len(u.Password) > 0 && len(u.Password) < 60 {
 		if err := u.HashPassword(); err != nil {
@@ -0,0 +1,179 @@
+package services
+
+import (
+	"context"
+	"crypto/rand"
+	"crypto/subtle"
+	"encoding/hex"
+	"errors"
+	"strings"
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+
+	"go-blog-platform/internal/models"
+)
+
+// APIKeyPrefix starts every API key, so keys are easy to recognize in
+// Authorization headers and secret scanners
+const APIKeyPrefix = "gbp_"
+
+// apiKeyTouchInterval limits how often last_used_at is written
+const apiKeyTouchInterval = time.Minute
+
+var (
+	ErrInvalidAPIKey      = errors.New("invalid or expired API key")
+	ErrAPIKeyNotFound     = errors.New("API key not found")
+	ErrAPIKeyLimitReached = errors.New("API key limit reached")
+)
+
+// APIKeyService manages personal API keys. A key looks like
+// gbp_<prefix>_<secret>; the prefix identifies the key and the secret is only
+// stored as a SHA-256 hash.
+type APIKeyService struct {
+	collection *mongo.Collection
+	users      *mongo.Collection
+	maxPerUser int64
+}
+
+func NewAPIKeyService(db *mongo.Database, maxPerUser int64) *APIKeyService {
+	return &APIKeyService{
+		collection: db.Collection("api_keys"),
+		users:      db.Collection("users"),
+		maxPerUser: maxPerUser,
+	}
+}
+
+// IsAPIKey reports whether the credential looks like an API key rather than a JWT
+func IsAPIKey(credential string) bool {
+	return strings.HasPrefix(credential, APIKeyPrefix)
+}
+
+// EnsureIndexes creates the lookup indexes
+func (s *APIKeyService) EnsureIndexes(ctx context.Context) error {
+	_, err := s.collection.Indexes().CreateMany(ctx, []mongo.IndexModel{
+		{Keys: bson.D{{Key: "prefix", Value: 1}}, Options: options.Index().SetUnique(true)},
+		{Keys: bson.D{{Key: "user_id", Value: 1}}},
+	})
+	return err
+}
+
+// Create issues a new API key for the user and returns it together with the
+// plaintext key
+func (s *APIKeyService) Create(ctx context.Context, userID primitive.ObjectID, name string, scopes []string, expiresAt *time.Time) (*models.APIKey, string, error) {
+	count, err := s.collection.CountDocuments(ctx, bson.M{"user_id": userID})
+	if err != nil {
+		return nil, "", err
+	}
+	if count >= s.maxPerUser {
+		return nil, "", ErrAPIKeyLimitReached
+	}
+
+	b := make([]byte, 6)
+	if _, err := rand.Read(b); err != nil {
+		return nil, "", err
+	}
+	prefix := APIKeyPrefix + hex.EncodeToString(b)
+
+	secret, err := generateOpaqueToken()
+	if err != nil {
+		return nil, "", err
+	}
+
+	key := &models.APIKey{
+		ID:         primitive.NewObjectID(),
+		UserID:     userID,
+		Name:       name,
+		Prefix:     prefix,
+		SecretHash: hashOpaqueToken(secret),
+		Scopes:     scopes,
+		ExpiresAt:  expiresAt,
+		CreatedAt:  time.Now(),
+	}
+	if _, err := s.collection.InsertOne(ctx, key); err != nil {
+		return nil, "", err
+	}
+
+	return key, prefix + "_" + secret, nil
+}
+
+// Authenticate verifies an API key and returns it together with its owner
+func (s *APIKeyService) Authenticate(ctx context.Context, credential string) (*models.APIKey, *models.User, error) {
+	prefix, secret, ok := strings.Cut(strings.TrimPrefix(credential, APIKeyPrefix), "_")
+	if !ok || !IsAPIKey(credential) {
+		return nil, nil, ErrInvalidAPIKey
+	}
+
+	var key models.APIKey
+	err := s.collection.FindOne(ctx, bson.M{"prefix": APIKeyPrefix + prefix}).Decode(&key)
+	if err == mongo.ErrNoDocuments {
+		return nil, nil, ErrInvalidAPIKey
+	}
+	if err != nil {
+		return nil, nil, err
+	}
+
+	if subtle.ConstantTimeCompare([]byte(hashOpaqueToken(secret)), []byte(key.SecretHash)) != 1 {
+		return nil, nil, ErrInvalidAPIKey
+	}
+
+	now := time.Now()
+	if key.ExpiresAt != nil && !key.ExpiresAt.After(now) {
+		return nil, nil, ErrInvalidAPIKey
+	}
+
+	var user models.User
+	err = s.users.FindOne(ctx, bson.M{"_id": key.UserID}).Decode(&user)
+	if err == mongo.ErrNoDocuments {
+		return nil, nil, ErrInvalidAPIKey
+	}
+	if err != nil {
+		return nil, nil, err
+	}
+
+	if key.LastUsedAt == nil || now.Sub(*key.LastUsedAt) >= apiKeyTouchInterval {
+		_, err = s.collection.UpdateOne(ctx, bson.M{"_id": key.ID}, bson.M{"$set": bson.M{"last_used_at": now}})
+		if err != nil {
+			return nil, nil, err
+		}
+	}
+
+	return &key, &user, nil
+}
+
+// List returns the user's API keys, newest first
+func (s *APIKeyService) List(ctx context.Context, userID primitive.ObjectID) ([]models.APIKey, error) {
+	opts := options.Find().SetSort(bson.D{{Key: "created_at", Value: -1}})
+	cursor, err := s.collection.Find(ctx, bson.M{"user_id": userID}, opts)
+	if err != nil {
+		return nil, err
+	}
+	defer cursor.Close(ctx)
+
+	keys := []models.APIKey{}
+	if err := cursor.All(ctx, &keys); err != nil {
+		return nil, err
+	}
+	return keys, nil
+}
+
+// Delete revokes one of the user's API keys
+func (s *APIKeyService) Delete(ctx context.Context, userID, keyID primitive.ObjectID) error {
+	result, err := s.collection.DeleteOne(ctx, bson.M{"_id": keyID, "user_id": userID})
+	if err != nil {
+		return err
+	}
+	if result.DeletedCount == 0 {
+		return ErrAPIKeyNotFound
+	}
+	return nil
+}
+
+// DeleteAll revokes every API key of the user
+func (s *APIKeyService) DeleteAll(ctx context.Context, userID primitive.ObjectID) error {
+	_, err := s.collection.DeleteMany(ctx, bson.M{"user_id": userID})
+	return err
+}
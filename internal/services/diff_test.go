@@ -0,0 +1,187 @@
+package services
+
+import (
+	"fmt"
+	"strings"
+	"testing"
+)
+
+// numberedLines returns lines "l1" to "ln", with the given lines replaced
+func numberedLines(n int, replace map[int]string) string {
+	var b strings.Builder
+	for i := 1; i <= n; i++ {
+		if line, ok := replace[i]; ok {
+			b.WriteString(line)
+		} else {
+			fmt.Fprintf(&b, "l%d", i)
+		}
+		b.WriteByte('\n')
+	}
+	return b.String()
+}
+
+func TestUnifiedDiff(t *testing.T) {
+	tests := []struct {
+		name     string
+		from, to string
+		want     string
+	}{
+		{
+			name: "identical",
+			from: "a\nb\nc\n",
+			to:   "a\nb\nc\n",
+			want: "",
+		},
+		{
+			name: "both empty",
+			want: "",
+		},
+		{
+			name: "insert only",
+			from: "a\nb\nc\n",
+			to:   "a\nb\nx\nc\n",
+			want: "--- from\n+++ to\n" +
+				"@@ -1,3 +1,4 @@\n a\n b\n+x\n c\n",
+		},
+		{
+			name: "delete only",
+			from: "a\nb\nc\n",
+			to:   "a\nc\n",
+			want: "--- from\n+++ to\n" +
+				"@@ -1,3 +1,2 @@\n a\n-b\n c\n",
+		},
+		{
+			name: "empty to non-empty",
+			from: "",
+			to:   "a\nb\n",
+			want: "--- from\n+++ to\n" +
+				"@@ -0,0 +1,2 @@\n+a\n+b\n",
+		},
+		{
+			name: "non-empty to empty",
+			from: "a\nb\n",
+			to:   "",
+			want: "--- from\n+++ to\n" +
+				"@@ -1,2 +0,0 @@\n-a\n-b\n",
+		},
+		{
+			name: "single line",
+			from: "a\n",
+			to:   "b\n",
+			want: "--- from\n+++ to\n" +
+				"@@ -1 +1 @@\n-a\n+b\n",
+		},
+		{
+			name: "only the final newline differs",
+			from: "a\nb",
+			to:   "a\nb\n",
+			want: "",
+		},
+		{
+			name: "no final newline",
+			from: "a\nb",
+			to:   "a\nc",
+			want: "--- from\n+++ to\n" +
+				"@@ -1,2 +1,2 @@\n a\n-b\n+c\n",
+		},
+		{
+			name: "context trimmed",
+			from: numberedLines(20, nil),
+			to:   numberedLines(20, map[int]string{10: "x"}),
+			want: "--- from\n+++ to\n" +
+				"@@ -7,7 +7,7 @@\n l7\n l8\n l9\n-l10\n+x\n l11\n l12\n l13\n",
+		},
+		{
+			// Six unchanged lines between changes are covered by both contexts
+			name: "hunks merged",
+			from: numberedLines(12, nil),
+			to:   numberedLines(12, map[int]string{2: "x", 9: "y"}),
+			want: "--- from\n+++ to\n" +
+				"@@ -1,12 +1,12 @@\n l1\n-l2\n+x\n l3\n l4\n l5\n l6\n l7\n l8\n-l9\n+y\n l10\n l11\n l12\n",
+		},
+		{
+			// Seven are not
+			name: "hunks apart",
+			from: numberedLines(14, nil),
+			to:   numberedLines(14, map[int]string{2: "x", 10: "y"}),
+			want: "--- from\n+++ to\n" +
+				"@@ -1,5 +1,5 @@\n l1\n-l2\n+x\n l3\n l4\n l5\n" +
+				"@@ -7,7 +7,7 @@\n l7\n l8\n l9\n-l10\n+y\n l11\n l12\n l13\n",
+		},
+		{
+			name: "lines moved",
+			from: "a\nb\nc\n",
+			to:   "c\na\nb\n",
+			want: "--- from\n+++ to\n" +
+				"@@ -1,3 +1,3 @@\n+c\n a\n b\n-c\n",
+		},
+	}
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			got, err := UnifiedDiff("from", "to", tc.from, tc.to)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if got != tc.want {
+				t.Errorf("UnifiedDiff =\n%s\nwant\n%s", got, tc.want)
+			}
+		})
+	}
+}
+
+func TestUnifiedDiffLimits(t *testing.T) {
+	// prefixedLines returns n lines, all different from those of another prefix
+	prefixedLines := func(prefix string, n int) string {
+		var b strings.Builder
+		for i := 0; i < n; i++ {
+			fmt.Fprintf(&b, "%s%d\n", prefix, i)
+		}
+		return b.String()
+	}
+
+	tests := []struct {
+		name     string
+		from, to string
+		wantErr  error
+	}{
+		{
+			name: "edits at the limit",
+			from: prefixedLines("a", maxDiffEdits/2),
+			to:   prefixedLines("b", maxDiffEdits/2),
+		},
+		{
+			name:    "too many edits",
+			from:    prefixedLines("a", maxDiffEdits/2),
+			to:      prefixedLines("b", maxDiffEdits/2+1),
+			wantErr: ErrDiffTooLarge,
+		},
+		{
+			name:    "large and completely different",
+			from:    prefixedLines("a", maxDiffLines),
+			to:      prefixedLines("b", maxDiffLines),
+			wantErr: ErrDiffTooLarge,
+		},
+		{
+			name: "large with few changes",
+			from: numberedLines(maxDiffLines, nil),
+			to:   numberedLines(maxDiffLines, map[int]string{1: "x", maxDiffLines: "y"}),
+		},
+		{
+			name:    "too many lines",
+			from:    numberedLines(maxDiffLines+1, nil),
+			to:      numberedLines(maxDiffLines+1, nil),
+			wantErr: ErrDiffTooLarge,
+		},
+	}
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			diff, err := UnifiedDiff("from", "to", tc.from, tc.to)
+			if err != tc.wantErr {
+				t.Fatalf("UnifiedDiff error = %v, want %v", err, tc.wantErr)
+			}
+			if err == nil && diff == "" {
+				t.Error("UnifiedDiff returned no changes")
+			}
+		})
+	}
+}
@@ -1,12 +1,50 @@
 package services
 
-type EmailService struct{}
+import (
+	"context"
+	"net/mail"
+)
 
-func NewEmailService() *EmailService {
-	return &EmailService{}
+type EmailService struct {
+	mailer    Mailer
+	templates *EmailTemplates
+	from      string
+	siteName  string
+	baseURL   string
 }
 
-func (s *EmailService) SendPasswordResetEmail(email, token string) error {
-	// TODO: Implement email sending
-	return nil
+func NewEmailService(mailer Mailer, templates *EmailTemplates, fromEmail, fromName, siteName, baseURL string) *EmailService {
+	from := (&mail.Address{Name: fromName, Address: fromEmail}).String()
+	return &EmailService{
+		mailer:    mailer,
+		templates: templates,
+		from:      from,
+		siteName:  siteName,
+		baseURL:   baseURL,
+	}
+}
+
+// Send renders the named template in the recipient's locale and sends it as
+// a multipart plain text and HTML message.
+func (s *EmailService) Send(to, locale, templateName string, data TemplateData) error {
+	values := TemplateData{
+		"SiteName": s.siteName,
+		"BaseURL":  s.baseURL,
+	}
+	for k, v := range data {
+		values[k] = v
+	}
+
+	rendered, err := s.templates.Render(templateName, locale, values)
+	if err != nil {
+		return err
+	}
+
+	return s.mailer.Send(context.Background(), &Message{
+		From:     s.from,
+		To:       []string{to},
+		Subject:  rendered.Subject,
+		TextBody: rendered.Text,
+		HTMLBody: rendered.HTML,
+	})
 }
@@ -0,0 +1,206 @@
+package services
+
+import (
+	"bytes"
+	"context"
+	"io"
+	"mime"
+	"mime/multipart"
+	"net/mail"
+	"strings"
+	"testing"
+)
+
+func newTestEmailService(mailer Mailer) *EmailService {
+	return NewEmailService(mailer, NewEmailTemplates(""), "noreply@blog.example", "Blog Team", "Test Blog", "https://blog.example")
+}
+
+// parseMessage encodes a message and parses it back as a mail client would
+func parseMessage(t *testing.T, msg *Message) *mail.Message {
+	t.Helper()
+
+	raw, err := msg.Bytes()
+	if err != nil {
+		t.Fatal(err)
+	}
+	parsed, err := mail.ReadMessage(bytes.NewReader(raw))
+	if err != nil {
+		t.Fatalf("ReadMessage: %v\n%s", err, raw)
+	}
+	return parsed
+}
+
+// messageParts returns the decoded bodies of a multipart message by content type
+func messageParts(t *testing.T, msg *mail.Message) map[string]string {
+	t.Helper()
+
+	mediaType, params, err := mime.ParseMediaType(msg.Header.Get("Content-Type"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if mediaType != "multipart/alternative" {
+		t.Fatalf("Content-Type = %q, want multipart/alternative", mediaType)
+	}
+
+	parts := make(map[string]string)
+	reader := multipart.NewReader(msg.Body, params["boundary"])
+	for {
+		part, err := reader.NextPart()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		// NextPart decodes quoted-printable bodies
+		body, err := io.ReadAll(part)
+		if err != nil {
+			t.Fatal(err)
+		}
+		contentType, _, _ := mime.ParseMediaType(part.Header.Get("Content-Type"))
+		parts[contentType] = string(body)
+	}
+	return parts
+}
+
+func TestEmailServiceSend(t *testing.T) {
+	mailer := NewMemoryMailer()
+	emails := newTestEmailService(mailer)
+
+	err := emails.Send("ann@example.com", "en", TemplateWelcome, TemplateData{
+		"Username": "ann",
+		"Link":     "https://blog.example/write?from=welcome&x=1",
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	messages := mailer.Messages()
+	if len(messages) != 1 {
+		t.Fatalf("sent %d messages, want 1", len(messages))
+	}
+	msg := messages[0]
+
+	recipients, err := msg.Recipients()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(recipients) != 1 || recipients[0] != "ann@example.com" {
+		t.Errorf("Recipients = %v, want [ann@example.com]", recipients)
+	}
+	if msg.Subject != "Welcome to Test Blog" {
+		t.Errorf("Subject = %q, want %q", msg.Subject, "Welcome to Test Blog")
+	}
+
+	parsed := parseMessage(t, &msg)
+	from, err := parsed.Header.AddressList("From")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if from[0].Name != "Blog Team" || from[0].Address != "noreply@blog.example" {
+		t.Errorf("From = %v, want Blog Team <noreply@blog.example>", from[0])
+	}
+	if got := parsed.Header.Get("MIME-Version"); got != "1.0" {
+		t.Errorf("MIME-Version = %q, want 1.0", got)
+	}
+	if got := parsed.Header.Get("Message-ID"); !strings.HasSuffix(got, "@blog.example>") {
+		t.Errorf("Message-ID = %q, want one at blog.example", got)
+	}
+
+	parts := messageParts(t, parsed)
+	if text := parts["text/plain"]; !strings.Contains(text, "Hi ann,") || !strings.Contains(text, "https://blog.example/write?from=welcome&x=1") {
+		t.Errorf("text body = %q, want the greeting and link", text)
+	}
+	html := parts["text/html"]
+	if !strings.Contains(html, "<p>Hi ann,</p>") || !strings.Contains(html, `href="https://blog.example/write?from=welcome&amp;x=1"`) {
+		t.Errorf("HTML body = %q, want the greeting and escaped link", html)
+	}
+}
+
+func TestEmailServiceSendLocalized(t *testing.T) {
+	mailer := NewMemoryMailer()
+	emails := newTestEmailService(mailer)
+
+	err := emails.Send("somchai@example.com", "th-TH", TemplateWelcome, TemplateData{
+		"Username": "somchai",
+		"Link":     "https://blog.example",
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	msg := mailer.Messages()[0]
+	parsed := parseMessage(t, &msg)
+
+	// Non-ASCII subjects are sent as encoded words
+	rawSubject := parsed.Header.Get("Subject")
+	if !strings.HasPrefix(rawSubject, "=?utf-8?q?") {
+		t.Errorf("Subject header = %q, want a Q-encoded word", rawSubject)
+	}
+	subject, err := new(mime.WordDecoder).DecodeHeader(rawSubject)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if subject != "ยินดีต้อนรับสู่ Test Blog" {
+		t.Errorf("Subject = %q, want the Thai welcome subject", subject)
+	}
+
+	parts := messageParts(t, parsed)
+	if !strings.Contains(parts["text/plain"], "สวัสดีคุณ somchai") {
+		t.Errorf("text body = %q, want the Thai greeting", parts["text/plain"])
+	}
+}
+
+func TestMessageBytesHeaders(t *testing.T) {
+	msg := &Message{
+		From:     "Blog Team <noreply@blog.example>",
+		To:       []string{"Ann Lee <ann@example.com>", "bob@example.com"},
+		Subject:  "Hello\r\nBcc: victim@example.com",
+		TextBody: "Only text",
+	}
+
+	parsed := parseMessage(t, msg)
+	if got := parsed.Header.Get("Bcc"); got != "" {
+		t.Errorf("Bcc = %q, want no header injected from the subject", got)
+	}
+	if got := parsed.Header.Get("Subject"); got != "Hello Bcc: victim@example.com" {
+		t.Errorf("Subject = %q, want newlines removed", got)
+	}
+
+	to, err := parsed.Header.AddressList("To")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(to) != 2 || to[0].Address != "ann@example.com" || to[1].Address != "bob@example.com" {
+		t.Errorf("To = %v, want ann@example.com and bob@example.com", to)
+	}
+
+	if got := parsed.Header.Get("Content-Type"); got != "text/plain; charset=UTF-8" {
+		t.Errorf("Content-Type = %q, want text/plain", got)
+	}
+	if got := parsed.Header.Get("Content-Transfer-Encoding"); got != "quoted-printable" {
+		t.Errorf("Content-Transfer-Encoding = %q, want quoted-printable", got)
+	}
+}
+
+func TestMessageBytesInvalidAddresses(t *testing.T) {
+	tests := []struct {
+		name string
+		msg  Message
+	}{
+		{"no recipients", Message{From: "noreply@blog.example", TextBody: "x"}},
+		{"bad recipient", Message{From: "noreply@blog.example", To: []string{"not an address"}, TextBody: "x"}},
+		{"bad sender", Message{From: "nobody", To: []string{"ann@example.com"}, TextBody: "x"}},
+	}
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			if _, err := tc.msg.Bytes(); err == nil {
+				t.Error("Bytes accepted the message")
+			}
+			if err := NewMemoryMailer().Send(context.Background(), &tc.msg); err == nil {
+				t.Error("MemoryMailer accepted the message")
+			}
+		})
+	}
+}
@@ -0,0 +1,214 @@
+package services
+
+import (
+	"bytes"
+	"embed"
+	"errors"
+	"fmt"
+	htmltemplate "html/template"
+	"io/fs"
+	"os"
+	"path"
+	"path/filepath"
+	"strings"
+	"sync"
+	texttemplate "text/template"
+)
+
+// Email template names
+const (
+	TemplatePasswordReset       = "password_reset"
+	TemplateEmailVerification   = "email_verification"
+	TemplateWelcome             = "welcome"
+	TemplateCommentNotification = "comment_notification"
+	TemplateAccountLocked       = "account_locked"
+	TemplateEmailChange         = "email_change"
+	TemplateEmailChanged        = "email_changed"
+	TemplatePasswordChanged     = "password_changed"
+	TemplateCollaboratorInvite  = "collaborator_invite"
+)
+
+// DefaultLocale is used when a template is not available in the user's locale
+const DefaultLocale = "en"
+
+//go:embed templates/email
+var embeddedEmailTemplates embed.FS
+
+// TemplateData is the data passed to an email template
+type TemplateData map[string]interface{}
+
+// RenderedEmail is the output of rendering an email template
+type RenderedEmail struct {
+	Subject string
+	Text    string
+	HTML    string
+}
+
+type compiledEmailTemplate struct {
+	text *texttemplate.Template
+	html *htmltemplate.Template
+}
+
+// EmailTemplates renders transactional emails from Go templates.
+//
+// Each template lives in <locale>/<name>.tmpl and defines a "subject" and
+// "text" block plus a "content" block that is wrapped by the HTML layout in
+// layout.tmpl. <locale>/common.tmpl holds blocks shared by all templates of a
+// locale. Any of these files can be overridden by placing a file with the same
+// relative path in the override directory.
+type EmailTemplates struct {
+	override fs.FS
+	defaults fs.FS
+
+	mu    sync.Mutex
+	cache map[string]*compiledEmailTemplate
+}
+
+func NewEmailTemplates(overrideDir string) *EmailTemplates {
+	defaults, _ := fs.Sub(embeddedEmailTemplates, "templates/email")
+
+	t := &EmailTemplates{
+		defaults: defaults,
+		cache:    make(map[string]*compiledEmailTemplate),
+	}
+	if overrideDir != "" {
+		t.override = os.DirFS(overrideDir)
+	}
+	return t
+}
+
+// Render renders the named template in the best available match for the
+// locale, falling back to the base language and then DefaultLocale.
+func (t *EmailTemplates) Render(name, locale string, data TemplateData) (*RenderedEmail, error) {
+	locale = t.resolveLocale(name, locale)
+
+	tmpl, err := t.compile(name, locale)
+	if err != nil {
+		return nil, err
+	}
+
+	values := TemplateData{"Locale": locale}
+	for k, v := range data {
+		values[k] = v
+	}
+
+	var subject, text, html bytes.Buffer
+	if err := tmpl.text.ExecuteTemplate(&subject, "subject", values); err != nil {
+		return nil, fmt.Errorf("render %s subject: %w", name, err)
+	}
+	if err := tmpl.text.ExecuteTemplate(&text, "text", values); err != nil {
+		return nil, fmt.Errorf("render %s text: %w", name, err)
+	}
+	if err := tmpl.html.ExecuteTemplate(&html, "html", values); err != nil {
+		return nil, fmt.Errorf("render %s html: %w", name, err)
+	}
+
+	return &RenderedEmail{
+		Subject: strings.TrimSpace(subject.String()),
+		Text:    strings.TrimSpace(text.String()) + "\n",
+		HTML:    html.String(),
+	}, nil
+}
+
+func (t *EmailTemplates) compile(name, locale string) (*compiledEmailTemplate, error) {
+	key := locale + "/" + name
+
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	if tmpl, ok := t.cache[key]; ok {
+		return tmpl, nil
+	}
+
+	layout, err := t.readFile("layout.tmpl")
+	if err != nil {
+		return nil, err
+	}
+	common, err := t.readFile(path.Join(locale, "common.tmpl"))
+	if err != nil {
+		return nil, err
+	}
+	body, err := t.readFile(path.Join(locale, name+".tmpl"))
+	if err != nil {
+		return nil, err
+	}
+
+	text := texttemplate.New(name).Option("missingkey=error")
+	for _, src := range []string{common, body} {
+		if text, err = text.Parse(src); err != nil {
+			return nil, fmt.Errorf("parse %s: %w", key, err)
+		}
+	}
+
+	html := htmltemplate.New(name).Option("missingkey=error")
+	for _, src := range []string{layout, common, body} {
+		if html, err = html.Parse(src); err != nil {
+			return nil, fmt.Errorf("parse %s: %w", key, err)
+		}
+	}
+
+	tmpl := &compiledEmailTemplate{text: text, html: html}
+	t.cache[key] = tmpl
+	return tmpl, nil
+}
+
+// resolveLocale picks the most specific locale the template exists in
+func (t *EmailTemplates) resolveLocale(name, locale string) string {
+	locale = NormalizeLocale(locale)
+
+	candidates := []string{locale}
+	if i := strings.Index(locale, "-"); i > 0 {
+		candidates = append(candidates, locale[:i])
+	}
+
+	for _, candidate := range candidates {
+		if candidate == "" {
+			continue
+		}
+		if _, err := t.readFile(path.Join(candidate, name+".tmpl")); err == nil {
+			return candidate
+		}
+	}
+
+	return DefaultLocale
+}
+
+func (t *EmailTemplates) readFile(name string) (string, error) {
+	if t.override != nil {
+		data, err := fs.ReadFile(t.override, name)
+		if err == nil {
+			return string(data), nil
+		}
+		if !errors.Is(err, fs.ErrNotExist) {
+			return "", err
+		}
+	}
+
+	data, err := fs.ReadFile(t.defaults, name)
+	if err != nil {
+		return "", fmt.Errorf("email template %s: %w", filepath.ToSlash(name), err)
+	}
+	return string(data), nil
+}
+
+// NormalizeLocale lower-cases a language tag such as "pt_BR" to "pt-br" and
+// returns an empty string if it is not a well-formed tag.
+func NormalizeLocale(locale string) string {
+	locale = strings.ToLower(strings.ReplaceAll(strings.TrimSpace(locale), "_", "-"))
+	if locale == "" || len(locale) > 35 {
+		return ""
+	}
+
+	for i, part := range strings.Split(locale, "-") {
+		if len(part) == 0 || len(part) > 8 || (i == 0 && (len(part) < 2 || len(part) > 3)) {
+			return ""
+		}
+		for _, r := range part {
+			if (r < 'a' || r > 'z') && (r < '0' || r > '9') {
+				return ""
+			}
+		}
+	}
+
+	return locale
+}
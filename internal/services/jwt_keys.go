@@ -0,0 +1,281 @@
+package services
+
+import (
+	"crypto"
+	"crypto/ed25519"
+	"crypto/rsa"
+	"crypto/sha256"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/json"
+	"encoding/pem"
+	"errors"
+	"fmt"
+	"math/big"
+	"os"
+	"sort"
+
+	"github.com/golang-jwt/jwt/v5"
+)
+
+// Supported JWT signing algorithms
+const (
+	AlgorithmHS256 = "HS256"
+	AlgorithmRS256 = "RS256"
+	AlgorithmEdDSA = "EdDSA"
+)
+
+// JWK is a public key in JSON Web Key format
+type JWK struct {
+	Kty string `json:"kty"`
+	Kid string `json:"kid"`
+	Use string `json:"use"`
+	Alg string `json:"alg"`
+	N   string `json:"n,omitempty"`
+	E   string `json:"e,omitempty"`
+	Crv string `json:"crv,omitempty"`
+	X   string `json:"x,omitempty"`
+}
+
+// JWKS is a JSON Web Key Set
+type JWKS struct {
+	Keys []JWK `json:"keys"`
+}
+
+type verificationKey struct {
+	method jwt.SigningMethod
+	key    interface{}
+	jwk    *JWK
+}
+
+// KeySet holds the key used to sign new tokens and every key tokens may be
+// verified with. Keeping retired public keys in the set lets tokens signed
+// before a rotation stay valid until they expire.
+type KeySet struct {
+	signingID     string
+	signingMethod jwt.SigningMethod
+	signingKey    interface{}
+	verification  map[string]verificationKey
+}
+
+// NewHMACKeySet returns a key set that signs and verifies with a shared secret.
+// Shared secrets are never published in the JWKS.
+func NewHMACKeySet(secret string) *KeySet {
+	return &KeySet{
+		signingMethod: jwt.SigningMethodHS256,
+		signingKey:    []byte(secret),
+		verification: map[string]verificationKey{
+			"": {method: jwt.SigningMethodHS256, key: []byte(secret)},
+		},
+	}
+}
+
+// LoadKeySet loads an asymmetric signing key and any additional verification
+// keys from PEM files. Verification key files may hold either public or
+// private keys.
+func LoadKeySet(algorithm, signingKeyFile string, verificationKeyFiles []string) (*KeySet, error) {
+	method := jwt.GetSigningMethod(algorithm)
+	if method == nil || (algorithm != AlgorithmRS256 && algorithm != AlgorithmEdDSA) {
+		return nil, fmt.Errorf("unsupported JWT algorithm %q", algorithm)
+	}
+
+	data, err := os.ReadFile(signingKeyFile)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read signing key: %w", err)
+	}
+
+	privateKey, err := parsePrivateKeyPEM(data)
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse signing key: %w", err)
+	}
+
+	ks := &KeySet{
+		signingMethod: method,
+		signingKey:    privateKey,
+		verification:  make(map[string]verificationKey),
+	}
+
+	kid, err := ks.addPublicKey(privateKey.Public())
+	if err != nil {
+		return nil, err
+	}
+	if ks.verification[kid].method.Alg() != algorithm {
+		return nil, fmt.Errorf("signing key type does not match algorithm %s", algorithm)
+	}
+	ks.signingID = kid
+
+	for _, file := range verificationKeyFiles {
+		data, err := os.ReadFile(file)
+		if err != nil {
+			return nil, fmt.Errorf("failed to read verification key %s: %w", file, err)
+		}
+
+		publicKey, err := parsePublicKeyPEM(data)
+		if err != nil {
+			return nil, fmt.Errorf("failed to parse verification key %s: %w", file, err)
+		}
+
+		if _, err := ks.addPublicKey(publicKey); err != nil {
+			return nil, fmt.Errorf("verification key %s: %w", file, err)
+		}
+	}
+
+	return ks, nil
+}
+
+// Sign signs the claims with the current signing key
+func (ks *KeySet) Sign(claims jwt.Claims) (string, error) {
+	token := jwt.NewWithClaims(ks.signingMethod, claims)
+	if ks.signingID != "" {
+		token.Header["kid"] = ks.signingID
+	}
+	return token.SignedString(ks.signingKey)
+}
+
+// Algorithms lists the algorithms tokens may be signed with
+func (ks *KeySet) Algorithms() []string {
+	seen := make(map[string]bool)
+	var algs []string
+	for _, key := range ks.verification {
+		alg := key.method.Alg()
+		if !seen[alg] {
+			seen[alg] = true
+			algs = append(algs, alg)
+		}
+	}
+	return algs
+}
+
+// Keyfunc resolves the verification key from the token's "kid" header and
+// rejects tokens whose algorithm does not match that key.
+func (ks *KeySet) Keyfunc(token *jwt.Token) (interface{}, error) {
+	kid, _ := token.Header["kid"].(string)
+
+	key, ok := ks.verification[kid]
+	if !ok {
+		return nil, fmt.Errorf("unknown key id %q", kid)
+	}
+	if token.Method.Alg() != key.method.Alg() {
+		return nil, errors.New("token algorithm does not match key")
+	}
+
+	return key.key, nil
+}
+
+// JWKS returns the public verification keys. It is empty for shared secrets.
+func (ks *KeySet) JWKS() JWKS {
+	jwks := JWKS{Keys: []JWK{}}
+	for _, key := range ks.verification {
+		if key.jwk != nil {
+			jwks.Keys = append(jwks.Keys, *key.jwk)
+		}
+	}
+	sort.Slice(jwks.Keys, func(i, j int) bool { return jwks.Keys[i].Kid < jwks.Keys[j].Kid })
+	return jwks
+}
+
+func (ks *KeySet) addPublicKey(publicKey crypto.PublicKey) (string, error) {
+	var (
+		method jwt.SigningMethod
+		jwk    JWK
+	)
+
+	switch key := publicKey.(type) {
+	case *rsa.PublicKey:
+		if key.N.BitLen() < 2048 {
+			return "", errors.New("RSA keys must be at least 2048 bits")
+		}
+		method = jwt.SigningMethodRS256
+		jwk = JWK{
+			Kty: "RSA",
+			Alg: AlgorithmRS256,
+			N:   base64.RawURLEncoding.EncodeToString(key.N.Bytes()),
+			E:   base64.RawURLEncoding.EncodeToString(big.NewInt(int64(key.E)).Bytes()),
+		}
+	case ed25519.PublicKey:
+		method = jwt.SigningMethodEdDSA
+		jwk = JWK{
+			Kty: "OKP",
+			Alg: AlgorithmEdDSA,
+			Crv: "Ed25519",
+			X:   base64.RawURLEncoding.EncodeToString(key),
+		}
+	default:
+		return "", fmt.Errorf("unsupported key type %T", publicKey)
+	}
+
+	jwk.Use = "sig"
+	jwk.Kid = jwkThumbprint(jwk)
+	ks.verification[jwk.Kid] = verificationKey{method: method, key: publicKey, jwk: &jwk}
+
+	return jwk.Kid, nil
+}
+
+// jwkThumbprint computes the RFC 7638 thumbprint used as the key ID
+func jwkThumbprint(jwk JWK) string {
+	var members interface{}
+	if jwk.Kty == "RSA" {
+		members = struct {
+			E   string `json:"e"`
+			Kty string `json:"kty"`
+			N   string `json:"n"`
+		}{jwk.E, jwk.Kty, jwk.N}
+	} else {
+		members = struct {
+			Crv string `json:"crv"`
+			Kty string `json:"kty"`
+			X   string `json:"x"`
+		}{jwk.Crv, jwk.Kty, jwk.X}
+	}
+
+	data, _ := json.Marshal(members)
+	sum := sha256.Sum256(data)
+	return base64.RawURLEncoding.EncodeToString(sum[:])
+}
+
+func parsePrivateKeyPEM(data []byte) (crypto.Signer, error) {
+	block, _ := pem.Decode(data)
+	if block == nil {
+		return nil, errors.New("no PEM block found")
+	}
+
+	switch block.Type {
+	case "RSA PRIVATE KEY":
+		return x509.ParsePKCS1PrivateKey(block.Bytes)
+	case "PRIVATE KEY":
+		key, err := x509.ParsePKCS8PrivateKey(block.Bytes)
+		if err != nil {
+			return nil, err
+		}
+		switch key := key.(type) {
+		case *rsa.PrivateKey:
+			return key, nil
+		case ed25519.PrivateKey:
+			return key, nil
+		default:
+			return nil, fmt.Errorf("unsupported private key type %T", key)
+		}
+	default:
+		return nil, fmt.Errorf("unsupported PEM block %q", block.Type)
+	}
+}
+
+func parsePublicKeyPEM(data []byte) (crypto.PublicKey, error) {
+	block, _ := pem.Decode(data)
+	if block == nil {
+		return nil, errors.New("no PEM block found")
+	}
+
+	switch block.Type {
+	case "PUBLIC KEY":
+		return x509.ParsePKIXPublicKey(block.Bytes)
+	case "RSA PUBLIC KEY":
+		return x509.ParsePKCS1PublicKey(block.Bytes)
+	default:
+		signer, err := parsePrivateKeyPEM(data)
+		if err != nil {
+			return nil, err
+		}
+		return signer.Public(), nil
+	}
+}
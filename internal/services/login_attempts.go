@@ -0,0 +1,28 @@
+package services
+
+import (
+	"context"
+	"time"
+
+	"go-blog-platform/internal/models"
+)
+
+// LoginAttemptStore persists failed login counters for LoginThrottle. Keys
+// identify an account or a client IP.
+type LoginAttemptStore interface {
+	// Get returns the attempt record for the key, or nil if there is none or
+	// it has expired
+	Get(ctx context.Context, key string, now time.Time) (*models.LoginAttempt, error)
+
+	// RecordFailure atomically counts a failure and returns the updated
+	// record. Counting starts over when the previous record has expired; a
+	// record stays alive for window after its last failure, and at least
+	// until its lock ends.
+	RecordFailure(ctx context.Context, key string, now time.Time, window time.Duration) (*models.LoginAttempt, error)
+
+	// Lock blocks the key until the given time
+	Lock(ctx context.Context, key string, until time.Time) error
+
+	// Reset removes the record for the key
+	Reset(ctx context.Context, key string) error
+}
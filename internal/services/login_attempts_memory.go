@@ -0,0 +1,94 @@
+package services
+
+import (
+	"context"
+	"sync"
+	"time"
+
+	"go-blog-platform/internal/models"
+)
+
+// MemoryLoginAttemptStore keeps login attempts in process memory. It suits
+// tests and single-instance deployments; counters are lost on restart.
+type MemoryLoginAttemptStore struct {
+	mu       sync.Mutex
+	attempts map[string]*models.LoginAttempt
+}
+
+func NewMemoryLoginAttemptStore() *MemoryLoginAttemptStore {
+	return &MemoryLoginAttemptStore{
+		attempts: make(map[string]*models.LoginAttempt),
+	}
+}
+
+func (s *MemoryLoginAttemptStore) Get(ctx context.Context, key string, now time.Time) (*models.LoginAttempt, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	attempt := s.live(key, now)
+	if attempt == nil {
+		return nil, nil
+	}
+
+	copied := *attempt
+	return &copied, nil
+}
+
+func (s *MemoryLoginAttemptStore) RecordFailure(ctx context.Context, key string, now time.Time, window time.Duration) (*models.LoginAttempt, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	attempt := s.live(key, now)
+	if attempt == nil {
+		attempt = &models.LoginAttempt{Key: key}
+		s.attempts[key] = attempt
+	}
+
+	attempt.Failures++
+	attempt.LastFailureAt = now
+	attempt.ExpiresAt = now.Add(window)
+	if attempt.LockedUntil != nil && attempt.LockedUntil.After(attempt.ExpiresAt) {
+		attempt.ExpiresAt = *attempt.LockedUntil
+	}
+
+	copied := *attempt
+	return &copied, nil
+}
+
+func (s *MemoryLoginAttemptStore) Lock(ctx context.Context, key string, until time.Time) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	attempt, ok := s.attempts[key]
+	if !ok {
+		attempt = &models.LoginAttempt{Key: key}
+		s.attempts[key] = attempt
+	}
+
+	attempt.LockedUntil = &until
+	if until.After(attempt.ExpiresAt) {
+		attempt.ExpiresAt = until
+	}
+	return nil
+}
+
+func (s *MemoryLoginAttemptStore) Reset(ctx context.Context, key string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	delete(s.attempts, key)
+	return nil
+}
+
+// live returns the unexpired record for the key, dropping it if it has expired
+func (s *MemoryLoginAttemptStore) live(key string, now time.Time) *models.LoginAttempt {
+	attempt, ok := s.attempts[key]
+	if !ok {
+		return nil
+	}
+	if !attempt.ExpiresAt.After(now) {
+		delete(s.attempts, key)
+		return nil
+	}
+	return attempt
+}
@@ -0,0 +1,90 @@
+package services
+
+import (
+	"context"
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+
+	"go-blog-platform/internal/models"
+)
+
+// MongoLoginAttemptStore shares login attempts between server instances
+// through the login_attempts collection.
+type MongoLoginAttemptStore struct {
+	collection *mongo.Collection
+}
+
+func NewMongoLoginAttemptStore(db *mongo.Database) *MongoLoginAttemptStore {
+	return &MongoLoginAttemptStore{
+		collection: db.Collection("login_attempts"),
+	}
+}
+
+// EnsureIndexes lets MongoDB remove records once they have expired
+func (s *MongoLoginAttemptStore) EnsureIndexes(ctx context.Context) error {
+	_, err := s.collection.Indexes().CreateOne(ctx, mongo.IndexModel{
+		Keys:    bson.D{{Key: "expires_at", Value: 1}},
+		Options: options.Index().SetExpireAfterSeconds(0),
+	})
+	return err
+}
+
+func (s *MongoLoginAttemptStore) Get(ctx context.Context, key string, now time.Time) (*models.LoginAttempt, error) {
+	// The TTL monitor only runs once a minute, so filter out expired records too
+	var attempt models.LoginAttempt
+	err := s.collection.FindOne(ctx, bson.M{"_id": key, "expires_at": bson.M{"$gt": now}}).Decode(&attempt)
+	if err == mongo.ErrNoDocuments {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	return &attempt, nil
+}
+
+func (s *MongoLoginAttemptStore) RecordFailure(ctx context.Context, key string, now time.Time, window time.Duration) (*models.LoginAttempt, error) {
+	// A pipeline update restarts the count in the same operation when the
+	// previous record has expired
+	live := bson.M{"$gt": bson.A{"$expires_at", now}}
+	update := mongo.Pipeline{
+		{{Key: "$set", Value: bson.M{
+			"failures":        bson.M{"$cond": bson.A{live, bson.M{"$add": bson.A{"$failures", 1}}, 1}},
+			"locked_until":    bson.M{"$cond": bson.A{live, "$locked_until", "$$REMOVE"}},
+			"last_failure_at": now,
+			"expires_at":      bson.M{"$max": bson.A{now.Add(window), bson.M{"$cond": bson.A{live, "$locked_until", nil}}}},
+		}}},
+	}
+
+	var attempt models.LoginAttempt
+	err := s.collection.FindOneAndUpdate(
+		ctx,
+		bson.M{"_id": key},
+		update,
+		options.FindOneAndUpdate().SetUpsert(true).SetReturnDocument(options.After),
+	).Decode(&attempt)
+	if err != nil {
+		return nil, err
+	}
+	return &attempt, nil
+}
+
+func (s *MongoLoginAttemptStore) Lock(ctx context.Context, key string, until time.Time) error {
+	_, err := s.collection.UpdateOne(
+		ctx,
+		bson.M{"_id": key},
+		bson.M{
+			"$set": bson.M{"locked_until": until},
+			"$max": bson.M{"expires_at": until},
+		},
+		options.Update().SetUpsert(true),
+	)
+	return err
+}
+
+func (s *MongoLoginAttemptStore) Reset(ctx context.Context, key string) error {
+	_, err := s.collection.DeleteOne(ctx, bson.M{"_id": key})
+	return err
+}
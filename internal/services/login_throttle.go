@@ -0,0 +1,161 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"time"
+
+	"go-blog-platform/internal/models"
+)
+
+var ErrLoginThrottled = errors.New("too many failed login attempts")
+
+// LoginThrottleConfig controls how failed logins are limited
+type LoginThrottleConfig struct {
+	// MaxAccountFailures locks an account after this many failures in a row
+	MaxAccountFailures int
+	// MaxIPFailures locks a client IP after this many failures, across accounts
+	MaxIPFailures int
+	// LockoutDuration is how long a lock lasts
+	LockoutDuration time.Duration
+	// Window is how long failures are remembered after the last one
+	Window time.Duration
+	// BaseDelay is the wait enforced after the first failure on an account;
+	// it doubles with every further failure up to MaxDelay
+	BaseDelay time.Duration
+	MaxDelay  time.Duration
+}
+
+// LoginThrottle limits password guessing by tracking failed logins per
+// account and per client IP. Accounts must wait progressively longer between
+// attempts and are locked temporarily once they reach the threshold.
+type LoginThrottle struct {
+	store  LoginAttemptStore
+	config LoginThrottleConfig
+	now    func() time.Time
+}
+
+// LoginFailure describes the outcome of a recorded failure
+type LoginFailure struct {
+	Failures int
+	// Locked is set when this failure locked the account
+	Locked bool
+}
+
+func NewLoginThrottle(store LoginAttemptStore, config LoginThrottleConfig) *LoginThrottle {
+	return &LoginThrottle{
+		store:  store,
+		config: config,
+		now:    time.Now,
+	}
+}
+
+// LockoutDuration returns how long a lock lasts
+func (t *LoginThrottle) LockoutDuration() time.Duration {
+	return t.config.LockoutDuration
+}
+
+// Check returns ErrLoginThrottled and how long to wait if a login for the
+// account from the IP must not be attempted yet
+func (t *LoginThrottle) Check(ctx context.Context, email, ip string) (time.Duration, error) {
+	now := t.now()
+
+	ipAttempt, err := t.store.Get(ctx, loginIPKey(ip), now)
+	if err != nil {
+		return 0, err
+	}
+	if ipAttempt != nil && lockedAt(ipAttempt, now) {
+		return ipAttempt.LockedUntil.Sub(now), ErrLoginThrottled
+	}
+
+	attempt, err := t.store.Get(ctx, loginAccountKey(email), now)
+	if err != nil {
+		return 0, err
+	}
+	if attempt == nil {
+		return 0, nil
+	}
+	if lockedAt(attempt, now) {
+		return attempt.LockedUntil.Sub(now), ErrLoginThrottled
+	}
+
+	if next := attempt.LastFailureAt.Add(t.delay(attempt.Failures)); next.After(now) {
+		return next.Sub(now), ErrLoginThrottled
+	}
+	return 0, nil
+}
+
+// RecordFailure counts a failed login for the account and the IP and locks
+// either once it reaches its threshold
+func (t *LoginThrottle) RecordFailure(ctx context.Context, email, ip string) (*LoginFailure, error) {
+	now := t.now()
+
+	ipAttempt, err := t.store.RecordFailure(ctx, loginIPKey(ip), now, t.config.Window)
+	if err != nil {
+		return nil, err
+	}
+	if ipAttempt.Failures >= t.config.MaxIPFailures && !lockedAt(ipAttempt, now) {
+		if err := t.store.Lock(ctx, loginIPKey(ip), now.Add(t.config.LockoutDuration)); err != nil {
+			return nil, err
+		}
+	}
+
+	attempt, err := t.store.RecordFailure(ctx, loginAccountKey(email), now, t.config.Window)
+	if err != nil {
+		return nil, err
+	}
+
+	failure := &LoginFailure{Failures: attempt.Failures}
+	if attempt.Failures >= t.config.MaxAccountFailures && !lockedAt(attempt, now) {
+		if err := t.store.Lock(ctx, loginAccountKey(email), now.Add(t.config.LockoutDuration)); err != nil {
+			return nil, err
+		}
+		failure.Locked = true
+	}
+	return failure, nil
+}
+
+// RecordSuccess clears the account's failures. IP counters are left to
+// expire so one valid login cannot hide guessing at other accounts.
+func (t *LoginThrottle) RecordSuccess(ctx context.Context, email string) error {
+	return t.store.Reset(ctx, loginAccountKey(email))
+}
+
+// Unlock lifts an account lock and clears its failures
+func (t *LoginThrottle) Unlock(ctx context.Context, email string) error {
+	return t.store.Reset(ctx, loginAccountKey(email))
+}
+
+// UnlockIP lifts a client IP lock and clears its failures
+func (t *LoginThrottle) UnlockIP(ctx context.Context, ip string) error {
+	return t.store.Reset(ctx, loginIPKey(ip))
+}
+
+// delay returns how long an account must wait after its last failure
+func (t *LoginThrottle) delay(failures int) time.Duration {
+	if failures < 1 || t.config.BaseDelay <= 0 {
+		return 0
+	}
+
+	delay := t.config.BaseDelay
+	for i := 1; i < failures && delay < t.config.MaxDelay; i++ {
+		delay *= 2
+	}
+	if delay > t.config.MaxDelay {
+		delay = t.config.MaxDelay
+	}
+	return delay
+}
+
+func lockedAt(attempt *models.LoginAttempt, now time.Time) bool {
+	return attempt.LockedUntil != nil && attempt.LockedUntil.After(now)
+}
+
+func loginAccountKey(email string) string {
+	return "account:" + strings.ToLower(strings.TrimSpace(email))
+}
+
+func loginIPKey(ip string) string {
+	return "ip:" + ip
+}
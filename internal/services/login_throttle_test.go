@@ -0,0 +1,221 @@
+package services
+
+import (
+	"context"
+	"testing"
+	"time"
+)
+
+// testClock is a settable time source for LoginThrottle
+type testClock struct {
+	now time.Time
+}
+
+func (c *testClock) Now() time.Time {
+	return c.now
+}
+
+func (c *testClock) Advance(d time.Duration) {
+	c.now = c.now.Add(d)
+}
+
+func newTestLoginThrottle(config LoginThrottleConfig) (*LoginThrottle, *testClock) {
+	clock := &testClock{now: time.Date(2024, 1, 1, 12, 0, 0, 0, time.UTC)}
+	throttle := NewLoginThrottle(NewMemoryLoginAttemptStore(), config)
+	throttle.now = clock.Now
+	return throttle, clock
+}
+
+var testThrottleConfig = LoginThrottleConfig{
+	MaxAccountFailures: 6,
+	MaxIPFailures:      20,
+	LockoutDuration:    15 * time.Minute,
+	Window:             time.Hour,
+	BaseDelay:          time.Second,
+	MaxDelay:           8 * time.Second,
+}
+
+// recordFailures records n failed logins for the account from the IP,
+// waiting out the delay before each
+func recordFailures(t *testing.T, throttle *LoginThrottle, clock *testClock, email, ip string, n int) *LoginFailure {
+	t.Helper()
+
+	ctx := context.Background()
+	var failure *LoginFailure
+	for i := 0; i < n; i++ {
+		if wait, err := throttle.Check(ctx, email, ip); err != nil {
+			clock.Advance(wait)
+		}
+		var err error
+		failure, err = throttle.RecordFailure(ctx, email, ip)
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+	return failure
+}
+
+func TestLoginThrottleDelay(t *testing.T) {
+	throttle, clock := newTestLoginThrottle(testThrottleConfig)
+	ctx := context.Background()
+
+	if _, err := throttle.Check(ctx, "ann@example.com", "10.0.0.1"); err != nil {
+		t.Fatalf("Check before any failure = %v, want nil", err)
+	}
+
+	// The wait doubles with every failure, up to MaxDelay
+	for i, want := range []time.Duration{time.Second, 2 * time.Second, 4 * time.Second, 8 * time.Second, 8 * time.Second} {
+		failure := recordFailures(t, throttle, clock, "ann@example.com", "10.0.0.1", 1)
+		if failure.Failures != i+1 || failure.Locked {
+			t.Fatalf("failure %d = %+v, want %d failures and no lock", i+1, failure, i+1)
+		}
+
+		wait, err := throttle.Check(ctx, "ann@example.com", "10.0.0.1")
+		if err != ErrLoginThrottled || wait != want {
+			t.Fatalf("after %d failures Check = %v, %v, want %v, ErrLoginThrottled", i+1, wait, err, want)
+		}
+
+		clock.Advance(want - time.Millisecond)
+		if _, err := throttle.Check(ctx, "ann@example.com", "10.0.0.1"); err != ErrLoginThrottled {
+			t.Fatalf("after %d failures Check just before the delay = %v, want ErrLoginThrottled", i+1, err)
+		}
+		clock.Advance(time.Millisecond)
+		if _, err := throttle.Check(ctx, "ann@example.com", "10.0.0.1"); err != nil {
+			t.Fatalf("after %d failures Check after the delay = %v, want nil", i+1, err)
+		}
+	}
+
+	// Other accounts are not slowed down
+	if _, err := throttle.Check(ctx, "bob@example.com", "10.0.0.1"); err != nil {
+		t.Errorf("Check for another account = %v, want nil", err)
+	}
+}
+
+func TestLoginThrottleDelayExpires(t *testing.T) {
+	throttle, clock := newTestLoginThrottle(testThrottleConfig)
+	ctx := context.Background()
+
+	recordFailures(t, throttle, clock, "ann@example.com", "10.0.0.1", 3)
+	clock.Advance(testThrottleConfig.Window)
+
+	// Failures are forgotten once the window has passed
+	failure := recordFailures(t, throttle, clock, "ann@example.com", "10.0.0.1", 1)
+	if failure.Failures != 1 {
+		t.Errorf("failures after the window = %d, want 1", failure.Failures)
+	}
+	if wait, err := throttle.Check(ctx, "ann@example.com", "10.0.0.1"); wait != time.Second || err != ErrLoginThrottled {
+		t.Errorf("Check = %v, %v, want 1s, ErrLoginThrottled", wait, err)
+	}
+}
+
+func TestLoginThrottleAccountLockout(t *testing.T) {
+	throttle, clock := newTestLoginThrottle(testThrottleConfig)
+	ctx := context.Background()
+
+	failure := recordFailures(t, throttle, clock, "Ann@Example.com ", "10.0.0.1", testThrottleConfig.MaxAccountFailures-1)
+	if failure.Locked {
+		t.Fatal("account locked before reaching the threshold")
+	}
+
+	failure = recordFailures(t, throttle, clock, "ann@example.com", "10.0.0.2", 1)
+	if !failure.Locked || failure.Failures != testThrottleConfig.MaxAccountFailures {
+		t.Fatalf("failure at the threshold = %+v, want the account locked", failure)
+	}
+
+	// The lock applies to the account from any IP, whatever the case of the address
+	wait, err := throttle.Check(ctx, "ANN@example.com", "10.0.0.3")
+	if err != ErrLoginThrottled || wait != testThrottleConfig.LockoutDuration {
+		t.Fatalf("Check on a locked account = %v, %v, want %v, ErrLoginThrottled", wait, err, testThrottleConfig.LockoutDuration)
+	}
+
+	// Failing while locked does not extend the lock
+	if failure, err := throttle.RecordFailure(ctx, "ann@example.com", "10.0.0.3"); err != nil || failure.Locked {
+		t.Fatalf("RecordFailure while locked = %+v, %v, want no new lock", failure, err)
+	}
+
+	clock.Advance(testThrottleConfig.LockoutDuration)
+	if _, err := throttle.Check(ctx, "ann@example.com", "10.0.0.3"); err != nil {
+		t.Errorf("Check after the lock ended = %v, want nil", err)
+	}
+}
+
+func TestLoginThrottleIPLockout(t *testing.T) {
+	config := testThrottleConfig
+	config.MaxIPFailures = 3
+	throttle, clock := newTestLoginThrottle(config)
+	ctx := context.Background()
+
+	// Guessing one password each for many accounts still counts against the IP
+	for _, email := range []string{"a@example.com", "b@example.com", "c@example.com"} {
+		recordFailures(t, throttle, clock, email, "10.0.0.1", 1)
+	}
+	clock.Advance(config.MaxDelay)
+
+	wait, err := throttle.Check(ctx, "d@example.com", "10.0.0.1")
+	if err != ErrLoginThrottled {
+		t.Fatalf("Check from a locked IP = %v, want ErrLoginThrottled", err)
+	}
+	if wait != config.LockoutDuration-config.MaxDelay {
+		t.Errorf("wait = %v, want %v", wait, config.LockoutDuration-config.MaxDelay)
+	}
+
+	if _, err := throttle.Check(ctx, "d@example.com", "10.0.0.2"); err != nil {
+		t.Errorf("Check from another IP = %v, want nil", err)
+	}
+
+	// A successful login does not clear the IP's failures
+	if err := throttle.RecordSuccess(ctx, "a@example.com"); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := throttle.Check(ctx, "a@example.com", "10.0.0.1"); err != ErrLoginThrottled {
+		t.Errorf("Check from a locked IP after a success = %v, want ErrLoginThrottled", err)
+	}
+
+	if err := throttle.UnlockIP(ctx, "10.0.0.1"); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := throttle.Check(ctx, "d@example.com", "10.0.0.1"); err != nil {
+		t.Errorf("Check after UnlockIP = %v, want nil", err)
+	}
+}
+
+func TestLoginThrottleSuccessResets(t *testing.T) {
+	throttle, clock := newTestLoginThrottle(testThrottleConfig)
+	ctx := context.Background()
+
+	recordFailures(t, throttle, clock, "ann@example.com", "10.0.0.1", 4)
+	if err := throttle.RecordSuccess(ctx, "ann@example.com"); err != nil {
+		t.Fatal(err)
+	}
+
+	if _, err := throttle.Check(ctx, "ann@example.com", "10.0.0.1"); err != nil {
+		t.Fatalf("Check after a success = %v, want nil", err)
+	}
+	failure := recordFailures(t, throttle, clock, "ann@example.com", "10.0.0.1", 1)
+	if failure.Failures != 1 {
+		t.Errorf("failures after a success = %d, want 1", failure.Failures)
+	}
+}
+
+func TestLoginThrottleUnlock(t *testing.T) {
+	throttle, clock := newTestLoginThrottle(testThrottleConfig)
+	ctx := context.Background()
+
+	failure := recordFailures(t, throttle, clock, "ann@example.com", "10.0.0.1", testThrottleConfig.MaxAccountFailures)
+	if !failure.Locked {
+		t.Fatal("account not locked at the threshold")
+	}
+
+	if err := throttle.Unlock(ctx, "ann@example.com"); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := throttle.Check(ctx, "ann@example.com", "10.0.0.1"); err != nil {
+		t.Fatalf("Check after Unlock = %v, want nil", err)
+	}
+
+	// Counting starts over, so the next failure only brings the base delay
+	failure = recordFailures(t, throttle, clock, "ann@example.com", "10.0.0.1", 1)
+	if failure.Failures != 1 || failure.Locked {
+		t.Errorf("failure after Unlock = %+v, want 1 failure and no lock", failure)
+	}
+}
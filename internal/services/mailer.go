@@ -0,0 +1,168 @@
+package services
+
+import (
+	"bytes"
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"mime"
+	"mime/multipart"
+	"mime/quotedprintable"
+	"net/mail"
+	"net/textproto"
+	"strings"
+	"time"
+)
+
+// Mailer delivers email messages. Implementations exist for SMTP, a local
+// maildir and memory.
+type Mailer interface {
+	Send(ctx context.Context, msg *Message) error
+}
+
+// Message is an email with a plain text body, an optional HTML alternative,
+// or both.
+type Message struct {
+	From     string
+	To       []string
+	Subject  string
+	TextBody string
+	HTMLBody string
+}
+
+// Recipients returns the bare addresses of all recipients
+func (m *Message) Recipients() ([]string, error) {
+	if len(m.To) == 0 {
+		return nil, errors.New("message has no recipients")
+	}
+
+	recipients := make([]string, 0, len(m.To))
+	for _, to := range m.To {
+		addr, err := mail.ParseAddress(to)
+		if err != nil {
+			return nil, fmt.Errorf("invalid recipient %q: %w", to, err)
+		}
+		recipients = append(recipients, addr.Address)
+	}
+	return recipients, nil
+}
+
+// Sender returns the bare address of the sender
+func (m *Message) Sender() (string, error) {
+	addr, err := mail.ParseAddress(m.From)
+	if err != nil {
+		return "", fmt.Errorf("invalid sender %q: %w", m.From, err)
+	}
+	return addr.Address, nil
+}
+
+// Bytes encodes the message in RFC 5322 format. Bodies are quoted-printable
+// encoded and sent as multipart/alternative when both are present.
+func (m *Message) Bytes() ([]byte, error) {
+	from, err := mail.ParseAddress(m.From)
+	if err != nil {
+		return nil, fmt.Errorf("invalid sender %q: %w", m.From, err)
+	}
+
+	var to []string
+	for _, recipient := range m.To {
+		addr, err := mail.ParseAddress(recipient)
+		if err != nil {
+			return nil, fmt.Errorf("invalid recipient %q: %w", recipient, err)
+		}
+		to = append(to, addr.String())
+	}
+	if len(to) == 0 {
+		return nil, errors.New("message has no recipients")
+	}
+
+	var buf bytes.Buffer
+	writeHeader := func(key, value string) {
+		buf.WriteString(key + ": " + value + "\r\n")
+	}
+
+	writeHeader("From", from.String())
+	writeHeader("To", strings.Join(to, ", "))
+	writeHeader("Subject", mime.QEncoding.Encode("utf-8", stripNewlines(m.Subject)))
+	writeHeader("Date", time.Now().Format(time.RFC1123Z))
+	writeHeader("Message-ID", newMessageID(from.Address))
+	writeHeader("MIME-Version", "1.0")
+
+	switch {
+	case m.TextBody != "" && m.HTMLBody != "":
+		var body bytes.Buffer
+		mw := multipart.NewWriter(&body)
+		if err := writePart(mw, "text/plain; charset=UTF-8", m.TextBody); err != nil {
+			return nil, err
+		}
+		if err := writePart(mw, "text/html; charset=UTF-8", m.HTMLBody); err != nil {
+			return nil, err
+		}
+		if err := mw.Close(); err != nil {
+			return nil, err
+		}
+
+		writeHeader("Content-Type", "multipart/alternative; boundary="+mw.Boundary())
+		buf.WriteString("\r\n")
+		buf.Write(body.Bytes())
+	case m.HTMLBody != "":
+		writeHeader("Content-Type", "text/html; charset=UTF-8")
+		writeHeader("Content-Transfer-Encoding", "quoted-printable")
+		buf.WriteString("\r\n")
+		if err := writeQuotedPrintable(&buf, m.HTMLBody); err != nil {
+			return nil, err
+		}
+	default:
+		writeHeader("Content-Type", "text/plain; charset=UTF-8")
+		writeHeader("Content-Transfer-Encoding", "quoted-printable")
+		buf.WriteString("\r\n")
+		if err := writeQuotedPrintable(&buf, m.TextBody); err != nil {
+			return nil, err
+		}
+	}
+
+	return buf.Bytes(), nil
+}
+
+func writePart(mw *multipart.Writer, contentType, body string) error {
+	header := textproto.MIMEHeader{}
+	header.Set("Content-Type", contentType)
+	header.Set("Content-Transfer-Encoding", "quoted-printable")
+
+	part, err := mw.CreatePart(header)
+	if err != nil {
+		return err
+	}
+
+	var buf bytes.Buffer
+	if err := writeQuotedPrintable(&buf, body); err != nil {
+		return err
+	}
+	_, err = part.Write(buf.Bytes())
+	return err
+}
+
+func writeQuotedPrintable(buf *bytes.Buffer, body string) error {
+	qp := quotedprintable.NewWriter(buf)
+	if _, err := qp.Write([]byte(body)); err != nil {
+		return err
+	}
+	return qp.Close()
+}
+
+func newMessageID(from string) string {
+	domain := "localhost"
+	if at := strings.LastIndex(from, "@"); at >= 0 {
+		domain = from[at+1:]
+	}
+
+	b := make([]byte, 16)
+	_, _ = rand.Read(b)
+	return fmt.Sprintf("<%s@%s>", hex.EncodeToString(b), domain)
+}
+
+func stripNewlines(s string) string {
+	return strings.NewReplacer("\r", "", "\n", " ").Replace(s)
+}
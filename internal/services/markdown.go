@@ -0,0 +1,179 @@
+package services
+
+import (
+	"bytes"
+	"fmt"
+	"strings"
+
+	"github.com/yuin/goldmark"
+	"github.com/yuin/goldmark/ast"
+	"github.com/yuin/goldmark/extension"
+	"github.com/yuin/goldmark/parser"
+	"github.com/yuin/goldmark/renderer/html"
+	"github.com/yuin/goldmark/text"
+
+	"go-blog-platform/internal/models"
+)
+
+// fallbackHeadingID anchors headings without any letters or digits
+const fallbackHeadingID = "section"
+
+// RenderedMarkdown is post content converted to HTML, with its headings
+type RenderedMarkdown struct {
+	Source string // the Markdown with its raw HTML sanitized
+	HTML   string
+	TOC    []models.TOCEntry
+}
+
+// MarkdownRenderer converts post content from CommonMark with the GitHub
+// extensions (tables, strikethrough, autolinks and task lists) and footnotes
+// to HTML. Raw HTML in the source goes through the sanitizer, and so does the
+// result.
+type MarkdownRenderer struct {
+	markdown  goldmark.Markdown
+	sanitizer *Sanitizer
+}
+
+func NewMarkdownRenderer(sanitizer *Sanitizer) *MarkdownRenderer {
+	return &MarkdownRenderer{
+		markdown: goldmark.New(
+			goldmark.WithExtensions(extension.GFM, extension.Footnote),
+			goldmark.WithParserOptions(parser.WithAutoHeadingID()),
+			goldmark.WithRendererOptions(html.WithUnsafe()),
+		),
+		sanitizer: sanitizer,
+	}
+}
+
+// Render sanitizes the raw HTML in the Markdown source and converts it to
+// HTML. Headings get anchors made from their text, listed in order in the
+// table of contents.
+func (r *MarkdownRenderer) Render(source string) (*RenderedMarkdown, error) {
+	src, err := r.sanitizeRawHTML([]byte(source))
+	if err != nil {
+		return nil, err
+	}
+
+	ctx := parser.NewContext(parser.WithIDs(&headingIDs{used: make(map[string]bool)}))
+	doc := r.markdown.Parser().Parse(text.NewReader(src), parser.WithContext(ctx))
+
+	rendered := &RenderedMarkdown{Source: string(src)}
+	err = ast.Walk(doc, func(node ast.Node, entering bool) (ast.WalkStatus, error) {
+		heading, ok := node.(*ast.Heading)
+		if !entering || !ok {
+			return ast.WalkContinue, nil
+		}
+
+		id, _ := heading.AttributeString("id")
+		idBytes, _ := id.([]byte)
+		rendered.TOC = append(rendered.TOC, models.TOCEntry{
+			Level: heading.Level,
+			ID:    string(idBytes),
+			Text:  inlineText(heading, src),
+		})
+		return ast.WalkSkipChildren, nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	var buf bytes.Buffer
+	if err := r.markdown.Renderer().Render(&buf, src, doc); err != nil {
+		return nil, err
+	}
+	rendered.HTML = r.sanitizer.renderedHTML(buf.String())
+	return rendered, nil
+}
+
+// sanitizeRawHTML runs the HTML blocks and inline tags of the source through
+// the sanitizer, leaving the Markdown around them, including code, as it is
+func (r *MarkdownRenderer) sanitizeRawHTML(src []byte) ([]byte, error) {
+	doc := r.markdown.Parser().Parse(text.NewReader(src))
+
+	// Raw HTML spread over several lines of a list or quote is interrupted by
+	// their markers, so sanitize each unbroken run of it
+	var runs []text.Segment
+	add := func(segment text.Segment) {
+		if last := len(runs) - 1; last >= 0 && runs[last].Stop == segment.Start {
+			runs[last].Stop = segment.Stop
+			return
+		}
+		runs = append(runs, text.NewSegment(segment.Start, segment.Stop))
+	}
+
+	err := ast.Walk(doc, func(node ast.Node, entering bool) (ast.WalkStatus, error) {
+		if !entering {
+			return ast.WalkContinue, nil
+		}
+		switch n := node.(type) {
+		case *ast.HTMLBlock:
+			for i := 0; i < n.Lines().Len(); i++ {
+				add(n.Lines().At(i))
+			}
+			if n.HasClosure() {
+				add(n.ClosureLine)
+			}
+		case *ast.RawHTML:
+			for i := 0; i < n.Segments.Len(); i++ {
+				add(n.Segments.At(i))
+			}
+		}
+		return ast.WalkContinue, nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	var out bytes.Buffer
+	pos := 0
+	for _, run := range runs {
+		out.Write(src[pos:run.Start])
+		out.WriteString(r.sanitizer.HTML(string(run.Value(src))))
+		pos = run.Stop
+	}
+	out.Write(src[pos:])
+	return out.Bytes(), nil
+}
+
+// inlineText returns the text of a node without its Markdown formatting
+func inlineText(node ast.Node, source []byte) string {
+	var b strings.Builder
+	for child := node.FirstChild(); child != nil; child = child.NextSibling() {
+		switch n := child.(type) {
+		case *ast.Text:
+			b.Write(n.Segment.Value(source))
+			if n.SoftLineBreak() {
+				b.WriteByte(' ')
+			}
+		case *ast.String:
+			b.Write(n.Value)
+		default:
+			b.WriteString(inlineText(child, source))
+		}
+	}
+	return b.String()
+}
+
+// headingIDs makes heading anchors the way post slugs are made, with a
+// suffix for repeated headings
+type headingIDs struct {
+	used map[string]bool
+}
+
+func (ids *headingIDs) Generate(value []byte, kind ast.NodeKind) []byte {
+	base := slugOf(string(value))
+	if base == "" {
+		base = fallbackHeadingID
+	}
+
+	id := base
+	for n := 1; ids.used[id]; n++ {
+		id = fmt.Sprintf("%s-%d", base, n)
+	}
+	ids.used[id] = true
+	return []byte(id)
+}
+
+func (ids *headingIDs) Put(value []byte) {
+	ids.used[string(value)] = true
+}
@@ -1,6 +1,7 @@
 package services
 
 import (
+	"errors"
 	"fmt"
 	"io"
 	"mime/multipart"
@@ -11,8 +12,12 @@ import (
 
 	"github.com/disintegration/imaging"
 	"github.com/gabriel-vasile/mimetype"
+	"go.mongodb.org/mongo-driver/bson/primitive"
 )
 
+// ErrMediaNotFound is returned for paths that are not uploaded files
+var ErrMediaNotFound = errors.New("media not found")
+
 type MediaService struct {
 	uploadDir string
 	baseURL   string
@@ -158,6 +163,23 @@ func (s *MediaService) generateThumbnails(sourcePath string, userID string) ([]T
 	return thumbnails, nil
 }
 
+// OwnerOf returns the user an uploaded file belongs to. Files are stored in
+// a directory per user, so paths outside the upload directory have no owner.
+func (s *MediaService) OwnerOf(path string) (primitive.ObjectID, error) {
+	rel, err := filepath.Rel(filepath.Clean(s.uploadDir), filepath.Clean(path))
+	if err != nil || rel == "." || strings.HasPrefix(rel, "..") {
+		return primitive.NilObjectID, ErrMediaNotFound
+	}
+
+	userDir, file, _ := strings.Cut(filepath.ToSlash(rel), "/")
+	userID, err := primitive.ObjectIDFromHex(userDir)
+	if err != nil || file == "" {
+		return primitive.NilObjectID, ErrMediaNotFound
+	}
+
+	return userID, nil
+}
+
 func (s *MediaService) DeleteFile(path string) error {
 	// Delete the main file
 	err := os.Remove(path)
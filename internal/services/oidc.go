@@ -0,0 +1,528 @@
+package services
+
+import (
+	"context"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rsa"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"math/big"
+	"net/http"
+	"net/url"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/golang-jwt/jwt/v5"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+
+	"go-blog-platform/internal/models"
+)
+
+const (
+	oidcStateTTL        = 10 * time.Minute
+	oidcJWKSMinRefresh  = 30 * time.Second
+	oidcClockSkew       = time.Minute
+	oidcMaxResponseSize = 1 << 20
+)
+
+var (
+	ErrInvalidOIDCState = errors.New("invalid or expired OIDC state")
+	ErrInvalidIDToken   = errors.New("invalid ID token")
+)
+
+// oidcSigningMethods are the ID token algorithms we accept. "none" and HMAC
+// algorithms are never accepted.
+var oidcSigningMethods = []string{"RS256", "RS384", "RS512", "PS256", "PS384", "PS512", "ES256", "ES384", "ES512"}
+
+// OIDCConfig configures the OpenID Connect relying party
+type OIDCConfig struct {
+	IssuerURL    string
+	ClientID     string
+	ClientSecret string
+	RedirectURL  string
+	Scopes       []string
+	// GroupsClaim names the ID token claim listing the user's groups
+	GroupsClaim string
+}
+
+// OIDCIdentity is the verified result of an OpenID Connect login
+type OIDCIdentity struct {
+	Issuer            string
+	Subject           string
+	Email             string
+	EmailVerified     bool
+	Name              string
+	PreferredUsername string
+	Groups            []string
+}
+
+type oidcDiscovery struct {
+	Issuer                string   `json:"issuer"`
+	AuthorizationEndpoint string   `json:"authorization_endpoint"`
+	TokenEndpoint         string   `json:"token_endpoint"`
+	JWKSURI               string   `json:"jwks_uri"`
+	SigningAlgorithms     []string `json:"id_token_signing_alg_values_supported"`
+}
+
+// OIDCService signs users in through an external OpenID Connect provider
+// using the authorization code flow with PKCE. Provider metadata comes from
+// the discovery document at IssuerURL, so pointing IssuerURL at a local mock
+// provider is enough to test the whole flow.
+type OIDCService struct {
+	config     OIDCConfig
+	states     *mongo.Collection
+	httpClient *http.Client
+
+	mu           sync.Mutex
+	discovery    *oidcDiscovery
+	keys         map[string]interface{}
+	keysLoadedAt time.Time
+}
+
+func NewOIDCService(db *mongo.Database, config OIDCConfig, httpClient *http.Client) *OIDCService {
+	if httpClient == nil {
+		httpClient = &http.Client{Timeout: 10 * time.Second}
+	}
+	config.IssuerURL = strings.TrimSuffix(config.IssuerURL, "/")
+
+	return &OIDCService{
+		config:     config,
+		states:     db.Collection("oidc_states"),
+		httpClient: httpClient,
+	}
+}
+
+// Issuer returns the provider's issuer identifier
+func (s *OIDCService) Issuer() string {
+	return s.config.IssuerURL
+}
+
+// EnsureIndexes lets MongoDB remove authorization requests that were never completed
+func (s *OIDCService) EnsureIndexes(ctx context.Context) error {
+	_, err := s.states.Indexes().CreateOne(ctx, mongo.IndexModel{
+		Keys:    bson.D{{Key: "expires_at", Value: 1}},
+		Options: options.Index().SetExpireAfterSeconds(0),
+	})
+	return err
+}
+
+// StartAuth records a new authorization request and returns the provider URL
+// to send the browser to, together with the state value. If linkUserID is
+// set, completing the request links the identity to that user instead of
+// logging in.
+func (s *OIDCService) StartAuth(ctx context.Context, linkUserID *primitive.ObjectID) (string, string, error) {
+	discovery, err := s.discover(ctx)
+	if err != nil {
+		return "", "", err
+	}
+
+	state, err := generateOpaqueToken()
+	if err != nil {
+		return "", "", err
+	}
+	nonce, err := generateOpaqueToken()
+	if err != nil {
+		return "", "", err
+	}
+	verifier, err := generateOpaqueToken()
+	if err != nil {
+		return "", "", err
+	}
+
+	_, err = s.states.InsertOne(ctx, models.OIDCState{
+		ID:           hashOpaqueToken(state),
+		Nonce:        nonce,
+		CodeVerifier: verifier,
+		LinkUserID:   linkUserID,
+		ExpiresAt:    time.Now().Add(oidcStateTTL),
+	})
+	if err != nil {
+		return "", "", err
+	}
+
+	params := url.Values{}
+	params.Set("response_type", "code")
+	params.Set("client_id", s.config.ClientID)
+	params.Set("redirect_uri", s.config.RedirectURL)
+	params.Set("scope", strings.Join(s.config.Scopes, " "))
+	params.Set("state", state)
+	params.Set("nonce", nonce)
+	params.Set("code_challenge", pkceChallenge(verifier))
+	params.Set("code_challenge_method", "S256")
+
+	authURL := discovery.AuthorizationEndpoint
+	if strings.Contains(authURL, "?") {
+		authURL += "&" + params.Encode()
+	} else {
+		authURL += "?" + params.Encode()
+	}
+
+	return authURL, state, nil
+}
+
+// pkceChallenge derives the S256 code challenge sent with the authorization
+// request from the verifier sent when redeeming the code (RFC 7636)
+func pkceChallenge(verifier string) string {
+	sum := sha256.Sum256([]byte(verifier))
+	return base64.RawURLEncoding.EncodeToString(sum[:])
+}
+
+// CompleteAuth consumes the authorization request identified by state,
+// exchanges the code for tokens and returns the verified identity from the ID
+// token
+func (s *OIDCService) CompleteAuth(ctx context.Context, state, code string) (*OIDCIdentity, *models.OIDCState, error) {
+	var request models.OIDCState
+	err := s.states.FindOneAndDelete(ctx, bson.M{
+		"_id":        hashOpaqueToken(state),
+		"expires_at": bson.M{"$gt": time.Now()},
+	}).Decode(&request)
+	if err == mongo.ErrNoDocuments {
+		return nil, nil, ErrInvalidOIDCState
+	}
+	if err != nil {
+		return nil, nil, err
+	}
+
+	rawIDToken, err := s.exchange(ctx, code, request.CodeVerifier)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	identity, err := s.verifyIDToken(ctx, rawIDToken, request.Nonce)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	return identity, &request, nil
+}
+
+// exchange redeems the authorization code at the token endpoint and returns
+// the raw ID token
+func (s *OIDCService) exchange(ctx context.Context, code, verifier string) (string, error) {
+	discovery, err := s.discover(ctx)
+	if err != nil {
+		return "", err
+	}
+
+	form := url.Values{}
+	form.Set("grant_type", "authorization_code")
+	form.Set("code", code)
+	form.Set("redirect_uri", s.config.RedirectURL)
+	form.Set("code_verifier", verifier)
+	form.Set("client_id", s.config.ClientID)
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, discovery.TokenEndpoint, strings.NewReader(form.Encode()))
+	if err != nil {
+		return "", err
+	}
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	req.Header.Set("Accept", "application/json")
+	if s.config.ClientSecret != "" {
+		req.SetBasicAuth(url.QueryEscape(s.config.ClientID), url.QueryEscape(s.config.ClientSecret))
+	}
+
+	var token struct {
+		IDToken          string `json:"id_token"`
+		Error            string `json:"error"`
+		ErrorDescription string `json:"error_description"`
+	}
+	status, err := s.doJSON(req, &token)
+	if err != nil {
+		return "", err
+	}
+	if status != http.StatusOK || token.Error != "" {
+		return "", fmt.Errorf("oidc token exchange failed: %d %s %s", status, token.Error, token.ErrorDescription)
+	}
+	if token.IDToken == "" {
+		return "", errors.New("oidc token response has no id_token")
+	}
+
+	return token.IDToken, nil
+}
+
+// verifyIDToken checks the ID token signature against the provider's keys
+// and validates issuer, audience, expiry and nonce
+func (s *OIDCService) verifyIDToken(ctx context.Context, rawIDToken, nonce string) (*OIDCIdentity, error) {
+	discovery, err := s.discover(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	methods := oidcSigningMethods
+	if len(discovery.SigningAlgorithms) > 0 {
+		methods = nil
+		for _, alg := range discovery.SigningAlgorithms {
+			for _, allowed := range oidcSigningMethods {
+				if alg == allowed {
+					methods = append(methods, alg)
+				}
+			}
+		}
+		if len(methods) == 0 {
+			return nil, fmt.Errorf("%w: provider supports no acceptable signing algorithm", ErrInvalidIDToken)
+		}
+	}
+
+	claims := jwt.MapClaims{}
+	token, err := jwt.ParseWithClaims(
+		rawIDToken,
+		claims,
+		func(token *jwt.Token) (interface{}, error) {
+			kid, _ := token.Header["kid"].(string)
+			return s.key(ctx, kid)
+		},
+		jwt.WithValidMethods(methods),
+		jwt.WithIssuer(discovery.Issuer),
+		jwt.WithAudience(s.config.ClientID),
+		jwt.WithExpirationRequired(),
+		jwt.WithIssuedAt(),
+		jwt.WithLeeway(oidcClockSkew),
+	)
+	if err != nil || !token.Valid {
+		return nil, fmt.Errorf("%w: %v", ErrInvalidIDToken, err)
+	}
+
+	if n, _ := claims["nonce"].(string); n == "" || n != nonce {
+		return nil, fmt.Errorf("%w: nonce mismatch", ErrInvalidIDToken)
+	}
+
+	// With several audiences the token must have been issued to us
+	audience, _ := claims.GetAudience()
+	if len(audience) > 1 {
+		if azp, _ := claims["azp"].(string); azp != s.config.ClientID {
+			return nil, fmt.Errorf("%w: azp mismatch", ErrInvalidIDToken)
+		}
+	}
+
+	subject, _ := claims.GetSubject()
+	if subject == "" {
+		return nil, fmt.Errorf("%w: missing subject", ErrInvalidIDToken)
+	}
+
+	identity := &OIDCIdentity{
+		Issuer:  s.config.IssuerURL,
+		Subject: subject,
+		Groups:  stringList(claims[s.config.GroupsClaim]),
+	}
+	identity.Email, _ = claims["email"].(string)
+	identity.Name, _ = claims["name"].(string)
+	identity.PreferredUsername, _ = claims["preferred_username"].(string)
+	switch verified := claims["email_verified"].(type) {
+	case bool:
+		identity.EmailVerified = verified
+	case string:
+		// Some providers send booleans as strings
+		identity.EmailVerified = verified == "true"
+	}
+
+	return identity, nil
+}
+
+// discover fetches and caches the provider's discovery document
+func (s *OIDCService) discover(ctx context.Context) (*oidcDiscovery, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if s.discovery != nil {
+		return s.discovery, nil
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, s.config.IssuerURL+"/.well-known/openid-configuration", nil)
+	if err != nil {
+		return nil, err
+	}
+
+	var discovery oidcDiscovery
+	status, err := s.doJSON(req, &discovery)
+	if err != nil {
+		return nil, err
+	}
+	if status != http.StatusOK {
+		return nil, fmt.Errorf("oidc discovery failed: %d", status)
+	}
+
+	if strings.TrimSuffix(discovery.Issuer, "/") != s.config.IssuerURL {
+		return nil, fmt.Errorf("oidc discovery issuer %q does not match %q", discovery.Issuer, s.config.IssuerURL)
+	}
+	if discovery.AuthorizationEndpoint == "" || discovery.TokenEndpoint == "" || discovery.JWKSURI == "" {
+		return nil, errors.New("oidc discovery document is incomplete")
+	}
+
+	s.discovery = &discovery
+	return s.discovery, nil
+}
+
+// key returns the provider's verification key with the given ID, refetching
+// the JWKS when a key is not known yet so provider key rotation is picked up
+func (s *OIDCService) key(ctx context.Context, kid string) (interface{}, error) {
+	discovery, err := s.discover(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if key, ok := s.lookupKey(kid); ok {
+		return key, nil
+	}
+	if time.Since(s.keysLoadedAt) < oidcJWKSMinRefresh {
+		return nil, errors.New("unknown signing key")
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, discovery.JWKSURI, nil)
+	if err != nil {
+		return nil, err
+	}
+
+	var set struct {
+		Keys []json.RawMessage `json:"keys"`
+	}
+	status, err := s.doJSON(req, &set)
+	if err != nil {
+		return nil, err
+	}
+	if status != http.StatusOK {
+		return nil, fmt.Errorf("oidc jwks fetch failed: %d", status)
+	}
+
+	keys := make(map[string]interface{})
+	for _, raw := range set.Keys {
+		id, key, err := parseJWK(raw)
+		if err != nil {
+			// Skip keys we cannot use, such as encryption keys
+			continue
+		}
+		keys[id] = key
+	}
+	s.keys = keys
+	s.keysLoadedAt = time.Now()
+
+	if key, ok := s.lookupKey(kid); ok {
+		return key, nil
+	}
+	return nil, errors.New("unknown signing key")
+}
+
+// lookupKey finds a key by ID. Tokens without a kid are accepted if the
+// provider publishes a single key.
+func (s *OIDCService) lookupKey(kid string) (interface{}, bool) {
+	if key, ok := s.keys[kid]; ok {
+		return key, true
+	}
+	if kid == "" && len(s.keys) == 1 {
+		for _, key := range s.keys {
+			return key, true
+		}
+	}
+	return nil, false
+}
+
+func (s *OIDCService) doJSON(req *http.Request, v interface{}) (int, error) {
+	resp, err := s.httpClient.Do(req)
+	if err != nil {
+		return 0, err
+	}
+	defer resp.Body.Close()
+
+	body, err := io.ReadAll(io.LimitReader(resp.Body, oidcMaxResponseSize))
+	if err != nil {
+		return 0, err
+	}
+	if err := json.Unmarshal(body, v); err != nil && resp.StatusCode == http.StatusOK {
+		return 0, fmt.Errorf("invalid response from %s: %w", req.URL.Host, err)
+	}
+	return resp.StatusCode, nil
+}
+
+// parseJWK converts an RSA or EC signing key in JWK form to a public key
+func parseJWK(raw json.RawMessage) (string, interface{}, error) {
+	var jwk struct {
+		Kid string `json:"kid"`
+		Kty string `json:"kty"`
+		Use string `json:"use"`
+		N   string `json:"n"`
+		E   string `json:"e"`
+		Crv string `json:"crv"`
+		X   string `json:"x"`
+		Y   string `json:"y"`
+	}
+	if err := json.Unmarshal(raw, &jwk); err != nil {
+		return "", nil, err
+	}
+	if jwk.Use != "" && jwk.Use != "sig" {
+		return "", nil, errors.New("not a signing key")
+	}
+
+	switch jwk.Kty {
+	case "RSA":
+		n, err := base64.RawURLEncoding.DecodeString(jwk.N)
+		if err != nil {
+			return "", nil, err
+		}
+		e, err := base64.RawURLEncoding.DecodeString(jwk.E)
+		if err != nil {
+			return "", nil, err
+		}
+		return jwk.Kid, &rsa.PublicKey{
+			N: new(big.Int).SetBytes(n),
+			E: int(new(big.Int).SetBytes(e).Int64()),
+		}, nil
+
+	case "EC":
+		var curve elliptic.Curve
+		switch jwk.Crv {
+		case "P-256":
+			curve = elliptic.P256()
+		case "P-384":
+			curve = elliptic.P384()
+		case "P-521":
+			curve = elliptic.P521()
+		default:
+			return "", nil, fmt.Errorf("unsupported curve %q", jwk.Crv)
+		}
+		x, err := base64.RawURLEncoding.DecodeString(jwk.X)
+		if err != nil {
+			return "", nil, err
+		}
+		y, err := base64.RawURLEncoding.DecodeString(jwk.Y)
+		if err != nil {
+			return "", nil, err
+		}
+		key := &ecdsa.PublicKey{Curve: curve, X: new(big.Int).SetBytes(x), Y: new(big.Int).SetBytes(y)}
+		if !curve.IsOnCurve(key.X, key.Y) {
+			return "", nil, errors.New("invalid EC key")
+		}
+		return jwk.Kid, key, nil
+
+	default:
+		return "", nil, fmt.Errorf("unsupported key type %q", jwk.Kty)
+	}
+}
+
+// stringList reads a claim that is either a list of strings or a single string
+func stringList(claim interface{}) []string {
+	switch v := claim.(type) {
+	case string:
+		return []string{v}
+	case []interface{}:
+		list := make([]string, 0, len(v))
+		for _, item := range v {
+			if s, ok := item.(string); ok {
+				list = append(list, s)
+			}
+		}
+		return list
+	default:
+		return nil
+	}
+}
@@ -0,0 +1,359 @@
+package services
+
+import (
+	"context"
+	"crypto/rand"
+	"crypto/rsa"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"math/big"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/golang-jwt/jwt/v5"
+)
+
+const (
+	testOIDCClientID     = "blog-client"
+	testOIDCClientSecret = "blog-secret"
+	testOIDCRedirectURL  = "http://localhost:8080/api/auth/oidc/callback"
+	testOIDCCode         = "auth-code"
+	testOIDCVerifier     = "dBjftJeZ4CVP-mB92K27uhbUJU1p1r_wW1gFWFOEjXk"
+	testOIDCNonce        = "nonce-123"
+	testOIDCKeyID        = "key-1"
+)
+
+// mockOIDCProvider is a local OpenID Connect provider serving discovery,
+// JWKS and token endpoints. Its token endpoint only redeems testOIDCCode
+// with the verifier matching the expected PKCE challenge.
+type mockOIDCProvider struct {
+	server *httptest.Server
+	key    *rsa.PrivateKey
+
+	mu        sync.Mutex
+	challenge string
+	idToken   string
+	tokenForm url.Values
+}
+
+func newMockOIDCProvider(t *testing.T) *mockOIDCProvider {
+	t.Helper()
+
+	key, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	p := &mockOIDCProvider{key: key, challenge: pkceChallenge(testOIDCVerifier)}
+	mux := http.NewServeMux()
+	mux.HandleFunc("/.well-known/openid-configuration", func(w http.ResponseWriter, r *http.Request) {
+		writeTestJSON(w, http.StatusOK, map[string]interface{}{
+			"issuer":                                p.server.URL,
+			"authorization_endpoint":                p.server.URL + "/authorize",
+			"token_endpoint":                        p.server.URL + "/token",
+			"jwks_uri":                              p.server.URL + "/jwks",
+			"id_token_signing_alg_values_supported": []string{"RS256"},
+		})
+	})
+	mux.HandleFunc("/jwks", func(w http.ResponseWriter, r *http.Request) {
+		writeTestJSON(w, http.StatusOK, map[string]interface{}{
+			"keys": []map[string]string{{
+				"kty": "RSA",
+				"use": "sig",
+				"kid": testOIDCKeyID,
+				"n":   base64.RawURLEncoding.EncodeToString(key.N.Bytes()),
+				"e":   base64.RawURLEncoding.EncodeToString(big.NewInt(int64(key.E)).Bytes()),
+			}},
+		})
+	})
+	mux.HandleFunc("/token", p.token)
+
+	p.server = httptest.NewServer(mux)
+	t.Cleanup(p.server.Close)
+	return p
+}
+
+func (p *mockOIDCProvider) token(w http.ResponseWriter, r *http.Request) {
+	if err := r.ParseForm(); err != nil {
+		writeTestJSON(w, http.StatusBadRequest, map[string]string{"error": "invalid_request"})
+		return
+	}
+
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	p.tokenForm = r.PostForm
+
+	clientID, secret, ok := r.BasicAuth()
+	if !ok || clientID != testOIDCClientID || secret != testOIDCClientSecret {
+		writeTestJSON(w, http.StatusUnauthorized, map[string]string{"error": "invalid_client"})
+		return
+	}
+	if r.PostForm.Get("grant_type") != "authorization_code" || r.PostForm.Get("code") != testOIDCCode ||
+		r.PostForm.Get("redirect_uri") != testOIDCRedirectURL {
+		writeTestJSON(w, http.StatusBadRequest, map[string]string{"error": "invalid_grant"})
+		return
+	}
+	if pkceChallenge(r.PostForm.Get("code_verifier")) != p.challenge {
+		writeTestJSON(w, http.StatusBadRequest, map[string]string{
+			"error":             "invalid_grant",
+			"error_description": "PKCE verification failed",
+		})
+		return
+	}
+
+	writeTestJSON(w, http.StatusOK, map[string]string{
+		"access_token": "access",
+		"token_type":   "Bearer",
+		"id_token":     p.idToken,
+	})
+}
+
+// setIDToken makes the token endpoint return the ID token
+func (p *mockOIDCProvider) setIDToken(token string) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	p.idToken = token
+}
+
+// claims returns valid ID token claims for the test client, which tests
+// change to make the token invalid
+func (p *mockOIDCProvider) claims() jwt.MapClaims {
+	now := time.Now()
+	return jwt.MapClaims{
+		"iss":            p.server.URL,
+		"sub":            "user-42",
+		"aud":            testOIDCClientID,
+		"exp":            now.Add(5 * time.Minute).Unix(),
+		"iat":            now.Unix(),
+		"nonce":          testOIDCNonce,
+		"email":          "ann@example.com",
+		"email_verified": true,
+		"name":           "Ann Lee",
+		"groups":         []string{"writers"},
+	}
+}
+
+// sign signs claims as an ID token with the key, using the provider's key ID
+func (p *mockOIDCProvider) sign(t *testing.T, claims jwt.MapClaims, key *rsa.PrivateKey) string {
+	t.Helper()
+
+	token := jwt.NewWithClaims(jwt.SigningMethodRS256, claims)
+	token.Header["kid"] = testOIDCKeyID
+	signed, err := token.SignedString(key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return signed
+}
+
+func (p *mockOIDCProvider) service() *OIDCService {
+	return &OIDCService{
+		config: OIDCConfig{
+			IssuerURL:    p.server.URL,
+			ClientID:     testOIDCClientID,
+			ClientSecret: testOIDCClientSecret,
+			RedirectURL:  testOIDCRedirectURL,
+			Scopes:       []string{"openid", "email", "profile"},
+			GroupsClaim:  "groups",
+		},
+		httpClient: p.server.Client(),
+	}
+}
+
+func writeTestJSON(w http.ResponseWriter, status int, v interface{}) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	_ = json.NewEncoder(w).Encode(v)
+}
+
+func TestPKCEChallenge(t *testing.T) {
+	// RFC 7636, appendix B
+	if got := pkceChallenge(testOIDCVerifier); got != "E9Melhoa2OwvFrEMTJguCHaoeK1t8URWbuGJSstw-cM" {
+		t.Errorf("pkceChallenge = %q, want the RFC 7636 example challenge", got)
+	}
+}
+
+func TestOIDCLogin(t *testing.T) {
+	provider := newMockOIDCProvider(t)
+	provider.setIDToken(provider.sign(t, provider.claims(), provider.key))
+	oidc := provider.service()
+	ctx := context.Background()
+
+	rawIDToken, err := oidc.exchange(ctx, testOIDCCode, testOIDCVerifier)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := provider.tokenForm.Get("code_verifier"); got != testOIDCVerifier {
+		t.Errorf("code_verifier sent = %q, want %q", got, testOIDCVerifier)
+	}
+
+	identity, err := oidc.verifyIDToken(ctx, rawIDToken, testOIDCNonce)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	want := OIDCIdentity{
+		Issuer:        provider.server.URL,
+		Subject:       "user-42",
+		Email:         "ann@example.com",
+		EmailVerified: true,
+		Name:          "Ann Lee",
+	}
+	if identity.Issuer != want.Issuer || identity.Subject != want.Subject || identity.Email != want.Email ||
+		identity.EmailVerified != want.EmailVerified || identity.Name != want.Name {
+		t.Errorf("identity = %+v, want %+v", identity, want)
+	}
+	if len(identity.Groups) != 1 || identity.Groups[0] != "writers" {
+		t.Errorf("groups = %v, want [writers]", identity.Groups)
+	}
+}
+
+func TestOIDCExchangeWrongVerifier(t *testing.T) {
+	provider := newMockOIDCProvider(t)
+	provider.setIDToken(provider.sign(t, provider.claims(), provider.key))
+	oidc := provider.service()
+
+	_, err := oidc.exchange(context.Background(), testOIDCCode, "some-other-verifier")
+	if err == nil {
+		t.Fatal("exchange succeeded with the wrong PKCE verifier")
+	}
+	if got := provider.tokenForm.Get("code_verifier"); got != "some-other-verifier" {
+		t.Errorf("code_verifier sent = %q, want %q", got, "some-other-verifier")
+	}
+}
+
+func TestOIDCRejectsInvalidIDTokens(t *testing.T) {
+	provider := newMockOIDCProvider(t)
+
+	otherKey, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		name  string
+		token func() string
+	}{
+		{"nonce mismatch", func() string {
+			claims := provider.claims()
+			claims["nonce"] = "another-nonce"
+			return provider.sign(t, claims, provider.key)
+		}},
+		{"missing nonce", func() string {
+			claims := provider.claims()
+			delete(claims, "nonce")
+			return provider.sign(t, claims, provider.key)
+		}},
+		{"wrong audience", func() string {
+			claims := provider.claims()
+			claims["aud"] = "another-client"
+			return provider.sign(t, claims, provider.key)
+		}},
+		{"several audiences without azp", func() string {
+			claims := provider.claims()
+			claims["aud"] = []string{testOIDCClientID, "another-client"}
+			return provider.sign(t, claims, provider.key)
+		}},
+		{"several audiences with wrong azp", func() string {
+			claims := provider.claims()
+			claims["aud"] = []string{testOIDCClientID, "another-client"}
+			claims["azp"] = "another-client"
+			return provider.sign(t, claims, provider.key)
+		}},
+		{"wrong issuer", func() string {
+			claims := provider.claims()
+			claims["iss"] = "https://evil.example"
+			return provider.sign(t, claims, provider.key)
+		}},
+		{"bad signature", func() string {
+			return provider.sign(t, provider.claims(), otherKey)
+		}},
+		{"tampered claims", func() string {
+			// The payload of one token with the signature of another
+			token := provider.sign(t, provider.claims(), provider.key)
+			claims := provider.claims()
+			claims["sub"] = "admin"
+			forged := provider.sign(t, claims, provider.key)
+			return forged[:strings.LastIndex(forged, ".")] + token[strings.LastIndex(token, "."):]
+		}},
+		{"unsigned", func() string {
+			token := jwt.NewWithClaims(jwt.SigningMethodNone, provider.claims())
+			signed, err := token.SignedString(jwt.UnsafeAllowNoneSignatureType)
+			if err != nil {
+				t.Fatal(err)
+			}
+			return signed
+		}},
+		{"HMAC with the public key", func() string {
+			token := jwt.NewWithClaims(jwt.SigningMethodHS256, provider.claims())
+			token.Header["kid"] = testOIDCKeyID
+			signed, err := token.SignedString(provider.key.N.Bytes())
+			if err != nil {
+				t.Fatal(err)
+			}
+			return signed
+		}},
+		{"expired", func() string {
+			claims := provider.claims()
+			claims["iat"] = time.Now().Add(-time.Hour).Unix()
+			claims["exp"] = time.Now().Add(-oidcClockSkew - time.Minute).Unix()
+			return provider.sign(t, claims, provider.key)
+		}},
+		{"missing expiry", func() string {
+			claims := provider.claims()
+			delete(claims, "exp")
+			return provider.sign(t, claims, provider.key)
+		}},
+		{"issued in the future", func() string {
+			claims := provider.claims()
+			claims["iat"] = time.Now().Add(oidcClockSkew + time.Minute).Unix()
+			return provider.sign(t, claims, provider.key)
+		}},
+		{"missing subject", func() string {
+			claims := provider.claims()
+			delete(claims, "sub")
+			return provider.sign(t, claims, provider.key)
+		}},
+	}
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			provider.setIDToken(tc.token())
+			oidc := provider.service()
+			ctx := context.Background()
+
+			rawIDToken, err := oidc.exchange(ctx, testOIDCCode, testOIDCVerifier)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			_, err = oidc.verifyIDToken(ctx, rawIDToken, testOIDCNonce)
+			if !errors.Is(err, ErrInvalidIDToken) {
+				t.Errorf("verifyIDToken = %v, want ErrInvalidIDToken", err)
+			}
+		})
+	}
+}
+
+func TestOIDCAcceptsAuthorizedParty(t *testing.T) {
+	provider := newMockOIDCProvider(t)
+	claims := provider.claims()
+	claims["aud"] = []string{testOIDCClientID, "another-client"}
+	claims["azp"] = testOIDCClientID
+	provider.setIDToken(provider.sign(t, claims, provider.key))
+	oidc := provider.service()
+	ctx := context.Background()
+
+	rawIDToken, err := oidc.exchange(ctx, testOIDCCode, testOIDCVerifier)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := oidc.verifyIDToken(ctx, rawIDToken, testOIDCNonce); err != nil {
+		t.Errorf("verifyIDToken = %v, want nil", err)
+	}
+}
@@ -0,0 +1,251 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"log"
+	"math/rand"
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+
+	"go-blog-platform/internal/models"
+)
+
+var ErrOutboxMessageNotFound = errors.New("outbox message not found")
+
+const (
+	outboxBaseBackoff = 30 * time.Second
+	outboxMaxBackoff  = 2 * time.Hour
+	outboxLease       = 5 * time.Minute
+)
+
+// Outbox persists outgoing email so delivery survives SMTP outages and
+// restarts. It implements Mailer: Send only enqueues, and Run delivers queued
+// messages through the underlying transport.
+type Outbox struct {
+	collection   *mongo.Collection
+	transport    Mailer
+	maxAttempts  int
+	pollInterval time.Duration
+}
+
+func NewOutbox(db *mongo.Database, transport Mailer, maxAttempts int, pollInterval time.Duration) *Outbox {
+	return &Outbox{
+		collection:   db.Collection("email_outbox"),
+		transport:    transport,
+		maxAttempts:  maxAttempts,
+		pollInterval: pollInterval,
+	}
+}
+
+// EnsureIndexes creates the index the worker polls with
+func (o *Outbox) EnsureIndexes(ctx context.Context) error {
+	_, err := o.collection.Indexes().CreateMany(ctx, []mongo.IndexModel{
+		{Keys: bson.D{{Key: "status", Value: 1}, {Key: "next_attempt_at", Value: 1}}},
+		{Keys: bson.D{{Key: "created_at", Value: -1}}},
+	})
+	return err
+}
+
+// Send enqueues the message for delivery
+func (o *Outbox) Send(ctx context.Context, msg *Message) error {
+	if _, err := msg.Bytes(); err != nil {
+		return err
+	}
+
+	now := time.Now()
+	_, err := o.collection.InsertOne(ctx, models.OutboxMessage{
+		ID:            primitive.NewObjectID(),
+		From:          msg.From,
+		To:            msg.To,
+		Subject:       msg.Subject,
+		TextBody:      msg.TextBody,
+		HTMLBody:      msg.HTMLBody,
+		Status:        models.OutboxStatusPending,
+		MaxAttempts:   o.maxAttempts,
+		NextAttemptAt: now,
+		CreatedAt:     now,
+		UpdatedAt:     now,
+	})
+	return err
+}
+
+// Run delivers queued messages until the context is cancelled. It is safe to
+// run on several server instances at once; each message is leased to a single
+// worker while it is being sent.
+func (o *Outbox) Run(ctx context.Context) {
+	ticker := time.NewTicker(o.pollInterval)
+	defer ticker.Stop()
+
+	for {
+		for {
+			delivered, err := o.deliverNext(ctx)
+			if err != nil {
+				if ctx.Err() == nil {
+					log.Printf("Outbox worker error: %v", err)
+				}
+				break
+			}
+			if !delivered {
+				break
+			}
+		}
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+// deliverNext claims one due message and attempts to deliver it. It reports
+// whether a message was claimed.
+func (o *Outbox) deliverNext(ctx context.Context) (bool, error) {
+	now := time.Now()
+	lease := now.Add(outboxLease)
+
+	var msg models.OutboxMessage
+	err := o.collection.FindOneAndUpdate(
+		ctx,
+		bson.M{"$or": []bson.M{
+			{
+				"status":          bson.M{"$in": []string{models.OutboxStatusPending, models.OutboxStatusFailed}},
+				"next_attempt_at": bson.M{"$lte": now},
+			},
+			// Messages whose worker died mid-delivery
+			{"status": models.OutboxStatusSending, "locked_until": bson.M{"$lt": now}},
+		}},
+		bson.M{
+			"$set": bson.M{"status": models.OutboxStatusSending, "locked_until": lease, "updated_at": now},
+			"$inc": bson.M{"attempts": 1},
+		},
+		options.FindOneAndUpdate().
+			SetSort(bson.D{{Key: "next_attempt_at", Value: 1}}).
+			SetReturnDocument(options.After),
+	).Decode(&msg)
+	if err == mongo.ErrNoDocuments {
+		return false, nil
+	}
+	if err != nil {
+		return false, err
+	}
+
+	sendErr := o.transport.Send(ctx, &Message{
+		From:     msg.From,
+		To:       msg.To,
+		Subject:  msg.Subject,
+		TextBody: msg.TextBody,
+		HTMLBody: msg.HTMLBody,
+	})
+
+	now = time.Now()
+	var update bson.M
+	switch {
+	case sendErr == nil:
+		// Drop the bodies once delivered; they may contain one-time links
+		update = bson.M{
+			"$set":   bson.M{"status": models.OutboxStatusSent, "sent_at": now, "updated_at": now},
+			"$unset": bson.M{"text_body": "", "html_body": "", "locked_until": "", "last_error": ""},
+		}
+	case msg.Attempts >= msg.MaxAttempts:
+		log.Printf("Outbox message %s dead-lettered after %d attempts: %v", msg.ID.Hex(), msg.Attempts, sendErr)
+		update = bson.M{
+			"$set":   bson.M{"status": models.OutboxStatusDead, "last_error": sendErr.Error(), "updated_at": now},
+			"$unset": bson.M{"locked_until": ""},
+		}
+	default:
+		update = bson.M{
+			"$set": bson.M{
+				"status":          models.OutboxStatusFailed,
+				"last_error":      sendErr.Error(),
+				"next_attempt_at": now.Add(outboxBackoff(msg.Attempts)),
+				"updated_at":      now,
+			},
+			"$unset": bson.M{"locked_until": ""},
+		}
+	}
+
+	// Only record the result if we still hold the lease
+	_, err = o.collection.UpdateOne(ctx, bson.M{"_id": msg.ID, "locked_until": lease}, update)
+	return true, err
+}
+
+// outboxBackoff returns the delay before the next attempt: exponential in the
+// number of attempts so far, capped, with up to 20% jitter
+func outboxBackoff(attempts int) time.Duration {
+	delay := outboxMaxBackoff
+	if attempts < 16 {
+		if d := outboxBaseBackoff << (attempts - 1); d < outboxMaxBackoff {
+			delay = d
+		}
+	}
+	return delay + time.Duration(rand.Int63n(int64(delay)/5+1))
+}
+
+// List returns outbox messages, newest first, optionally filtered by status
+func (o *Outbox) List(ctx context.Context, status string, limit int64) ([]models.OutboxMessage, error) {
+	filter := bson.M{}
+	if status != "" {
+		filter["status"] = status
+	}
+
+	opts := options.Find().SetSort(bson.D{{Key: "created_at", Value: -1}}).SetLimit(limit)
+	cursor, err := o.collection.Find(ctx, filter, opts)
+	if err != nil {
+		return nil, err
+	}
+	defer cursor.Close(ctx)
+
+	messages := []models.OutboxMessage{}
+	if err := cursor.All(ctx, &messages); err != nil {
+		return nil, err
+	}
+	return messages, nil
+}
+
+// Get returns a single outbox message
+func (o *Outbox) Get(ctx context.Context, id primitive.ObjectID) (*models.OutboxMessage, error) {
+	var msg models.OutboxMessage
+	err := o.collection.FindOne(ctx, bson.M{"_id": id}).Decode(&msg)
+	if err == mongo.ErrNoDocuments {
+		return nil, ErrOutboxMessageNotFound
+	}
+	if err != nil {
+		return nil, err
+	}
+	return &msg, nil
+}
+
+// Requeue puts a failed or dead-lettered message back in the queue with a
+// fresh set of attempts
+func (o *Outbox) Requeue(ctx context.Context, id primitive.ObjectID) error {
+	now := time.Now()
+	result, err := o.collection.UpdateOne(
+		ctx,
+		bson.M{
+			"_id":    id,
+			"status": bson.M{"$in": []string{models.OutboxStatusFailed, models.OutboxStatusDead}},
+		},
+		bson.M{
+			"$set": bson.M{
+				"status":          models.OutboxStatusPending,
+				"attempts":        0,
+				"next_attempt_at": now,
+				"updated_at":      now,
+			},
+			"$unset": bson.M{"last_error": ""},
+		},
+	)
+	if err != nil {
+		return err
+	}
+	if result.MatchedCount == 0 {
+		return ErrOutboxMessageNotFound
+	}
+	return nil
+}
@@ -0,0 +1,232 @@
+package services
+
+import (
+	"bufio"
+	"crypto/sha1"
+	"encoding/hex"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+	"unicode"
+	"unicode/utf8"
+)
+
+// bcryptMaxPasswordBytes is the longest password bcrypt takes into account
+const bcryptMaxPasswordBytes = 72
+
+// PasswordPolicyConfig configures the password policy
+type PasswordPolicyConfig struct {
+	MinLength int
+	// MinCharacterClasses is how many of lowercase letters, uppercase
+	// letters, digits and symbols a password must mix
+	MinCharacterClasses int
+	// BreachedHashesFile lists the SHA-1 hashes of known-breached passwords.
+	// Empty disables the check.
+	BreachedHashesFile string
+}
+
+// PasswordPolicyError lists every rule a password breaks
+type PasswordPolicyError struct {
+	Problems []string
+}
+
+func (e *PasswordPolicyError) Error() string {
+	return "password does not meet the policy: " + strings.Join(e.Problems, "; ")
+}
+
+// PasswordPolicy decides which passwords users may choose
+type PasswordPolicy struct {
+	config   PasswordPolicyConfig
+	breached *BreachedPasswordList
+}
+
+func NewPasswordPolicy(config PasswordPolicyConfig) (*PasswordPolicy, error) {
+	policy := &PasswordPolicy{config: config}
+
+	if config.BreachedHashesFile != "" {
+		list, err := OpenBreachedPasswordList(config.BreachedHashesFile)
+		if err != nil {
+			return nil, err
+		}
+		policy.breached = list
+	}
+
+	return policy, nil
+}
+
+// Validate checks a new password for the user with the given username and
+// email address. It returns a *PasswordPolicyError if the password is not
+// acceptable.
+func (p *PasswordPolicy) Validate(password, username, email string) error {
+	var problems []string
+
+	if utf8.RuneCountInString(password) < p.config.MinLength {
+		problems = append(problems, fmt.Sprintf("must be at least %d characters long", p.config.MinLength))
+	}
+	if len(password) > bcryptMaxPasswordBytes {
+		problems = append(problems, fmt.Sprintf("must be at most %d bytes long", bcryptMaxPasswordBytes))
+	}
+	if characterClasses(password) < p.config.MinCharacterClasses {
+		problems = append(problems, fmt.Sprintf("must mix at least %d of lowercase letters, uppercase letters, digits and symbols", p.config.MinCharacterClasses))
+	}
+	if containsPersonalInfo(password, username, email) {
+		problems = append(problems, "must not contain your username or email address")
+	}
+
+	// Only worth the lookup if the password is otherwise acceptable
+	if len(problems) == 0 && p.breached != nil {
+		breached, err := p.breached.Contains(password)
+		if err != nil {
+			return err
+		}
+		if breached {
+			problems = append(problems, "appears in a list of breached passwords")
+		}
+	}
+
+	if len(problems) > 0 {
+		return &PasswordPolicyError{Problems: problems}
+	}
+	return nil
+}
+
+func characterClasses(password string) int {
+	var lower, upper, digit, symbol bool
+	for _, r := range password {
+		switch {
+		case unicode.IsLower(r):
+			lower = true
+		case unicode.IsUpper(r):
+			upper = true
+		case unicode.IsDigit(r):
+			digit = true
+		default:
+			symbol = true
+		}
+	}
+
+	count := 0
+	for _, present := range []bool{lower, upper, digit, symbol} {
+		if present {
+			count++
+		}
+	}
+	return count
+}
+
+func containsPersonalInfo(password, username, email string) bool {
+	password = strings.ToLower(password)
+	local, _, _ := strings.Cut(email, "@")
+
+	// Very short values would reject too many unrelated passwords
+	for _, value := range []string{username, email, local} {
+		value = strings.ToLower(strings.TrimSpace(value))
+		if len(value) >= 3 && strings.Contains(password, value) {
+			return true
+		}
+	}
+	return false
+}
+
+// BreachedPasswordList looks passwords up in a file of SHA-1 hashes ordered
+// by hash, one "HASH" or "HASH:COUNT" per line, as in the Pwned Passwords
+// download. Like the k-anonymity range API, a lookup locates the block of
+// hashes sharing the password hash's first five characters and compares the
+// rest within it. The file is searched on disk, so it can be many gigabytes.
+type BreachedPasswordList struct {
+	path string
+}
+
+// OpenBreachedPasswordList checks that the hash list file is readable
+func OpenBreachedPasswordList(path string) (*BreachedPasswordList, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, fmt.Errorf("open breached password list: %w", err)
+	}
+	f.Close()
+
+	return &BreachedPasswordList{path: path}, nil
+}
+
+// Contains reports whether the password's hash is in the list
+func (l *BreachedPasswordList) Contains(password string) (bool, error) {
+	sum := sha1.Sum([]byte(password))
+	hash := strings.ToUpper(hex.EncodeToString(sum[:]))
+	prefix := hash[:5]
+
+	f, err := os.Open(l.path)
+	if err != nil {
+		return false, err
+	}
+	defer f.Close()
+
+	info, err := f.Stat()
+	if err != nil {
+		return false, err
+	}
+	size := info.Size()
+
+	// Find the smallest offset whose next line is not before the prefix
+	lo, hi := int64(0), size
+	for lo < hi {
+		mid := lo + (hi-lo)/2
+		start, line, err := lineAt(f, mid, size)
+		if err != nil {
+			return false, err
+		}
+		if start >= size || hashOf(line) >= prefix {
+			hi = mid
+		} else {
+			lo = mid + 1
+		}
+	}
+
+	start, _, err := lineAt(f, lo, size)
+	if err != nil {
+		return false, err
+	}
+
+	// Scan the block of hashes with the same prefix
+	scanner := bufio.NewScanner(io.NewSectionReader(f, start, size-start))
+	for scanner.Scan() {
+		candidate := hashOf(scanner.Text())
+		if !strings.HasPrefix(candidate, prefix) {
+			break
+		}
+		if candidate == hash {
+			return true, nil
+		}
+	}
+	return false, scanner.Err()
+}
+
+// lineAt returns the first complete line starting at or after offset, and
+// where it starts
+func lineAt(f *os.File, offset, size int64) (int64, string, error) {
+	start := offset
+	if offset > 0 {
+		// Skip the rest of the line offset points into
+		r := bufio.NewReader(io.NewSectionReader(f, offset-1, size-offset+1))
+		skipped, err := r.ReadString('\n')
+		if err == io.EOF {
+			return size, "", nil
+		}
+		if err != nil {
+			return 0, "", err
+		}
+		start = offset - 1 + int64(len(skipped))
+	}
+
+	r := bufio.NewReader(io.NewSectionReader(f, start, size-start))
+	line, err := r.ReadString('\n')
+	if err != nil && err != io.EOF {
+		return 0, "", err
+	}
+	return start, line, nil
+}
+
+func hashOf(line string) string {
+	hash, _, _ := strings.Cut(strings.TrimSpace(line), ":")
+	return strings.ToUpper(hash)
+}
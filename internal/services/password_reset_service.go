@@ -0,0 +1,123 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+
+	"go-blog-platform/internal/models"
+)
+
+var ErrInvalidResetToken = errors.New("invalid or expired reset token")
+
+// PasswordResetService issues single-use password reset tokens. Only the
+// SHA-256 hash of each token is stored.
+type PasswordResetService struct {
+	collection *mongo.Collection
+	ttl        time.Duration
+}
+
+func NewPasswordResetService(db *mongo.Database, ttl time.Duration) *PasswordResetService {
+	return &PasswordResetService{
+		collection: db.Collection("password_resets"),
+		ttl:        ttl,
+	}
+}
+
+// TTL returns how long a reset token stays valid
+func (s *PasswordResetService) TTL() time.Duration {
+	return s.ttl
+}
+
+// EnsureIndexes creates the lookup indexes and lets MongoDB remove tokens
+// once they have expired.
+func (s *PasswordResetService) EnsureIndexes(ctx context.Context) error {
+	_, err := s.collection.Indexes().CreateMany(ctx, []mongo.IndexModel{
+		{Keys: bson.D{{Key: "token_hash", Value: 1}}, Options: options.Index().SetUnique(true)},
+		{Keys: bson.D{{Key: "user_id", Value: 1}}},
+		{Keys: bson.D{{Key: "expires_at", Value: 1}}, Options: options.Index().SetExpireAfterSeconds(0)},
+	})
+	return err
+}
+
+// Create issues a new reset token for the user and returns it in plaintext.
+// Any token issued to the user earlier stops working.
+func (s *PasswordResetService) Create(ctx context.Context, userID primitive.ObjectID) (string, error) {
+	token, err := generateOpaqueToken()
+	if err != nil {
+		return "", err
+	}
+
+	if err := s.InvalidateAll(ctx, userID); err != nil {
+		return "", err
+	}
+
+	now := time.Now()
+	_, err = s.collection.InsertOne(ctx, models.PasswordResetToken{
+		ID:        primitive.NewObjectID(),
+		UserID:    userID,
+		TokenHash: hashOpaqueToken(token),
+		ExpiresAt: now.Add(s.ttl),
+		CreatedAt: now,
+	})
+	if err != nil {
+		return "", err
+	}
+
+	return token, nil
+}
+
+// Lookup returns a valid token without using it up
+func (s *PasswordResetService) Lookup(ctx context.Context, token string) (*models.PasswordResetToken, error) {
+	var resetToken models.PasswordResetToken
+	err := s.collection.FindOne(ctx, bson.M{
+		"token_hash": hashOpaqueToken(token),
+		"used":       false,
+		"expires_at": bson.M{"$gt": time.Now()},
+	}).Decode(&resetToken)
+	if err == mongo.ErrNoDocuments {
+		return nil, ErrInvalidResetToken
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	return &resetToken, nil
+}
+
+// Consume marks the token as used and returns it. It succeeds at most once
+// per token, even under concurrent requests.
+func (s *PasswordResetService) Consume(ctx context.Context, token string) (*models.PasswordResetToken, error) {
+	now := time.Now()
+
+	var resetToken models.PasswordResetToken
+	err := s.collection.FindOneAndUpdate(
+		ctx,
+		bson.M{
+			"token_hash": hashOpaqueToken(token),
+			"used":       false,
+			"expires_at": bson.M{"$gt": now},
+		},
+		bson.M{"$set": bson.M{"used": true, "used_at": now}},
+		options.FindOneAndUpdate().SetReturnDocument(options.After),
+	).Decode(&resetToken)
+	if err == mongo.ErrNoDocuments {
+		return nil, ErrInvalidResetToken
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	return &resetToken, nil
+}
+
+// InvalidateAll removes every unused reset token of the user
+func (s *PasswordResetService) InvalidateAll(ctx context.Context, userID primitive.ObjectID) error {
+	_, err := s.collection.DeleteMany(ctx, bson.M{"user_id": userID, "used": false})
+	return err
+}
@@ -0,0 +1,75 @@
+package services
+
+import (
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+// ErrInvalidCursor is returned for a page cursor that is malformed or was
+// issued for a different sort
+var ErrInvalidCursor = errors.New("invalid cursor")
+
+// PostCursor marks the last post of a page of posts sorted by Field, then by
+// ID. Clients get it as an opaque string and send it back for the next page.
+type PostCursor struct {
+	Field string             `json:"f"`
+	Desc  bool               `json:"d,omitempty"`
+	Value *time.Time         `json:"v,omitempty"` // nil if the post has no value in Field
+	ID    primitive.ObjectID `json:"id"`
+}
+
+// Encode returns the cursor as a URL-safe string
+func (c *PostCursor) Encode() string {
+	data, _ := json.Marshal(c)
+	return base64.RawURLEncoding.EncodeToString(data)
+}
+
+// DecodePostCursor parses a cursor returned by Encode. It must have been
+// issued for the same sort field and order.
+func DecodePostCursor(s, field string, desc bool) (*PostCursor, error) {
+	data, err := base64.RawURLEncoding.DecodeString(s)
+	if err != nil {
+		return nil, ErrInvalidCursor
+	}
+
+	var cursor PostCursor
+	if err := json.Unmarshal(data, &cursor); err != nil {
+		return nil, ErrInvalidCursor
+	}
+	if cursor.Field != field || cursor.Desc != desc || cursor.ID.IsZero() {
+		return nil, ErrInvalidCursor
+	}
+	return &cursor, nil
+}
+
+// After returns a filter for the posts that come after the cursor. Posts
+// without a value in Field come first in ascending order and last in
+// descending order, as MongoDB sorts them.
+func (c *PostCursor) After() bson.M {
+	op := "$gt"
+	if c.Desc {
+		op = "$lt"
+	}
+
+	if c.Value == nil {
+		tie := bson.M{c.Field: nil, "_id": bson.M{op: c.ID}}
+		if c.Desc {
+			return tie
+		}
+		return bson.M{"$or": bson.A{tie, bson.M{c.Field: bson.M{"$ne": nil}}}}
+	}
+
+	after := bson.A{
+		bson.M{c.Field: bson.M{op: *c.Value}},
+		bson.M{c.Field: *c.Value, "_id": bson.M{op: c.ID}},
+	}
+	if c.Desc {
+		after = append(after, bson.M{c.Field: nil})
+	}
+	return bson.M{"$or": after}
+}
@@ -0,0 +1,149 @@
+package services
+
+import (
+	"context"
+	"log"
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+
+	"go-blog-platform/internal/constants"
+	"go-blog-platform/internal/models"
+)
+
+const postSchedulerLeaseID = "post-scheduler"
+
+// PostScheduler publishes scheduled posts and archives published posts whose
+// unpublish time has passed. Only the server instance holding the lease
+// document does the work; if it stops, another takes over once the lease
+// expires.
+type PostScheduler struct {
+	posts    *mongo.Collection
+	leases   *mongo.Collection
+	owner    string
+	interval time.Duration
+	leaseTTL time.Duration
+}
+
+func NewPostScheduler(db *mongo.Database, interval time.Duration) *PostScheduler {
+	return &PostScheduler{
+		posts:    db.Collection("posts"),
+		leases:   db.Collection("leases"),
+		owner:    primitive.NewObjectID().Hex(),
+		interval: interval,
+		leaseTTL: 3 * interval,
+	}
+}
+
+// EnsureIndexes creates the indexes the scheduler polls with
+func (s *PostScheduler) EnsureIndexes(ctx context.Context) error {
+	_, err := s.posts.Indexes().CreateMany(ctx, []mongo.IndexModel{
+		{Keys: bson.D{{Key: "status", Value: 1}, {Key: "publish_at", Value: 1}}},
+		{Keys: bson.D{{Key: "status", Value: 1}, {Key: "unpublish_at", Value: 1}}},
+	})
+	return err
+}
+
+// Run checks for due posts until the context is cancelled
+func (s *PostScheduler) Run(ctx context.Context) {
+	ticker := time.NewTicker(s.interval)
+	defer ticker.Stop()
+
+	for {
+		if err := s.tick(ctx); err != nil && ctx.Err() == nil {
+			log.Printf("Post scheduler error: %v", err)
+		}
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+func (s *PostScheduler) tick(ctx context.Context) error {
+	leader, err := s.acquireLease(ctx)
+	if err != nil || !leader {
+		return err
+	}
+
+	if err := s.publishDue(ctx); err != nil {
+		return err
+	}
+	return s.unpublishDue(ctx)
+}
+
+// acquireLease takes or renews the scheduler lease and reports whether this
+// instance holds it
+func (s *PostScheduler) acquireLease(ctx context.Context) (bool, error) {
+	now := time.Now()
+	_, err := s.leases.UpdateOne(
+		ctx,
+		bson.M{"_id": postSchedulerLeaseID, "$or": []bson.M{
+			{"owner": s.owner},
+			{"expires_at": bson.M{"$lt": now}},
+		}},
+		bson.M{"$set": bson.M{"owner": s.owner, "expires_at": now.Add(s.leaseTTL)}},
+		options.Update().SetUpsert(true),
+	)
+	// The upsert collides with the lease document while another instance holds it
+	if mongo.IsDuplicateKeyError(err) {
+		return false, nil
+	}
+	return err == nil, err
+}
+
+// publishDue publishes scheduled posts whose publish time has passed
+func (s *PostScheduler) publishDue(ctx context.Context) error {
+	return s.moveDue(ctx, "publish_at", constants.PostStatusScheduled, constants.PostStatusPublished, PostActionPublish)
+}
+
+// unpublishDue archives published posts whose unpublish time has passed
+func (s *PostScheduler) unpublishDue(ctx context.Context) error {
+	return s.moveDue(ctx, "unpublish_at", constants.PostStatusPublished, constants.PostStatusArchived, PostActionArchive)
+}
+
+func (s *PostScheduler) moveDue(ctx context.Context, field, from, to, action string) error {
+	now := time.Now()
+	cursor, err := s.posts.Find(ctx, bson.M{"status": from, field: bson.M{"$lte": now}})
+	if err != nil {
+		return err
+	}
+	defer cursor.Close(ctx)
+
+	var posts []models.Post
+	if err := cursor.All(ctx, &posts); err != nil {
+		return err
+	}
+
+	for _, post := range posts {
+		set := bson.M{"status": to, "updated_at": now}
+		if to == constants.PostStatusPublished && post.PublishedAt == nil {
+			set["published_at"] = post.PublishAt
+		}
+
+		// Skip posts that were rescheduled or withdrawn since the query
+		_, err := s.posts.UpdateOne(
+			ctx,
+			bson.M{"_id": post.ID, "status": from, field: bson.M{"$lte": now}},
+			bson.M{
+				"$set":   set,
+				"$unset": bson.M{field: ""},
+				"$push": bson.M{"status_history": models.StatusChange{
+					Action: action,
+					From:   from,
+					To:     to,
+					At:     now,
+				}},
+			},
+		)
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
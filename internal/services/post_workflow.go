@@ -0,0 +1,107 @@
+package services
+
+import (
+	"errors"
+
+	"go-blog-platform/internal/constants"
+)
+
+var ErrInvalidTransition = errors.New("invalid status transition")
+
+// Review workflow actions. PostActionCreate only appears in status histories.
+const (
+	PostActionCreate   = "create"
+	PostActionSubmit   = "submit"
+	PostActionWithdraw = "withdraw"
+	PostActionApprove  = "approve"
+	PostActionReject   = "reject"
+	PostActionSchedule = "schedule"
+	PostActionPublish  = "publish"
+	PostActionArchive  = "archive"
+)
+
+// PostTransition is a step of the review workflow
+type PostTransition struct {
+	Action string
+	From   []string
+	To     string
+
+	// Permission is needed on top of the relation to the post below
+	Permission string
+
+	// ByReviewer marks steps taken by reviewers, who may not review posts
+	// they wrote. Other steps are taken by the post's author, co-authors and
+	// editors.
+	ByReviewer bool
+
+	// RequiresComment marks steps that must explain themselves to the author
+	RequiresComment bool
+}
+
+// PostTransitions is the review workflow:
+// draft → in_review → approved → (scheduled →) published, with rejected
+// sending a post back to its authors. PostScheduler publishes scheduled posts.
+var PostTransitions = []PostTransition{
+	{
+		Action: PostActionSubmit,
+		From:   []string{constants.PostStatusDraft, constants.PostStatusRejected},
+		To:     constants.PostStatusInReview,
+	},
+	{
+		Action: PostActionWithdraw,
+		From: []string{
+			constants.PostStatusInReview, constants.PostStatusApproved, constants.PostStatusRejected,
+			constants.PostStatusScheduled, constants.PostStatusPublished, constants.PostStatusArchived,
+		},
+		To: constants.PostStatusDraft,
+	},
+	{
+		Action:     PostActionApprove,
+		From:       []string{constants.PostStatusInReview},
+		To:         constants.PostStatusApproved,
+		Permission: constants.PermPostReview,
+		ByReviewer: true,
+	},
+	{
+		Action:          PostActionReject,
+		From:            []string{constants.PostStatusInReview, constants.PostStatusApproved},
+		To:              constants.PostStatusRejected,
+		Permission:      constants.PermPostReview,
+		ByReviewer:      true,
+		RequiresComment: true,
+	},
+	{
+		Action:     PostActionSchedule,
+		From:       []string{constants.PostStatusApproved, constants.PostStatusScheduled},
+		To:         constants.PostStatusScheduled,
+		Permission: constants.PermPostPublish,
+	},
+	{
+		Action:     PostActionPublish,
+		From:       []string{constants.PostStatusApproved, constants.PostStatusScheduled},
+		To:         constants.PostStatusPublished,
+		Permission: constants.PermPostPublish,
+	},
+	{
+		Action: PostActionArchive,
+		From:   []string{constants.PostStatusPublished},
+		To:     constants.PostStatusArchived,
+	},
+}
+
+// FindPostTransition returns the step for an action on a post with the given
+// status, or ErrInvalidTransition if the action does not apply to it
+func FindPostTransition(action, status string) (*PostTransition, error) {
+	for i := range PostTransitions {
+		transition := &PostTransitions[i]
+		if transition.Action != action {
+			continue
+		}
+		for _, from := range transition.From {
+			if from == status {
+				return transition, nil
+			}
+		}
+	}
+	return nil, ErrInvalidTransition
+}
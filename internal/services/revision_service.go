@@ -0,0 +1,183 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+
+	"go-blog-platform/internal/models"
+)
+
+// revisionRecordAttempts bounds the retries when concurrent edits race for
+// the same revision number
+const revisionRecordAttempts = 5
+
+var (
+	ErrRevisionNotFound = errors.New("revision not found")
+	ErrRevisionConflict = errors.New("too many concurrent edits, try again")
+)
+
+// RevisionService stores the revision history of posts
+type RevisionService struct {
+	collection *mongo.Collection
+}
+
+func NewRevisionService(db *mongo.Database) *RevisionService {
+	return &RevisionService{
+		collection: db.Collection("post_revisions"),
+	}
+}
+
+// EnsureIndexes creates the index that numbers revisions uniquely per post
+func (s *RevisionService) EnsureIndexes(ctx context.Context) error {
+	_, err := s.collection.Indexes().CreateOne(ctx, mongo.IndexModel{
+		Keys:    bson.D{{Key: "post_id", Value: 1}, {Key: "number", Value: -1}},
+		Options: options.Index().SetUnique(true),
+	})
+	return err
+}
+
+// Record stores the post's title, content and tags as its next revision.
+// restoredFrom is the number of the revision being restored, or 0.
+func (s *RevisionService) Record(ctx context.Context, post *models.Post, editorID primitive.ObjectID, restoredFrom int) (*models.PostRevision, error) {
+	for attempt := 0; attempt < revisionRecordAttempts; attempt++ {
+		latest, err := s.latestNumber(ctx, post.ID)
+		if err != nil {
+			return nil, err
+		}
+
+		revision := &models.PostRevision{
+			ID:           primitive.NewObjectID(),
+			PostID:       post.ID,
+			Number:       latest + 1,
+			Title:        post.Title,
+			Content:      post.Content,
+			Tags:         post.Tags,
+			EditorID:     editorID,
+			RestoredFrom: restoredFrom,
+			CreatedAt:    time.Now(),
+		}
+		_, err = s.collection.InsertOne(ctx, revision)
+		// Another edit took the number first
+		if mongo.IsDuplicateKeyError(err) {
+			continue
+		}
+		if err != nil {
+			return nil, err
+		}
+		return revision, nil
+	}
+	return nil, ErrRevisionConflict
+}
+
+// RecordBaseline stores the post as it is now as revision 1, credited to its
+// author, unless it already has revisions. Posts written before revisions
+// were kept get one before their first change.
+func (s *RevisionService) RecordBaseline(ctx context.Context, post *models.Post) error {
+	_, err := s.collection.InsertOne(ctx, models.PostRevision{
+		ID:        primitive.NewObjectID(),
+		PostID:    post.ID,
+		Number:    1,
+		Title:     post.Title,
+		Content:   post.Content,
+		Tags:      post.Tags,
+		EditorID:  post.AuthorID,
+		CreatedAt: post.UpdatedAt,
+	})
+	if mongo.IsDuplicateKeyError(err) {
+		return nil
+	}
+	return err
+}
+
+// List returns the post's revisions without their content, newest first
+func (s *RevisionService) List(ctx context.Context, postID primitive.ObjectID) ([]models.PostRevision, error) {
+	opts := options.Find().
+		SetSort(bson.D{{Key: "number", Value: -1}}).
+		SetProjection(bson.M{"content": 0})
+	cursor, err := s.collection.Find(ctx, bson.M{"post_id": postID}, opts)
+	if err != nil {
+		return nil, err
+	}
+	defer cursor.Close(ctx)
+
+	revisions := []models.PostRevision{}
+	if err := cursor.All(ctx, &revisions); err != nil {
+		return nil, err
+	}
+	return revisions, nil
+}
+
+// Get returns one revision of the post
+func (s *RevisionService) Get(ctx context.Context, postID primitive.ObjectID, number int) (*models.PostRevision, error) {
+	var revision models.PostRevision
+	err := s.collection.FindOne(ctx, bson.M{"post_id": postID, "number": number}).Decode(&revision)
+	if err == mongo.ErrNoDocuments {
+		return nil, ErrRevisionNotFound
+	}
+	if err != nil {
+		return nil, err
+	}
+	return &revision, nil
+}
+
+// Diff returns the changes between two revisions of the post as a unified
+// diff covering the title, tags and content. Revisions too large or too
+// different to compare give ErrDiffTooLarge.
+func (s *RevisionService) Diff(ctx context.Context, postID primitive.ObjectID, from, to int) (string, error) {
+	a, err := s.Get(ctx, postID, from)
+	if err != nil {
+		return "", err
+	}
+	b, err := s.Get(ctx, postID, to)
+	if err != nil {
+		return "", err
+	}
+
+	return UnifiedDiff(
+		fmt.Sprintf("revision %d", from),
+		fmt.Sprintf("revision %d", to),
+		revisionText(a),
+		revisionText(b),
+	)
+}
+
+// DeleteForPost removes the history of a deleted post
+func (s *RevisionService) DeleteForPost(ctx context.Context, postID primitive.ObjectID) error {
+	_, err := s.collection.DeleteMany(ctx, bson.M{"post_id": postID})
+	return err
+}
+
+// Discard removes a revision recorded for a change to the post that then
+// failed, so the history only holds text the post actually had
+func (s *RevisionService) Discard(ctx context.Context, revision *models.PostRevision) error {
+	_, err := s.collection.DeleteOne(ctx, bson.M{"_id": revision.ID})
+	return err
+}
+
+func (s *RevisionService) latestNumber(ctx context.Context, postID primitive.ObjectID) (int, error) {
+	var latest models.PostRevision
+	opts := options.FindOne().
+		SetSort(bson.D{{Key: "number", Value: -1}}).
+		SetProjection(bson.M{"number": 1})
+	err := s.collection.FindOne(ctx, bson.M{"post_id": postID}, opts).Decode(&latest)
+	if err == mongo.ErrNoDocuments {
+		return 0, nil
+	}
+	if err != nil {
+		return 0, err
+	}
+	return latest.Number, nil
+}
+
+// revisionText lays a revision out as the text that is diffed
+func revisionText(revision *models.PostRevision) string {
+	return fmt.Sprintf("Title: %s\nTags: %s\n\n%s", revision.Title, strings.Join(revision.Tags, ", "), revision.Content)
+}
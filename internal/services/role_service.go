@@ -0,0 +1,293 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"regexp"
+	"sync"
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+
+	"go-blog-platform/internal/constants"
+	"go-blog-platform/internal/models"
+)
+
+var (
+	ErrRoleNotFound      = errors.New("role not found")
+	ErrRoleExists        = errors.New("role already exists")
+	ErrInvalidRoleName   = errors.New("invalid role name")
+	ErrInvalidPermission = errors.New("invalid permission")
+	ErrBuiltInRole       = errors.New("built-in role cannot be changed")
+	ErrRoleInUse         = errors.New("role is assigned to users")
+)
+
+// roleCacheTTL bounds how long other server instances keep using a role's
+// old permissions after an admin changes it
+const roleCacheTTL = 30 * time.Second
+
+var roleNamePattern = regexp.MustCompile(`^[a-z][a-z0-9_-]{1,31}$`)
+
+// RoleService stores roles, each a named set of permissions, in the roles
+// collection. Roles are cached in memory because every request looks up the
+// permissions of its caller's role.
+type RoleService struct {
+	collection *mongo.Collection
+	users      *mongo.Collection
+
+	mu       sync.Mutex
+	roles    map[string]models.Role
+	loadedAt time.Time
+}
+
+func NewRoleService(db *mongo.Database) *RoleService {
+	return &RoleService{
+		collection: db.Collection("roles"),
+		users:      db.Collection("users"),
+	}
+}
+
+// EnsureDefaults creates the built-in roles. Changes admins made to built-in
+// roles are kept, except that the admin role always holds every permission.
+func (s *RoleService) EnsureDefaults(ctx context.Context) error {
+	now := time.Now()
+	for _, name := range constants.BuiltInRoles {
+		update := bson.M{
+			"$set":         bson.M{"built_in": true},
+			"$setOnInsert": bson.M{"created_at": now, "updated_at": now},
+		}
+		if name == constants.RoleAdmin {
+			update["$set"].(bson.M)["permissions"] = constants.AllPermissions
+		} else {
+			update["$setOnInsert"].(bson.M)["permissions"] = constants.DefaultRolePermissions[name]
+		}
+
+		_, err := s.collection.UpdateOne(ctx, bson.M{"_id": name}, update, options.Update().SetUpsert(true))
+		if err != nil {
+			return err
+		}
+	}
+
+	s.invalidate()
+	return nil
+}
+
+// List returns every role, ordered by name
+func (s *RoleService) List(ctx context.Context) ([]models.Role, error) {
+	opts := options.Find().SetSort(bson.D{{Key: "_id", Value: 1}})
+	cursor, err := s.collection.Find(ctx, bson.M{}, opts)
+	if err != nil {
+		return nil, err
+	}
+	defer cursor.Close(ctx)
+
+	roles := []models.Role{}
+	if err := cursor.All(ctx, &roles); err != nil {
+		return nil, err
+	}
+	return roles, nil
+}
+
+// Get returns a role by name
+func (s *RoleService) Get(ctx context.Context, name string) (*models.Role, error) {
+	roles, err := s.load(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	role, ok := roles[name]
+	if !ok {
+		return nil, ErrRoleNotFound
+	}
+	return &role, nil
+}
+
+// Create defines a new role
+func (s *RoleService) Create(ctx context.Context, name, description string, permissions []string) (*models.Role, error) {
+	if !roleNamePattern.MatchString(name) {
+		return nil, ErrInvalidRoleName
+	}
+
+	permissions, err := normalizePermissions(permissions)
+	if err != nil {
+		return nil, err
+	}
+
+	now := time.Now()
+	role := &models.Role{
+		Name:        name,
+		Description: description,
+		Permissions: permissions,
+		CreatedAt:   now,
+		UpdatedAt:   now,
+	}
+	_, err = s.collection.InsertOne(ctx, role)
+	if mongo.IsDuplicateKeyError(err) {
+		return nil, ErrRoleExists
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	s.invalidate()
+	return role, nil
+}
+
+// Update replaces a role's description and permissions. The admin role
+// cannot be changed.
+func (s *RoleService) Update(ctx context.Context, name, description string, permissions []string) (*models.Role, error) {
+	if name == constants.RoleAdmin {
+		return nil, ErrBuiltInRole
+	}
+
+	permissions, err := normalizePermissions(permissions)
+	if err != nil {
+		return nil, err
+	}
+
+	var role models.Role
+	err = s.collection.FindOneAndUpdate(
+		ctx,
+		bson.M{"_id": name},
+		bson.M{"$set": bson.M{
+			"description": description,
+			"permissions": permissions,
+			"updated_at":  time.Now(),
+		}},
+		options.FindOneAndUpdate().SetReturnDocument(options.After),
+	).Decode(&role)
+	if err == mongo.ErrNoDocuments {
+		return nil, ErrRoleNotFound
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	s.invalidate()
+	return &role, nil
+}
+
+// Delete removes a custom role that no user has
+func (s *RoleService) Delete(ctx context.Context, name string) error {
+	role, err := s.Get(ctx, name)
+	if err != nil {
+		return err
+	}
+	if role.BuiltIn {
+		return ErrBuiltInRole
+	}
+
+	count, err := s.users.CountDocuments(ctx, bson.M{"role": name})
+	if err != nil {
+		return err
+	}
+	if count > 0 {
+		return ErrRoleInUse
+	}
+
+	result, err := s.collection.DeleteOne(ctx, bson.M{"_id": name})
+	if err != nil {
+		return err
+	}
+	if result.DeletedCount == 0 {
+		return ErrRoleNotFound
+	}
+
+	s.invalidate()
+	return nil
+}
+
+// Permissions returns the permissions of a role. Unknown roles have none.
+func (s *RoleService) Permissions(ctx context.Context, name string) ([]string, error) {
+	roles, err := s.load(ctx)
+	if err != nil {
+		return nil, err
+	}
+	return roles[name].Permissions, nil
+}
+
+// Covers reports whether a role holds every permission of another role, so
+// that a requirement placed on the other role extends to it
+func (s *RoleService) Covers(ctx context.Context, name, other string) (bool, error) {
+	if name == other {
+		return true, nil
+	}
+
+	roles, err := s.load(ctx)
+	if err != nil {
+		return false, err
+	}
+	role, ok := roles[name]
+	if !ok {
+		return false, nil
+	}
+
+	held := make(map[string]bool, len(role.Permissions))
+	for _, permission := range role.Permissions {
+		held[permission] = true
+	}
+	for _, permission := range roles[other].Permissions {
+		if !held[permission] {
+			return false, nil
+		}
+	}
+	return true, nil
+}
+
+func (s *RoleService) load(ctx context.Context) (map[string]models.Role, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if s.roles != nil && time.Since(s.loadedAt) < roleCacheTTL {
+		return s.roles, nil
+	}
+
+	cursor, err := s.collection.Find(ctx, bson.M{})
+	if err != nil {
+		return nil, err
+	}
+	defer cursor.Close(ctx)
+
+	var list []models.Role
+	if err := cursor.All(ctx, &list); err != nil {
+		return nil, err
+	}
+
+	roles := make(map[string]models.Role, len(list))
+	for _, role := range list {
+		roles[role.Name] = role
+	}
+
+	s.roles = roles
+	s.loadedAt = time.Now()
+	return s.roles, nil
+}
+
+func (s *RoleService) invalidate() {
+	s.mu.Lock()
+	s.roles = nil
+	s.mu.Unlock()
+}
+
+// normalizePermissions checks every permission is known and removes duplicates
+func normalizePermissions(permissions []string) ([]string, error) {
+	known := make(map[string]bool, len(constants.AllPermissions))
+	for _, permission := range constants.AllPermissions {
+		known[permission] = true
+	}
+
+	result := []string{}
+	seen := make(map[string]bool)
+	for _, permission := range permissions {
+		if !known[permission] {
+			return nil, ErrInvalidPermission
+		}
+		if !seen[permission] {
+			seen[permission] = true
+			result = append(result, permission)
+		}
+	}
+	return result, nil
+}
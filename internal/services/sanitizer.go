@@ -0,0 +1,96 @@
+package services
+
+import (
+	"fmt"
+	"regexp"
+
+	"github.com/microcosm-cc/bluemonday"
+)
+
+// Sanitizer policies for user-written HTML
+const (
+	// SanitizerPolicyUGC keeps formatting, links, images, lists and tables
+	SanitizerPolicyUGC = "ugc"
+	// SanitizerPolicyStrict removes all markup
+	SanitizerPolicyStrict = "strict"
+)
+
+var (
+	// Heading anchors are made of letters, digits and marks of any script
+	anchorIDPattern = regexp.MustCompile(`^[\p{L}\p{M}\p{N}:_.-]+$`)
+
+	footnoteClassPattern = regexp.MustCompile(`^(footnotes|footnote-ref|footnote-backref)$`)
+	footnoteRolePattern  = regexp.MustCompile(`^(doc-endnotes|doc-noteref|doc-backlink)$`)
+	emptyPattern         = regexp.MustCompile(`^$`)
+	checkboxPattern      = regexp.MustCompile(`^checkbox$`)
+)
+
+type SanitizerConfig struct {
+	// Policy is SanitizerPolicyUGC or SanitizerPolicyStrict
+	Policy string
+	// AllowedElements are allowed, without attributes, on top of the policy
+	AllowedElements []string
+	// URLSchemes are allowed in links and images besides http, https and mailto
+	URLSchemes []string
+}
+
+// Sanitizer removes markup that could run scripts or change the page from
+// user-written text, keeping only what an allowlist permits. It is applied
+// when text is saved, so stored content is safe to embed.
+type Sanitizer struct {
+	rich     *bluemonday.Policy
+	rendered *bluemonday.Policy
+	plain    *bluemonday.Policy
+}
+
+func NewSanitizer(cfg SanitizerConfig) (*Sanitizer, error) {
+	var rich *bluemonday.Policy
+	switch cfg.Policy {
+	case SanitizerPolicyUGC:
+		rich = bluemonday.UGCPolicy()
+	case SanitizerPolicyStrict:
+		rich = bluemonday.StrictPolicy()
+	default:
+		return nil, fmt.Errorf("unknown sanitizer policy %q", cfg.Policy)
+	}
+
+	// Rendered Markdown also needs the markup the renderer adds itself
+	rendered := bluemonday.UGCPolicy()
+	rendered.AllowAttrs("id").Matching(anchorIDPattern).OnElements("h1", "h2", "h3", "h4", "h5", "h6", "li", "sup")
+	rendered.AllowAttrs("class").Matching(footnoteClassPattern).OnElements("a", "div")
+	rendered.AllowAttrs("role").Matching(footnoteRolePattern).OnElements("a", "div")
+	rendered.AllowAttrs("type").Matching(checkboxPattern).OnElements("input")
+	rendered.AllowAttrs("checked", "disabled").Matching(emptyPattern).OnElements("input")
+
+	for _, policy := range []*bluemonday.Policy{rich, rendered} {
+		if len(cfg.AllowedElements) > 0 {
+			policy.AllowElements(cfg.AllowedElements...)
+		}
+		if len(cfg.URLSchemes) > 0 {
+			policy.AllowURLSchemes(cfg.URLSchemes...)
+		}
+	}
+
+	return &Sanitizer{
+		rich:     rich,
+		rendered: rendered,
+		plain:    bluemonday.StrictPolicy(),
+	}, nil
+}
+
+// HTML sanitizes a rich-text field with the configured policy
+func (s *Sanitizer) HTML(html string) string {
+	return s.rich.Sanitize(html)
+}
+
+// Text removes all markup from a plain-text field. Characters with a meaning
+// in HTML are escaped, so the text can be embedded as it is.
+func (s *Sanitizer) Text(text string) string {
+	return s.plain.Sanitize(text)
+}
+
+// renderedHTML sanitizes HTML rendered from sanitized Markdown, keeping the
+// anchors, footnotes and task list checkboxes the renderer adds
+func (s *Sanitizer) renderedHTML(html string) string {
+	return s.rendered.Sanitize(html)
+}
@@ -0,0 +1,202 @@
+package services
+
+import (
+	"bytes"
+	"regexp"
+	"strings"
+	"testing"
+)
+
+// xssPayloads are common ways of getting a script into a page
+var xssPayloads = []struct {
+	name  string
+	input string
+}{
+	{"script tag", `<script>alert(1)</script>`},
+	{"uppercase script tag", `<SCRIPT SRC=//evil.example/x.js></SCRIPT>`},
+	{"img onerror", `<img src=x onerror=alert(1)>`},
+	{"img onerror without spaces", `<img/src=x/onerror=alert(1)>`},
+	{"svg onload", `<svg onload=alert(1)>`},
+	{"body onload", `<body onload=alert(1)>`},
+	{"javascript link", `<a href="javascript:alert(1)">x</a>`},
+	{"encoded javascript link", `<a href="&#106;avascript:alert(1)">x</a>`},
+	{"javascript link with tab", "<a href=\"java\tscript:alert(1)\">x</a>"},
+	{"data link", `<a href="data:text/html;base64,PHNjcmlwdD5hbGVydCgxKTwvc2NyaXB0Pg==">x</a>`},
+	{"iframe", `<iframe src="https://evil.example"></iframe>`},
+	{"object", `<object data="https://evil.example/x.swf"></object>`},
+	{"style attribute", `<p style="background:url(javascript:alert(1))">x</p>`},
+	{"style tag", `<style>body{display:none}</style>`},
+	{"event attribute", `<p onclick="alert(1)">x</p>`},
+	{"form", `<form action="https://evil.example"><input type=submit></form>`},
+	{"meta refresh", `<meta http-equiv="refresh" content="0;url=https://evil.example">`},
+	{"unclosed tag", `<img src=x onerror=alert(1)//`},
+}
+
+// unsafeMarkup matches markup that can run a script or restyle the page.
+// Escaped text, such as &lt;script&gt;, and quoted attribute values other
+// than URLs are harmless and do not match.
+var unsafeMarkup = []*regexp.Regexp{
+	regexp.MustCompile(`(?i)<\s*/?\s*(script|style|iframe|object|embed|svg|body|form|input|meta|link|base)\b`),
+	regexp.MustCompile(`(?i)<(?:[^>"']|"[^"]*"|'[^']*')*[\s/]on[a-z]+\s*=`),
+	regexp.MustCompile(`(?i)<(?:[^>"']|"[^"]*"|'[^']*')*[\s/]style\s*=`),
+	regexp.MustCompile(`(?i)<[^>]*(script|data)\s*:`),
+}
+
+func newTestSanitizer(t *testing.T, policy string) *Sanitizer {
+	t.Helper()
+
+	sanitizer, err := NewSanitizer(SanitizerConfig{Policy: policy})
+	if err != nil {
+		t.Fatal(err)
+	}
+	return sanitizer
+}
+
+func assertSafe(t *testing.T, output string) {
+	t.Helper()
+
+	for _, pattern := range unsafeMarkup {
+		if match := pattern.FindString(output); match != "" {
+			t.Errorf("output %q contains %q", output, match)
+		}
+	}
+}
+
+func TestSanitizerRemovesXSS(t *testing.T) {
+	for _, policy := range []string{SanitizerPolicyUGC, SanitizerPolicyStrict} {
+		sanitizer := newTestSanitizer(t, policy)
+		for _, tc := range xssPayloads {
+			t.Run(policy+"/"+tc.name, func(t *testing.T) {
+				assertSafe(t, sanitizer.HTML(tc.input))
+				assertSafe(t, sanitizer.Text(tc.input))
+			})
+		}
+	}
+}
+
+func TestSanitizerKeepsFormatting(t *testing.T) {
+	sanitizer := newTestSanitizer(t, SanitizerPolicyUGC)
+
+	input := `<p>Hello <strong>world</strong>, see <a href="https://example.com">this</a></p>`
+	got := sanitizer.HTML(input)
+	for _, want := range []string{"<strong>world</strong>", `href="https://example.com"`} {
+		if !strings.Contains(got, want) {
+			t.Errorf("HTML(%q) = %q, want it to contain %q", input, got, want)
+		}
+	}
+}
+
+func TestSanitizerStrictPolicy(t *testing.T) {
+	sanitizer := newTestSanitizer(t, SanitizerPolicyStrict)
+
+	if got := sanitizer.HTML("<p>Hello <strong>world</strong></p>"); got != "Hello world" {
+		t.Errorf("HTML = %q, want %q", got, "Hello world")
+	}
+}
+
+func TestSanitizerText(t *testing.T) {
+	sanitizer := newTestSanitizer(t, SanitizerPolicyUGC)
+
+	tests := []struct {
+		input string
+		want  string
+	}{
+		{"Sunset over the bay", "Sunset over the bay"},
+		{"<b>Bold</b> caption", "Bold caption"},
+		{`Fish & "chips"`, "Fish &amp; &#34;chips&#34;"},
+	}
+	for _, tc := range tests {
+		got := sanitizer.Text(tc.input)
+		if got != tc.want {
+			t.Errorf("Text(%q) = %q, want %q", tc.input, got, tc.want)
+		}
+		if again := sanitizer.Text(got); again != got {
+			t.Errorf("Text(%q) = %q, want it unchanged", got, again)
+		}
+	}
+}
+
+func TestSanitizerConfig(t *testing.T) {
+	if _, err := NewSanitizer(SanitizerConfig{Policy: "none"}); err == nil {
+		t.Error("NewSanitizer accepted an unknown policy")
+	}
+
+	sanitizer, err := NewSanitizer(SanitizerConfig{
+		Policy:          SanitizerPolicyUGC,
+		AllowedElements: []string{"mark"},
+		URLSchemes:      []string{"tel"},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	input := `<mark onclick="alert(1)">Call</mark> <a href="tel:+15555550100">us</a>`
+	got := sanitizer.HTML(input)
+	for _, want := range []string{"<mark>Call</mark>", `href="tel:+15555550100"`} {
+		if !strings.Contains(got, want) {
+			t.Errorf("HTML(%q) = %q, want it to contain %q", input, got, want)
+		}
+	}
+	assertSafe(t, got)
+}
+
+func TestMarkdownRendererRemovesXSS(t *testing.T) {
+	renderer := NewMarkdownRenderer(newTestSanitizer(t, SanitizerPolicyUGC))
+
+	for _, tc := range xssPayloads {
+		t.Run(tc.name, func(t *testing.T) {
+			for _, source := range []string{
+				tc.input,
+				"Some text " + tc.input + " inline",
+				"> quoted\n> " + tc.input,
+				"- item\n\n  " + tc.input,
+			} {
+				rendered, err := renderer.Render(source)
+				if err != nil {
+					t.Fatal(err)
+				}
+				assertSafe(t, rendered.HTML)
+
+				// The stored Markdown stays safe for renderers that pass raw
+				// HTML through
+				var buf bytes.Buffer
+				if err := renderer.markdown.Convert([]byte(rendered.Source), &buf); err != nil {
+					t.Fatal(err)
+				}
+				assertSafe(t, buf.String())
+			}
+		})
+	}
+
+	rendered, err := renderer.Render("[x](javascript:alert(1)) ![y](javascript:alert(1))")
+	if err != nil {
+		t.Fatal(err)
+	}
+	assertSafe(t, rendered.HTML)
+}
+
+func TestMarkdownRendererKeepsMarkdown(t *testing.T) {
+	renderer := NewMarkdownRenderer(newTestSanitizer(t, SanitizerPolicyUGC))
+
+	source := "# Intro\n\n> quoted *text*\n\n```html\n<script>alert(1)</script>\n```\n\n- [x] done\n\nNote[^1]\n\n[^1]: The note\n"
+	rendered, err := renderer.Render(source)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if rendered.Source != source {
+		t.Errorf("Source = %q, want %q", rendered.Source, source)
+	}
+	for _, want := range []string{
+		`<h1 id="intro">Intro</h1>`,
+		"<blockquote>",
+		"&lt;script&gt;alert(1)&lt;/script&gt;",
+		`<input checked="" disabled="" type="checkbox"`,
+		`class="footnote-ref"`,
+	} {
+		if !strings.Contains(rendered.HTML, want) {
+			t.Errorf("HTML = %q, want it to contain %q", rendered.HTML, want)
+		}
+	}
+	assertSafe(t, strings.ReplaceAll(rendered.HTML, "<input", ""))
+}
@@ -0,0 +1,238 @@
+package services
+
+import (
+	"context"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"errors"
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+
+	"go-blog-platform/internal/models"
+)
+
+var (
+	ErrInvalidRefreshToken = errors.New("invalid or expired refresh token")
+	ErrRefreshTokenReused  = errors.New("refresh token has already been used")
+	ErrSessionNotFound     = errors.New("session not found")
+)
+
+type SessionService struct {
+	collection *mongo.Collection
+	refreshTTL time.Duration
+}
+
+func NewSessionService(db *mongo.Database, refreshTTL time.Duration) *SessionService {
+	return &SessionService{
+		collection: db.Collection("sessions"),
+		refreshTTL: refreshTTL,
+	}
+}
+
+// EnsureIndexes creates the lookup indexes and lets MongoDB expire sessions
+// once their refresh token can no longer be used.
+func (s *SessionService) EnsureIndexes(ctx context.Context) error {
+	_, err := s.collection.Indexes().CreateMany(ctx, []mongo.IndexModel{
+		{Keys: bson.D{{Key: "refresh_token_hash", Value: 1}}, Options: options.Index().SetUnique(true)},
+		{Keys: bson.D{{Key: "previous_token_hashes", Value: 1}}},
+		{Keys: bson.D{{Key: "user_id", Value: 1}}},
+		{Keys: bson.D{{Key: "expires_at", Value: 1}}, Options: options.Index().SetExpireAfterSeconds(0)},
+	})
+	return err
+}
+
+// Create starts a new session for the user and returns it together with the
+// plaintext refresh token. Only the token hash is persisted.
+func (s *SessionService) Create(ctx context.Context, userID primitive.ObjectID, userAgent, ipAddress string) (*models.Session, string, error) {
+	refreshToken, err := generateOpaqueToken()
+	if err != nil {
+		return nil, "", err
+	}
+
+	now := time.Now()
+	session := &models.Session{
+		ID:               primitive.NewObjectID(),
+		UserID:           userID,
+		RefreshTokenHash: hashOpaqueToken(refreshToken),
+		UserAgent:        userAgent,
+		IPAddress:        ipAddress,
+		CreatedAt:        now,
+		LastUsedAt:       now,
+		ExpiresAt:        now.Add(s.refreshTTL),
+	}
+
+	if _, err := s.collection.InsertOne(ctx, session); err != nil {
+		return nil, "", err
+	}
+
+	return session, refreshToken, nil
+}
+
+// Rotate exchanges a refresh token for a new one. Each refresh token can be
+// used exactly once; presenting a token that was already rotated revokes the
+// whole session, since it means the token has leaked.
+func (s *SessionService) Rotate(ctx context.Context, refreshToken string) (*models.Session, string, error) {
+	newToken, err := generateOpaqueToken()
+	if err != nil {
+		return nil, "", err
+	}
+
+	now := time.Now()
+	tokenHash := hashOpaqueToken(refreshToken)
+
+	var session models.Session
+	err = s.collection.FindOneAndUpdate(
+		ctx,
+		bson.M{
+			"refresh_token_hash": tokenHash,
+			"revoked_at":         nil,
+			"expires_at":         bson.M{"$gt": now},
+		},
+		bson.M{
+			"$set": bson.M{
+				"refresh_token_hash": hashOpaqueToken(newToken),
+				"last_used_at":       now,
+				"expires_at":         now.Add(s.refreshTTL),
+			},
+			"$push": bson.M{"previous_token_hashes": tokenHash},
+		},
+		options.FindOneAndUpdate().SetReturnDocument(options.After),
+	).Decode(&session)
+
+	if err == nil {
+		return &session, newToken, nil
+	}
+	if err != mongo.ErrNoDocuments {
+		return nil, "", err
+	}
+
+	// The token is not current; check whether it belongs to an earlier rotation.
+	result, err := s.collection.UpdateOne(
+		ctx,
+		bson.M{"previous_token_hashes": tokenHash, "revoked_at": nil},
+		bson.M{"$set": bson.M{"revoked_at": now}},
+	)
+	if err != nil {
+		return nil, "", err
+	}
+	if result.MatchedCount > 0 {
+		return nil, "", ErrRefreshTokenReused
+	}
+
+	return nil, "", ErrInvalidRefreshToken
+}
+
+// IsActive reports whether the session exists, has not been revoked and has
+// not expired.
+func (s *SessionService) IsActive(ctx context.Context, sessionID string) (bool, error) {
+	id, err := primitive.ObjectIDFromHex(sessionID)
+	if err != nil {
+		return false, nil
+	}
+
+	count, err := s.collection.CountDocuments(ctx, bson.M{
+		"_id":        id,
+		"revoked_at": nil,
+		"expires_at": bson.M{"$gt": time.Now()},
+	})
+	if err != nil {
+		return false, err
+	}
+
+	return count > 0, nil
+}
+
+// ListActive returns the user's sessions that can still be refreshed, most
+// recently used first.
+func (s *SessionService) ListActive(ctx context.Context, userID primitive.ObjectID) ([]models.Session, error) {
+	opts := options.Find().SetSort(bson.D{{Key: "last_used_at", Value: -1}})
+	cursor, err := s.collection.Find(ctx, bson.M{
+		"user_id":    userID,
+		"revoked_at": nil,
+		"expires_at": bson.M{"$gt": time.Now()},
+	}, opts)
+	if err != nil {
+		return nil, err
+	}
+	defer cursor.Close(ctx)
+
+	sessions := []models.Session{}
+	if err := cursor.All(ctx, &sessions); err != nil {
+		return nil, err
+	}
+
+	return sessions, nil
+}
+
+// Revoke revokes a single session.
+func (s *SessionService) Revoke(ctx context.Context, sessionID primitive.ObjectID) error {
+	return s.revoke(ctx, bson.M{"_id": sessionID, "revoked_at": nil})
+}
+
+// RevokeForUser revokes a single session, provided it belongs to the user.
+func (s *SessionService) RevokeForUser(ctx context.Context, userID, sessionID primitive.ObjectID) error {
+	return s.revoke(ctx, bson.M{"_id": sessionID, "user_id": userID, "revoked_at": nil})
+}
+
+func (s *SessionService) revoke(ctx context.Context, filter bson.M) error {
+	result, err := s.collection.UpdateOne(ctx, filter, bson.M{"$set": bson.M{"revoked_at": time.Now()}})
+	if err != nil {
+		return err
+	}
+	if result.MatchedCount == 0 {
+		return ErrSessionNotFound
+	}
+
+	return nil
+}
+
+// RevokeAll revokes every active session of the user and returns how many
+// sessions were revoked.
+func (s *SessionService) RevokeAll(ctx context.Context, userID primitive.ObjectID) (int64, error) {
+	result, err := s.collection.UpdateMany(
+		ctx,
+		bson.M{"user_id": userID, "revoked_at": nil},
+		bson.M{"$set": bson.M{"revoked_at": time.Now()}},
+	)
+	if err != nil {
+		return 0, err
+	}
+
+	return result.ModifiedCount, nil
+}
+
+// RevokeOthers revokes every active session of the user except the given
+// one and returns how many sessions were revoked.
+func (s *SessionService) RevokeOthers(ctx context.Context, userID, keepSessionID primitive.ObjectID) (int64, error) {
+	result, err := s.collection.UpdateMany(
+		ctx,
+		bson.M{"user_id": userID, "_id": bson.M{"$ne": keepSessionID}, "revoked_at": nil},
+		bson.M{"$set": bson.M{"revoked_at": time.Now()}},
+	)
+	if err != nil {
+		return 0, err
+	}
+
+	return result.ModifiedCount, nil
+}
+
+// generateOpaqueToken returns a random bearer token that is only ever stored
+// as its hashOpaqueToken digest
+func generateOpaqueToken() (string, error) {
+	b := make([]byte, 32)
+	if _, err := rand.Read(b); err != nil {
+		return "", err
+	}
+	return base64.RawURLEncoding.EncodeToString(b), nil
+}
+
+func hashOpaqueToken(token string) string {
+	sum := sha256.Sum256([]byte(token))
+	return hex.EncodeToString(sum[:])
+}
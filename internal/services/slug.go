@@ -0,0 +1,244 @@
+package services
+
+import (
+	"context"
+	"fmt"
+	"regexp"
+	"strconv"
+	"strings"
+	"unicode"
+	"unicode/utf8"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+	"golang.org/x/text/unicode/norm"
+
+	"go-blog-platform/internal/models"
+)
+
+const (
+	// maxSlugLength is the most characters a slug takes from a title
+	maxSlugLength = 80
+	// fallbackSlug is used for titles without any letters or digits
+	fallbackSlug = "post"
+	// slugAssignAttempts bounds the retries when another post takes a slug
+	// between picking it and saving it
+	slugAssignAttempts = 5
+)
+
+// slugTransliterations spells letters that do not decompose into an ASCII
+// letter and accents
+var slugTransliterations = map[rune]string{
+	// Latin
+	'ß': "ss", 'æ': "ae", 'œ': "oe", 'ø': "o", 'đ': "d", 'ð': "d", 'ħ': "h",
+	'ı': "i", 'ł': "l", 'ŀ': "l", 'þ': "th", 'ŧ': "t",
+	// Cyrillic
+	'а': "a", 'б': "b", 'в': "v", 'г': "g", 'ґ': "g", 'д': "d", 'е': "e",
+	'ё': "yo", 'є': "ye", 'ж': "zh", 'з': "z", 'и': "i", 'і': "i", 'ї': "yi",
+	'й': "y", 'к': "k", 'л': "l", 'м': "m", 'н': "n", 'о': "o", 'п': "p",
+	'р': "r", 'с': "s", 'т': "t", 'у': "u", 'ф': "f", 'х': "kh", 'ц': "ts",
+	'ч': "ch", 'ш': "sh", 'щ': "shch", 'ъ': "", 'ы': "y", 'ь': "", 'э': "e",
+	'ю': "yu", 'я': "ya",
+	// Greek
+	'α': "a", 'β': "v", 'γ': "g", 'δ': "d", 'ε': "e", 'ζ': "z", 'η': "i",
+	'θ': "th", 'ι': "i", 'κ': "k", 'λ': "l", 'μ': "m", 'ν': "n", 'ξ': "x",
+	'ο': "o", 'π': "p", 'ρ': "r", 'σ': "s", 'ς': "s", 'τ': "t", 'υ': "y",
+	'φ': "f", 'χ': "ch", 'ψ': "ps", 'ω': "o",
+}
+
+// Slugify turns a title into a lowercase, hyphen-separated slug. Accented
+// Latin, Cyrillic and Greek letters are transliterated to ASCII; letters of
+// other scripts, such as Thai, are kept as they are.
+func Slugify(title string) string {
+	if slug := slugOf(title); slug != "" {
+		return slug
+	}
+	return fallbackSlug
+}
+
+// slugOf makes the slug of a text, which is empty if the text has no letters
+// or digits
+func slugOf(title string) string {
+	var b strings.Builder
+	pendingHyphen := false
+	asciiBase := false
+
+	write := func(spelled string, ascii bool) {
+		if pendingHyphen {
+			b.WriteByte('-')
+			pendingHyphen = false
+		}
+		b.WriteString(spelled)
+		asciiBase = ascii
+	}
+
+	for _, r := range norm.NFC.String(strings.ToLower(title)) {
+		// Keep the marks of scripts such as Thai, drop accents left on ASCII letters
+		if unicode.Is(unicode.Mn, r) {
+			if !asciiBase && b.Len() > 0 && !pendingHyphen {
+				write(string(r), false)
+			}
+			continue
+		}
+		// Apostrophes do not split words
+		if r == '\'' || r == '’' {
+			continue
+		}
+		if !unicode.IsLetter(r) && !unicode.IsDigit(r) {
+			pendingHyphen = b.Len() > 0
+			continue
+		}
+
+		// Spell letters by their transliteration or, failing that, by their
+		// unaccented ASCII form
+		base, _ := utf8.DecodeRuneInString(norm.NFD.String(string(r)))
+		if spelled, ok := slugTransliterations[r]; ok {
+			write(spelled, true)
+		} else if spelled, ok := slugTransliterations[base]; ok {
+			write(spelled, true)
+		} else if base < utf8.RuneSelf {
+			write(string(base), true)
+		} else {
+			write(string(r), false)
+		}
+	}
+
+	// Cut long slugs after a whole word where possible
+	slug := []rune(norm.NFC.String(b.String()))
+	if len(slug) > maxSlugLength {
+		slug = slug[:maxSlugLength]
+		for i := len(slug) - 1; i > 0; i-- {
+			if slug[i] == '-' {
+				slug = slug[:i]
+				break
+			}
+		}
+	}
+	return string(slug)
+}
+
+// SlugService keeps post slugs unique. Slugs a post had before its title
+// changed stay reserved for it so old links keep working.
+type SlugService struct {
+	posts *mongo.Collection
+}
+
+func NewSlugService(db *mongo.Database) *SlugService {
+	return &SlugService{
+		posts: db.Collection("posts"),
+	}
+}
+
+// EnsureIndexes creates the unique slug index and the index for looking up
+// old slugs
+func (s *SlugService) EnsureIndexes(ctx context.Context) error {
+	_, err := s.posts.Indexes().CreateMany(ctx, []mongo.IndexModel{
+		{
+			Keys: bson.D{{Key: "slug", Value: 1}},
+			Options: options.Index().
+				SetUnique(true).
+				SetPartialFilterExpression(bson.M{"slug": bson.M{"$type": "string"}}),
+		},
+		{Keys: bson.D{{Key: "slug_history", Value: 1}}},
+	})
+	return err
+}
+
+// Available returns the title's slug, or the slug with the lowest numeric
+// suffix that no other post uses now or used before
+func (s *SlugService) Available(ctx context.Context, title string, postID primitive.ObjectID) (string, error) {
+	base := Slugify(title)
+	pattern := primitive.Regex{Pattern: "^" + regexp.QuoteMeta(base) + `(-\d+)?$`}
+
+	opts := options.Find().SetProjection(bson.M{"slug": 1, "slug_history": 1})
+	cursor, err := s.posts.Find(ctx, bson.M{
+		"_id": bson.M{"$ne": postID},
+		"$or": []bson.M{{"slug": pattern}, {"slug_history": pattern}},
+	}, opts)
+	if err != nil {
+		return "", err
+	}
+	defer cursor.Close(ctx)
+
+	var posts []models.Post
+	if err := cursor.All(ctx, &posts); err != nil {
+		return "", err
+	}
+
+	taken := make(map[string]bool)
+	for _, post := range posts {
+		taken[post.Slug] = true
+		for _, slug := range post.SlugHistory {
+			taken[slug] = true
+		}
+	}
+
+	slug := base
+	for n := 2; taken[slug]; n++ {
+		slug = fmt.Sprintf("%s-%d", base, n)
+	}
+	return slug, nil
+}
+
+// Assign picks a slug for the title and saves it with write, picking again
+// if another post took the slug in the meantime
+func (s *SlugService) Assign(ctx context.Context, title string, postID primitive.ObjectID, write func(slug string) error) error {
+	var err error
+	for attempt := 0; attempt < slugAssignAttempts; attempt++ {
+		var slug string
+		slug, err = s.Available(ctx, title, postID)
+		if err != nil {
+			return err
+		}
+		if err = write(slug); !mongo.IsDuplicateKeyError(err) {
+			return err
+		}
+	}
+	return err
+}
+
+// MigrateSlugs gives a slug to posts created before posts had them
+func (s *SlugService) MigrateSlugs(ctx context.Context) error {
+	opts := options.Find().
+		SetSort(bson.D{{Key: "created_at", Value: 1}}).
+		SetProjection(bson.M{"title": 1})
+	cursor, err := s.posts.Find(ctx, bson.M{"slug": bson.M{"$exists": false}}, opts)
+	if err != nil {
+		return err
+	}
+	defer cursor.Close(ctx)
+
+	var posts []models.Post
+	if err := cursor.All(ctx, &posts); err != nil {
+		return err
+	}
+
+	for _, post := range posts {
+		err := s.Assign(ctx, post.Title, post.ID, func(slug string) error {
+			_, err := s.posts.UpdateOne(ctx, bson.M{"_id": post.ID}, bson.M{"$set": bson.M{"slug": slug}})
+			return err
+		})
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// SlugFitsTitle reports whether slug was made from the title, possibly with
+// a collision suffix, so the post can keep it when the title changes
+// without changing its slug
+func SlugFitsTitle(slug, title string) bool {
+	base := Slugify(title)
+	if slug == base {
+		return true
+	}
+	suffix, ok := strings.CutPrefix(slug, base+"-")
+	if !ok {
+		return false
+	}
+	n, err := strconv.Atoi(suffix)
+	return err == nil && n >= 2 && strconv.Itoa(n) == suffix
+}
@@ -0,0 +1,158 @@
+package services
+
+import (
+	"errors"
+	"time"
+
+	"github.com/golang-jwt/jwt/v5"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+
+	"go-blog-platform/internal/models"
+)
+
+var ErrInvalidToken = errors.New("invalid token")
+
+// Claims is the claims contract shared by token signing and verification.
+// The subject is the user ID as a hex string.
+type Claims struct {
+	Email         string `json:"email,omitempty"`
+	EmailVerified bool   `json:"email_verified"`
+	Role          string `json:"role"`
+	TwoFactor     bool   `json:"tfa,omitempty"`
+	SessionID     string `json:"sid"`
+	jwt.RegisteredClaims
+}
+
+// Purposes of single-action tokens sent in links
+const (
+	PurposeEmailVerification  = "email_verification"
+	PurposeTwoFactorChallenge = "two_factor_challenge"
+	PurposeEmailChange        = "email_change"
+)
+
+// ActionClaims authorize a single action, such as confirming an email
+// address, for the user in the subject. They are never accepted as access
+// tokens.
+type ActionClaims struct {
+	Purpose string `json:"purpose"`
+	Email   string `json:"email,omitempty"`
+	jwt.RegisteredClaims
+}
+
+// TokenService signs and verifies access tokens.
+type TokenService struct {
+	keys      *KeySet
+	issuer    string
+	audience  string
+	accessTTL time.Duration
+}
+
+func NewTokenService(keys *KeySet, issuer, audience string, accessTTL time.Duration) *TokenService {
+	return &TokenService{
+		keys:      keys,
+		issuer:    issuer,
+		audience:  audience,
+		accessTTL: accessTTL,
+	}
+}
+
+// AccessTokenTTL returns how long issued access tokens stay valid.
+func (s *TokenService) AccessTokenTTL() time.Duration {
+	return s.accessTTL
+}
+
+// IssueAccessToken signs an access token for the user bound to the session.
+func (s *TokenService) IssueAccessToken(user *models.User, sessionID string) (string, error) {
+	now := time.Now()
+	claims := Claims{
+		Email:         user.Email,
+		EmailVerified: user.EmailVerified,
+		Role:          user.Role,
+		TwoFactor:     user.TwoFactorEnabled,
+		SessionID:     sessionID,
+		RegisteredClaims: jwt.RegisteredClaims{
+			Subject:   user.ID.Hex(),
+			Issuer:    s.issuer,
+			Audience:  jwt.ClaimStrings{s.audience},
+			IssuedAt:  jwt.NewNumericDate(now),
+			ExpiresAt: jwt.NewNumericDate(now.Add(s.accessTTL)),
+		},
+	}
+
+	return s.keys.Sign(claims)
+}
+
+// JWKS returns the public keys access tokens can be verified with.
+func (s *TokenService) JWKS() JWKS {
+	return s.keys.JWKS()
+}
+
+// ParseAccessToken verifies the token signature, algorithm, issuer, audience
+// and expiry and returns its claims. Tokens must be signed with the algorithm
+// of the key their "kid" header names.
+func (s *TokenService) ParseAccessToken(tokenString string) (*Claims, error) {
+	claims := &Claims{}
+	token, err := jwt.ParseWithClaims(
+		tokenString,
+		claims,
+		s.keys.Keyfunc,
+		jwt.WithValidMethods(s.keys.Algorithms()),
+		jwt.WithIssuer(s.issuer),
+		jwt.WithAudience(s.audience),
+		jwt.WithExpirationRequired(),
+		jwt.WithIssuedAt(),
+	)
+	if err != nil || !token.Valid {
+		return nil, ErrInvalidToken
+	}
+
+	if claims.Subject == "" || claims.SessionID == "" {
+		return nil, ErrInvalidToken
+	}
+
+	return claims, nil
+}
+
+// IssueActionToken signs a token that authorizes a single action for the
+// user, bound to the given email address.
+func (s *TokenService) IssueActionToken(purpose string, userID primitive.ObjectID, email string, ttl time.Duration) (string, error) {
+	now := time.Now()
+	claims := ActionClaims{
+		Purpose: purpose,
+		Email:   email,
+		RegisteredClaims: jwt.RegisteredClaims{
+			Subject:   userID.Hex(),
+			Issuer:    s.issuer,
+			Audience:  jwt.ClaimStrings{s.issuer},
+			IssuedAt:  jwt.NewNumericDate(now),
+			ExpiresAt: jwt.NewNumericDate(now.Add(ttl)),
+		},
+	}
+
+	return s.keys.Sign(claims)
+}
+
+// ParseActionToken verifies a token issued by IssueActionToken for the
+// given purpose.
+func (s *TokenService) ParseActionToken(purpose, tokenString string) (*ActionClaims, error) {
+	claims := &ActionClaims{}
+	token, err := jwt.ParseWithClaims(
+		tokenString,
+		claims,
+		s.keys.Keyfunc,
+		jwt.WithValidMethods(s.keys.Algorithms()),
+		jwt.WithIssuer(s.issuer),
+		jwt.WithAudience(s.issuer),
+		jwt.WithExpirationRequired(),
+		jwt.WithIssuedAt(),
+	)
+	if err != nil || !token.Valid {
+		return nil, ErrInvalidToken
+	}
+
+	if claims.Purpose != purpose || claims.Subject == "" {
+		return nil, ErrInvalidToken
+	}
+
+	return claims, nil
+}
@@ -0,0 +1,222 @@
+package services
+
+import (
+	"net/url"
+	"strings"
+	"testing"
+	"time"
+)
+
+// rfc6238Secret is the SHA-1 key of the RFC 6238 test vectors,
+// "12345678901234567890", in base32
+const rfc6238Secret = "GEZDGNBVGY3TQOJQGEZDGNBVGY3TQOJQ"
+
+// rfc6238Vectors are the SHA-1 test vectors of RFC 6238, appendix B,
+// truncated to the six digits authenticator apps show
+var rfc6238Vectors = []struct {
+	unix int64
+	code string
+}{
+	{59, "287082"},
+	{1111111109, "081804"},
+	{1111111111, "050471"},
+	{1234567890, "005924"},
+	{2000000000, "279037"},
+	{20000000000, "353130"},
+}
+
+func TestHOTPVectors(t *testing.T) {
+	key, err := totpEncoding.DecodeString(rfc6238Secret)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for _, tc := range rfc6238Vectors {
+		if got := hotp(key, uint64(tc.unix/totpPeriod)); got != tc.code {
+			t.Errorf("hotp at %d = %s, want %s", tc.unix, got, tc.code)
+		}
+	}
+}
+
+func TestValidateTOTPVectors(t *testing.T) {
+	for _, tc := range rfc6238Vectors {
+		step, ok := ValidateTOTP(rfc6238Secret, tc.code, 0, time.Unix(tc.unix, 0))
+		if !ok {
+			t.Errorf("ValidateTOTP rejected %s at %d", tc.code, tc.unix)
+			continue
+		}
+		if step != tc.unix/totpPeriod {
+			t.Errorf("ValidateTOTP at %d returned step %d, want %d", tc.unix, step, tc.unix/totpPeriod)
+		}
+	}
+}
+
+func TestValidateTOTPInput(t *testing.T) {
+	now := time.Unix(1111111111, 0)
+
+	tests := []struct {
+		name   string
+		secret string
+		code   string
+		want   bool
+	}{
+		{"valid", rfc6238Secret, "050471", true},
+		{"lowercase secret", strings.ToLower(rfc6238Secret), "050471", true},
+		{"spaces", rfc6238Secret, " 050 471 ", true},
+		{"wrong code", rfc6238Secret, "050472", false},
+		{"too short", rfc6238Secret, "50471", false},
+		{"eight digits", rfc6238Secret, "14050471", false},
+		{"invalid secret", "not base32!", "050471", false},
+		{"empty", rfc6238Secret, "", false},
+	}
+	for _, tc := range tests {
+		if _, got := ValidateTOTP(tc.secret, tc.code, 0, now); got != tc.want {
+			t.Errorf("%s: ValidateTOTP = %v, want %v", tc.name, got, tc.want)
+		}
+	}
+}
+
+func TestValidateTOTPSkew(t *testing.T) {
+	key, err := totpEncoding.DecodeString(rfc6238Secret)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	now := time.Unix(1234567890, 0)
+	current := now.Unix() / totpPeriod
+
+	tests := []struct {
+		offset int64
+		want   bool
+	}{
+		{-2, false},
+		{-1, true},
+		{0, true},
+		{1, true},
+		{2, false},
+	}
+	for _, tc := range tests {
+		code := hotp(key, uint64(current+tc.offset))
+		step, ok := ValidateTOTP(rfc6238Secret, code, 0, now)
+		if ok != tc.want {
+			t.Errorf("code from step %+d: ValidateTOTP = %v, want %v", tc.offset, ok, tc.want)
+		}
+		if ok && step != current+tc.offset {
+			t.Errorf("code from step %+d: ValidateTOTP returned step %d, want %d", tc.offset, step, current+tc.offset)
+		}
+	}
+}
+
+func TestValidateTOTPReplay(t *testing.T) {
+	key, err := totpEncoding.DecodeString(rfc6238Secret)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	now := time.Unix(1234567890, 0)
+	current := now.Unix() / totpPeriod
+	code := hotp(key, uint64(current))
+
+	step, ok := ValidateTOTP(rfc6238Secret, code, 0, now)
+	if !ok {
+		t.Fatal("ValidateTOTP rejected a fresh code")
+	}
+
+	// The same code again, even a little later, was already used
+	if _, ok := ValidateTOTP(rfc6238Secret, code, step, now); ok {
+		t.Error("ValidateTOTP accepted a code twice")
+	}
+	if _, ok := ValidateTOTP(rfc6238Secret, code, step, now.Add(totpPeriod*time.Second)); ok {
+		t.Error("ValidateTOTP accepted a used code in the next step")
+	}
+
+	// So was any code from before the last one, still within the skew window
+	previous := hotp(key, uint64(current-1))
+	if _, ok := ValidateTOTP(rfc6238Secret, previous, step, now); ok {
+		t.Error("ValidateTOTP accepted a code older than the last used one")
+	}
+
+	// The next step's code is still good
+	next := hotp(key, uint64(current+1))
+	if got, ok := ValidateTOTP(rfc6238Secret, next, step, now); !ok || got != current+1 {
+		t.Errorf("ValidateTOTP(next) = %d, %v, want %d, true", got, ok, current+1)
+	}
+}
+
+func TestGenerateTOTPSecret(t *testing.T) {
+	secret, err := GenerateTOTPSecret()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	key, err := totpEncoding.DecodeString(secret)
+	if err != nil {
+		t.Fatalf("secret %q is not base32: %v", secret, err)
+	}
+	if len(key) != totpSecretSize {
+		t.Errorf("secret has %d bytes, want %d", len(key), totpSecretSize)
+	}
+
+	now := time.Now()
+	code := hotp(key, uint64(now.Unix()/totpPeriod))
+	if _, ok := ValidateTOTP(secret, code, 0, now); !ok {
+		t.Error("ValidateTOTP rejected a code for a generated secret")
+	}
+}
+
+func TestTOTPURI(t *testing.T) {
+	uri := TOTPURI("My Blog", "ann@example.com", rfc6238Secret)
+
+	parsed, err := url.Parse(uri)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if parsed.Scheme != "otpauth" || parsed.Host != "totp" {
+		t.Errorf("URI %q is not an otpauth://totp URI", uri)
+	}
+	if parsed.Path != "/My Blog:ann@example.com" {
+		t.Errorf("label = %q, want %q", parsed.Path, "/My Blog:ann@example.com")
+	}
+	if strings.Contains(uri, "+") {
+		t.Errorf("URI %q encodes spaces as +", uri)
+	}
+
+	query := parsed.Query()
+	for key, want := range map[string]string{
+		"secret":    rfc6238Secret,
+		"issuer":    "My Blog",
+		"algorithm": "SHA1",
+		"digits":    "6",
+		"period":    "30",
+	} {
+		if got := query.Get(key); got != want {
+			t.Errorf("%s = %q, want %q", key, got, want)
+		}
+	}
+}
+
+func TestRecoveryCodes(t *testing.T) {
+	codes, err := GenerateRecoveryCodes(10)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(codes) != 10 {
+		t.Fatalf("got %d codes, want 10", len(codes))
+	}
+
+	seen := make(map[string]bool)
+	for _, code := range codes {
+		if len(code) != 11 || code[5] != '-' {
+			t.Errorf("code %q is not formatted as xxxxx-xxxxx", code)
+		}
+		if seen[code] {
+			t.Errorf("code %q generated twice", code)
+		}
+		seen[code] = true
+
+		loose := strings.ToUpper(strings.Replace(code, "-", " ", 1))
+		if HashRecoveryCode(loose) != HashRecoveryCode(code) {
+			t.Errorf("HashRecoveryCode(%q) differs from HashRecoveryCode(%q)", loose, code)
+		}
+	}
+}
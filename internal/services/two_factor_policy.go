@@ -0,0 +1,112 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+
+	"go-blog-platform/internal/models"
+)
+
+var ErrInvalidPolicyRole = errors.New("invalid role")
+
+// twoFactorPolicyCacheTTL bounds how long other server instances keep
+// enforcing a policy after an admin changes it
+const twoFactorPolicyCacheTTL = 30 * time.Second
+
+// TwoFactorPolicyService stores the admin-managed "2FA required for role"
+// policy in the settings collection.
+type TwoFactorPolicyService struct {
+	collection  *mongo.Collection
+	roles       *RoleService
+	defaultRole string
+
+	mu       sync.Mutex
+	policy   *models.TwoFactorPolicy
+	loadedAt time.Time
+}
+
+// NewTwoFactorPolicyService returns the policy service. defaultRole applies
+// until an admin sets a policy.
+func NewTwoFactorPolicyService(db *mongo.Database, roles *RoleService, defaultRole string) *TwoFactorPolicyService {
+	return &TwoFactorPolicyService{
+		collection:  db.Collection("settings"),
+		roles:       roles,
+		defaultRole: defaultRole,
+	}
+}
+
+// Get returns the current policy
+func (s *TwoFactorPolicyService) Get(ctx context.Context) (*models.TwoFactorPolicy, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if s.policy != nil && time.Since(s.loadedAt) < twoFactorPolicyCacheTTL {
+		return s.policy, nil
+	}
+
+	var policy models.TwoFactorPolicy
+	err := s.collection.FindOne(ctx, bson.M{"_id": models.TwoFactorPolicySettingID}).Decode(&policy)
+	if err == mongo.ErrNoDocuments {
+		policy = models.TwoFactorPolicy{ID: models.TwoFactorPolicySettingID, RequiredRole: s.defaultRole}
+	} else if err != nil {
+		return nil, err
+	}
+
+	s.policy = &policy
+	s.loadedAt = time.Now()
+	return s.policy, nil
+}
+
+// Set changes the role two-factor authentication is required for. An empty
+// role removes the requirement.
+func (s *TwoFactorPolicyService) Set(ctx context.Context, requiredRole string, updatedBy primitive.ObjectID) (*models.TwoFactorPolicy, error) {
+	if requiredRole != "" {
+		_, err := s.roles.Get(ctx, requiredRole)
+		if err == ErrRoleNotFound {
+			return nil, ErrInvalidPolicyRole
+		}
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	policy := models.TwoFactorPolicy{
+		ID:           models.TwoFactorPolicySettingID,
+		RequiredRole: requiredRole,
+		UpdatedBy:    updatedBy,
+		UpdatedAt:    time.Now(),
+	}
+	_, err := s.collection.ReplaceOne(ctx, bson.M{"_id": policy.ID}, policy, options.Replace().SetUpsert(true))
+	if err != nil {
+		return nil, err
+	}
+
+	s.mu.Lock()
+	s.policy = &policy
+	s.loadedAt = time.Now()
+	s.mu.Unlock()
+
+	return &policy, nil
+}
+
+// Requires reports whether users with the given role must use two-factor
+// authentication. A role is covered when it holds every permission of the
+// policy's role, so requiring it for authors also covers editors and admins.
+func (s *TwoFactorPolicyService) Requires(ctx context.Context, role string) (bool, error) {
+	policy, err := s.Get(ctx)
+	if err != nil {
+		return false, err
+	}
+	if policy.RequiredRole == "" {
+		return false, nil
+	}
+
+	return s.roles.Covers(ctx, role, policy.RequiredRole)
+}